@@ -1,59 +1,327 @@
 package cmd
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"io/ioutil"
-	"log"
+	"os"
 	"path/filepath"
 
 	"github.com/lohvht/went/lang"
+	"github.com/lohvht/went/lang/token"
 )
 
 // NOTE: write-up on how to decouple CLI and Running commands
 // https://npf.io/2016/10/reusable-commands/
+// Subcommands are registered in command.go
+
+const (
+	stdinArg  = "-"       // file name that denotes reading the script from stdin
+	stdinName = "<stdin>" // name of the input used for error reporting when reading from stdin
+
+	scriptArgsName = "args" // name of the global list holding the arguments passed to the script
+)
+
+// Exit codes of the process, following the conventions of sysexits.h
+const (
+	exitOK       = 0  // successful termination
+	exitFailure  = 1  // lint checks reported errors, e.g. warnings with -Werror, or tests failed
+	exitUsage    = 64 // the command was used incorrectly, e.g. with unknown flags
+	exitSyntax   = 65 // the input script has syntax errors
+	exitNoInput  = 66 // the input script does not exist or is not readable
+	exitSoftware = 70 // the input script failed with a runtime error
+)
 
 // Run starts the command line process, returning an error code when the process is
 // finished
 func Run() int {
-	// if len(os.Args) < 2 {
-	// 	fmt.Println("Entering Interpreter Mode")
-	// }
-	filePtr := flag.String("f", "", "Script file to read and parse (Required)")
-	flag.Parse()
+	args, ok := stripGlobalFlags(os.Args[1:])
+	if !ok {
+		return exitUsage
+	}
+	if len(args) > 0 {
+		if c, ok := lookupCommand(args[0]); ok {
+			return c.run(c, args[1:])
+		}
+	}
+	// not a command, "went file.went" is a shortcut for "went run file.went"
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.Usage = func() {
+		printCommands(flags.Output())
+		fmt.Fprintln(flags.Output(), "\nThe flags are:")
+		flags.PrintDefaults()
+	}
+	filePtr := flags.String("f", "", "Script file to read and parse (\"-\" for stdin), may also be given as the first argument; enters interpreter mode if omitted")
+	exprPtr := flags.String("e", "", "Expression to evaluate, printing its result")
+	checkPtr := flags.Bool("check", false, "Check the script for errors without running it")
+	astPtr := flags.Bool("ast", false, "Print the AST of the script without running it")
+	tokensPtr := flags.Bool("tokens", false, "Print the tokens of the script without running it")
+	profilePtr := flags.Bool("profile", false, "Print the time spent on each line of the script to stderr")
+	coverPtr := flags.Bool("cover", false, "Print the statement coverage of the script to stderr")
+	coverHTMLPtr := flags.String("coverhtml", "", "Write an HTML report of the statement coverage of the script to the file")
+	noColorPtr := flags.Bool(noColorFlag, false, "Print errors and warnings without colors")
+	errorsPtr := flags.String(errorsFlag, errPrinter.format, "Format of the errors and warnings, \"text\" or \"json\" for one JSON object per line")
+	flags.BoolVar(&werror, werrorFlag, werror, "Treat warnings as errors")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if *noColorPtr {
+		disableColor()
+	}
+	if !setFormat(*errorsPtr) {
+		return exitUsage
+	}
 
+	if *exprPtr != "" {
+		return evalInput(*exprPtr)
+	}
+	scriptArgs := flags.Args()
+	if *filePtr == "" && flags.NArg() > 0 {
+		*filePtr, scriptArgs = flags.Arg(0), flags.Args()[1:]
+	}
 	if *filePtr == "" {
-		flag.PrintDefaults()
-		return 1
+		return interpreterMode()
+	}
+	switch {
+	case *checkPtr:
+		return withScript(*filePtr, checkInput)
+	case *astPtr:
+		return withScript(*filePtr, printAST)
+	case *tokensPtr:
+		return withScript(*filePtr, printTokens)
+	}
+	return runScript(*filePtr, scriptArgs, runOptions{profile: *profilePtr, cover: *coverPtr, coverHTML: *coverHTMLPtr})
+}
+
+// withScript reads the script at path and passes it to fn, returning the exit
+// code returned by fn, or the exit code for an unreadable input
+func withScript(path string, fn func(name, input string) int) int {
+	name, input, err := readScript(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitNoInput
+	}
+	return fn(name, input)
+}
+
+// runOptions holds the optional settings of a script run
+type runOptions struct {
+	profile   bool   // print the profile of the run to stderr
+	cover     bool   // print the coverage summary of the run to stderr
+	coverHTML string // write the HTML coverage report to this file if set
+}
+
+// runScript reads and runs the script at path with the given script arguments
+func runScript(path string, args []string, opts runOptions) int {
+	return withScript(path, func(name, input string) int {
+		cfg := lang.Config{Env: scriptEnv(args)}
+		var p *profiler
+		if opts.profile {
+			p = newProfiler()
+			cfg.Hook = chainHooks(cfg.Hook, p.hook)
+		}
+		var cov *coverage
+		if opts.cover || opts.coverHTML != "" {
+			cov = &coverage{}
+			cfg.Hook = chainHooks(cfg.Hook, cov.add(name, input))
+		}
+		code := interpretInput(context.Background(), name, input, cfg)
+		if p != nil {
+			p.stop()
+			p.report(os.Stderr, name)
+		}
+		if cov != nil {
+			if code := reportCoverage(cov, opts); code != exitOK {
+				return code
+			}
+		}
+		return code
+	})
+}
+
+// reportCoverage prints the coverage summary and writes the HTML report as
+// requested by opts, returning the exit code of the process
+func reportCoverage(cov *coverage, opts runOptions) int {
+	if opts.cover {
+		cov.report(os.Stderr)
+	}
+	if opts.coverHTML != "" {
+		if err := cov.writeHTMLFile(opts.coverHTML); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+// chainHooks returns a hook that calls each of the non-nil hooks in order
+func chainHooks(hooks ...lang.Hook) lang.Hook {
+	var chain []lang.Hook
+	for _, h := range hooks {
+		if h != nil {
+			chain = append(chain, h)
+		}
+	}
+	if len(chain) == 1 {
+		return chain[0]
+	}
+	return func(i *lang.Interpreter, node lang.Node) {
+		for _, h := range chain {
+			h(i, node)
+		}
+	}
+}
+
+// readScript reads the entire script at path, or from stdin if the path is "-",
+// returning the name of the script to be used for error reporting
+func readScript(path string) (name, input string, err error) {
+	if path == stdinArg {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", "", fmt.Errorf("Encountered error with reading from stdin: %s", err)
+		}
+		return stdinName, string(b), nil
 	}
 	// Read the entire script into file, this is how they handle it for golang's html/template: https://golang.org/src/html/template/template.go (LINE 420)
 	// NOTE: If this proves to be an issue later on, use a buffer a la: https://stackoverflow.com/questions/13514184/how-can-i-read-a-whole-file-into-a-string-variable-in-golang
 	// Not likely though, since our scripts are meant to be literally all text (i.e. no finicky business with images)
 	// Worst case scenario would be to restrict the file extension?
-	b, err := ioutil.ReadFile(*filePtr)
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Encountered error with opening/reading the file input: %s.\n", *filePtr)
-		return 1
+		return "", "", fmt.Errorf("Encountered error with opening/reading the file input: %s", path)
 	}
-	s := string(b) // string value of input
-	name := filepath.Base(*filePtr)
-	parseInput(name, s)
-	return 0
+	return filepath.Base(path), string(b), nil
+}
+
+// checkInput parses the input without running it, printing the errors found and
+// returning the exit code of the process. Only the lexical and syntax errors are
+// found, names are not resolved and the lint checks are left to lintInput.
+func checkInput(name, input string) int {
+	return checkInputOptions(name, input, lang.DefaultParseOptions())
 }
 
-// func interpreterMode() {
-// REVIEW: Make a mode that runs line-by-line interpretation in a manner similar
-// to Python IDLE or javascript consoles for browsers
+// checkInputOptions is checkInput, parsing with the options given
+func checkInputOptions(name, input string, opts lang.ParseOptions) int {
+	if _, err := lang.ParseWithOptions(name, input, opts); err != nil {
+		errPrinter.printError(input, err)
+		return exitSyntax
+	}
+	return exitOK
+}
 
-// }
+// lintInput parses the input and prints the issues reported by the lint checks,
+// returning the exit code of the process, the issues that are only warnings do
+// not fail the process unless -Werror is set
+func lintInput(name, input string) int {
+	f, err := lang.ParseFile(name, input)
+	if err != nil {
+		errPrinter.printError(input, err)
+		return exitSyntax
+	}
+	diags := lang.NewDiagnostics(input)
+	diags.AddLint(name, lang.Lint(f))
+	warnPrinter.printDiagnostics(diags)
+	if diags.HasErrors() || werror && diags.Len() > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
 
-// parseInput takes in the string input and runs the language
-func parseInput(name, input string) {
+// printAST parses the input and prints its AST in s-expression form, returning
+// the exit code of the process
+func printAST(name, input string) int {
+	f, err := lang.ParseFile(name, input)
+	if err != nil {
+		errPrinter.printError(input, err)
+		return exitSyntax
+	}
+	fmt.Println(new(lang.AstPrinter).Print(f))
+	return exitOK
+}
+
+// printASTJSON parses the input and prints its AST in JSON form, returning the
+// exit code of the process
+func printASTJSON(name, input string) int {
+	f, err := lang.ParseFile(name, input)
+	if err != nil {
+		errPrinter.printError(input, err)
+		return exitSyntax
+	}
+	b, err := lang.MarshalJSONIndent(f, "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitSoftware
+	}
+	fmt.Println(string(b))
+	return exitOK
+}
+
+// printTokens prints the type, value and position of every token of the input,
+// including comments, returning the exit code of the process
+func printTokens(name, input string) int {
+	tkns, errs := token.TokeniseAll(name, input, token.ScanComments)
+	for _, tkn := range tkns {
+		if tkn.Type == token.EOF {
+			fmt.Printf("%s\t%s\n", tkn.Pos, tkn.Type)
+			break
+		}
+		fmt.Printf("%s\t%s\t%q\n", tkn.Pos, tkn.Type, tkn.Value)
+	}
+	if len(errs) > 0 {
+		errPrinter.printError(input, errs)
+		return exitSyntax
+	}
+	return exitOK
+}
+
+// scriptEnv returns the global environment of a script, binding the script
+// arguments to the global list "args"
+func scriptEnv(args []string) lang.Environment {
+	wargs := lang.WList{}
+	for _, arg := range args {
+		wargs = append(wargs, lang.WString(arg))
+	}
+	return lang.Environment{scriptArgsName: wargs}
+}
+
+// interpretInput takes in the string input and runs the language with the given
+// interpreter settings, returning the exit code of the process
+// TODO: once the bytecode VM lands, cache the compiled output next to the
+// script (e.g. "script.wentc"), keyed by a hash of the input, and load it
+// instead of parsing on later runs. Caching the AST alone is not worth it as
+// decoding it costs about as much as parsing the input.
+func interpretInput(ctx context.Context, name, input string, cfg lang.Config) int {
 	p, errp := lang.Parse(name, input)
 	if errp != nil {
-		log.Fatal(errp)
+		errPrinter.printError(input, errp)
+		return exitSyntax
+	}
+	if _, erri := lang.InterpretContext(ctx, p.Root, cfg); erri != nil {
+		errPrinter.printError(input, erri)
+		return exitSoftware
+	}
+	return exitOK
+}
+
+// evalInput evaluates a one-liner given on the command line and prints its result,
+// returning the exit code of the process
+func evalInput(input string) int {
+	p, err := lang.Parse("<expr>", input)
+	if err != nil {
+		errPrinter.printError(input, err)
+		return exitSyntax
+	}
+	i, err := lang.Interpret(p.Root)
+	if err != nil {
+		errPrinter.printError(input, err)
+		return exitSoftware
 	}
-	_, erri := lang.Interpret(p.Root)
-	if erri != nil {
-		log.Fatal(erri)
+	if i.Result != nil {
+		fmt.Println(i.Result)
 	}
+	return exitOK
 }
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lohvht/went/lang"
+)
+
+// mainEnv is set in the environment of the test binary when it is run as the
+// went command by runWent
+const mainEnv = "WENT_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(mainEnv) == "1" {
+		os.Exit(Run())
+	}
+	os.Exit(m.Run())
+}
+
+// runWent runs the test binary as the went command with the arguments and the
+// input on stdin, returning its exit code, stdout and stderr
+func runWent(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	c := exec.Command(os.Args[0], args...)
+	c.Env = append(os.Environ(), mainEnv+"=1", "NO_COLOR=1")
+	c.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	c.Stdout, c.Stderr = &out, &errOut
+	if err := c.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatal(err)
+		}
+		code = exitErr.ExitCode()
+	}
+	return code, out.String(), errOut.String()
+}
+
+// writeScript writes the script to a file of the given name in a temporary
+// directory, returning its path
+func writeScript(t *testing.T, name, script string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "went")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCLI(t *testing.T) {
+	ok := writeScript(t, "ok.went", "x = 1\nprint(x + 2)\nx\n")
+	syntax := writeScript(t, "syntax.went", "x = = 1\n")
+	runtime := writeScript(t, "runtime.went", "x = 1 / 0\n")
+	lint := writeScript(t, "lint.went", "x = 1\ny = x == x\n")
+	tests := writeScript(t, "funcs_test.went", "func test_ok() { print('ok') }\nfunc test_fail() { 1 / 0 }\nfunc helper() { 1 / 0 }\n")
+	doc := writeScript(t, "doc.went", "// Module doc.\n\nx = 1 // not a doc\n// Double returns twice a.\n//\n// It is pure.\nfunc double(a) { return a * 2 }\n\n/* Bare does nothing. */\nfunc bare(a, b) {}\nfunc undocumented() {}\n")
+	debug := writeScript(t, "debug.went", "a = 0\nfunc f(a) {\n\tb = a * 2\n\treturn b\n}\nc = f(1)\n")
+	for _, tc := range []struct {
+		name           string
+		stdin          string
+		args           []string
+		code           int
+		stdout, stderr string // expected to be contained in the output
+	}{
+		{"run", "", []string{ok}, exitOK, "3", ""},
+		{"run command", "", []string{"run", ok}, exitOK, "3", ""},
+		{"stdin", "print(1 + 1)", []string{"-"}, exitOK, "2", ""},
+		{"eval", "", []string{"-e", "1 + 2 * 3"}, exitOK, "7", ""},
+		{"eval error", "", []string{"-e", "y"}, exitSoftware, "", "NameError - name 'y' is not defined"},
+		{"check", "", []string{"-check", ok}, exitOK, "", ""},
+		{"check syntax error", "", []string{"-check", syntax}, exitSyntax, "", "syntax.went:1:5: SyntaxError"},
+		{"check command", "", []string{"check", syntax}, exitSyntax, "", "SyntaxError"},
+		{"syntax error", "", []string{syntax}, exitSyntax, "", "SyntaxError"},
+		{"runtime error", "", []string{runtime}, exitSoftware, "", "runtime.went:1:5: ZeroDivisionError"},
+		{"json errors", "", []string{"-errors=json", runtime}, exitSoftware, "",
+			`"line":1,"col":5,"end":{"line":1,"col":10},"code":"W3005"`},
+		{"json syntax errors", "", []string{"-errors=json", syntax}, exitSyntax, "",
+			`"line":1,"col":5,"end":{"line":1,"col":6},"code":"W2003"`},
+		{"missing file", "", []string{filepath.Join(filepath.Dir(ok), "missing.went")}, exitNoInput, "", "missing.went"},
+		{"unknown flag", "", []string{"-nope"}, exitUsage, "", "flag provided but not defined"},
+		{"unknown errors format", "", []string{"-errors=xml", ok}, exitUsage, "", "unknown -errors format"},
+		{"lint warnings", "", []string{"lint", lint}, exitOK, "L001", ""},
+		{"lint -Werror", "", []string{"-Werror", "lint", lint}, exitFailure, "L001", ""},
+		{"test functions", "", []string{"test", "-v", tests}, exitFailure, "1 passed, 1 failed", ""},
+		{"test function failure", "", []string{"test", tests}, exitFailure, "--- FAIL: " + tests + ":test_fail", ""},
+		{"test function pass", "", []string{"test", "-v", tests}, exitFailure, "--- PASS: " + tests + ":test_ok", ""},
+		{"test file", "", []string{"test", ok}, exitOK, "", ""},
+		{"test coverage", "", []string{"test", "-cover", tests}, exitFailure, "", tests + ":\tcoverage: 83.3% of statements (5/6)\n"},
+		{"run coverage", "", []string{"run", "-cover", debug}, exitOK, "", "coverage: 100.0% of statements (5/5)\n"},
+		{"doc", "", []string{"doc", doc}, exitOK, "module doc\n\n    Module doc.\n\n" +
+			"func double(a)\n    Double returns twice a.\n\n    It is pure.\n\nfunc bare(a, b)\n    Bare does nothing.\n\nfunc undocumented()\n", ""},
+		{"doc syntax error", "", []string{"doc", syntax}, exitSyntax, "module", "SyntaxError"},
+		{"profile", "", []string{"run", "-profile", debug}, exitOK, "", "%  (top level)\n"},
+		{"profile functions", "", []string{"run", "-profile", debug}, exitOK, "", "%  f\n"},
+		{"debug vars", "break 4\nrun\nvars\nquit\n", []string{"debug", debug}, exitOK, "a = 1\nargs = []\nb = 2\nf = ", ""},
+		{"debug quit", "step\nquit\n", []string{"debug", debug}, exitOK, "stopped at", ""},
+	} {
+		code, stdout, stderr := runWent(t, tc.stdin, tc.args...)
+		if code != tc.code {
+			t.Errorf("%s: got exit code %d, expected %d\nstdout: %s\nstderr: %s", tc.name, code, tc.code, stdout, stderr)
+		}
+		if !strings.Contains(stdout, tc.stdout) {
+			t.Errorf("%s: got stdout %q, expected it to contain %q", tc.name, stdout, tc.stdout)
+		}
+		if !strings.Contains(stderr, tc.stderr) || tc.stderr == "" && stderr != "" {
+			t.Errorf("%s: got stderr %q, expected it to contain %q", tc.name, stderr, tc.stderr)
+		}
+	}
+	// only the output of the script is written, not the value of its last
+	// statement
+	if _, stdout, _ := runWent(t, "", ok); stdout != "3\n" {
+		t.Errorf("run: got stdout %q, expected %q", stdout, "3\n")
+	}
+}
+
+func TestProfileFuncs(t *testing.T) {
+	// g is defined at 1:1 but only the calls of g and of the literal are
+	// attributed to them, not their definitions nor the root of the script
+	f, err := lang.ParseFile("profile", "func g(x) { return [y for y in x] }\nh = func() { return g([1]) }\nh()\nfunc unused() {}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProfiler()
+	if _, err := lang.InterpretContext(context.Background(), f, lang.Config{Hook: p.hook}); err != nil {
+		t.Fatal(err)
+	}
+	p.stop()
+	var names []string
+	for _, fp := range p.funcs {
+		names = append(names, fp.name)
+	}
+	sort.Strings(names)
+	if expected := []string{"func literal at 2:5", "g"}; strings.Join(names, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("got functions %q, expected %q", names, expected)
+	}
+}
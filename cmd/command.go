@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lohvht/went/lang"
+)
+
+// command is a subcommand of the went command line, e.g. "went run"
+type command struct {
+	name  string // name used to invoke the command
+	args  string // arguments of the command, shown in its usage
+	short string // short description of the command, shown by "went help"
+	// run runs the command with the arguments following its name, returning
+	// the exit code of the process
+	run func(cmd *command, args []string) int
+}
+
+// commands holds all the subcommands in the order that they are listed by
+// "went help", new commands should be registered here
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"run", "[-profile] [-cover] [-coverhtml file] [file | -] [arguments...]", "run a script, or start the interpreter if no script is given", runCmd},
+		{"repl", "", "start the interactive interpreter", replCmd},
+		{"eval", "<expr>", "evaluate an expression and print its result", evalCmd},
+		{"check", "[-e] [-strict] <file>...", "check scripts for errors without running them", checkCmd},
+		{"lint", "<file>...", "report suspicious constructs in scripts", lintCmd},
+		{"debug", "<file> [arguments...]", "run a script under the interactive debugger", debugCmd},
+		{"test", "[-v] [-cover] [-coverhtml file] [file | directory]...", "run the *_test.went scripts found at the paths and their test_* functions", testCmd},
+		{"doc", "<file>", "print the documentation of a script and its functions", docCmd},
+		{"lsp", "", "run the language server over stdio", lspCmd},
+		{"ast", "[-json] <file>", "print the AST of a script", astCmd},
+		{"tokens", "<file>", "print the tokens of a script", tokensCmd},
+		{"help", "[command]", "show help for a command", helpCmd},
+	}
+}
+
+// lookupCommand returns the registered command with the given name
+func lookupCommand(name string) (*command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// usage prints the usage line of the command
+func (c *command) usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: went %s %s\n", c.name, c.args)
+}
+
+// flagSet returns an empty flag set for the command that prints the command's
+// usage on errors
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		c.usage(fs.Output())
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the arguments of the command with its flag set, returning
+// false and the exit code of the process if the command should not continue
+func (c *command) parseFlags(fs *flag.FlagSet, args []string) (ok bool, code int) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return false, exitOK
+		}
+		return false, exitUsage
+	}
+	return true, exitOK
+}
+
+// printCommands prints the list of registered commands
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "usage: went [-no-color] [-errors=text|json] [-Werror] <command> [arguments]\n       went [flags] [file | -] [arguments...]")
+	fmt.Fprintln(w, "\nThe commands are:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.short)
+	}
+	fmt.Fprintln(w, "\nUse \"went help <command>\" for more information about a command.")
+}
+
+// forEachScript passes each of the scripts at paths to fn, returning the exit
+// code of the last script that failed
+func forEachScript(paths []string, fn func(name, input string) int) int {
+	code := exitOK
+	for _, path := range paths {
+		if scriptCode := withScript(path, fn); scriptCode != exitOK {
+			code = scriptCode
+		}
+	}
+	return code
+}
+
+// Commands
+
+func runCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	profile := fs.Bool("profile", false, "print the time spent on each line and in each function of the script to stderr")
+	cover := fs.Bool("cover", false, "print the statement coverage of the script to stderr")
+	coverHTML := fs.String("coverhtml", "", "write an HTML report of the statement coverage of the script to the `file`")
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		return interpreterMode()
+	}
+	return runScript(fs.Arg(0), fs.Args()[1:], runOptions{profile: *profile, cover: *cover, coverHTML: *coverHTML})
+}
+
+func replCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	return interpreterMode()
+}
+
+func evalCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+	return evalInput(strings.Join(fs.Args(), " "))
+}
+
+func checkCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	all := fs.Bool("e", false, "report all errors, same as -maxerrors 0")
+	maxErrors := fs.Int("maxerrors", lang.DefaultMaxErrors, "stop after this many errors, no limit if 0")
+	strict := fs.Bool("strict", false, "report legacy forms, such as octals with a leading zero")
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+	opts := lang.DefaultParseOptions()
+	opts.MaxErrors = *maxErrors
+	if *all {
+		opts.MaxErrors = 0
+	}
+	opts.Strict = *strict
+	return forEachScript(fs.Args(), func(name, input string) int {
+		return checkInputOptions(name, input, opts)
+	})
+}
+
+func lintCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+	return forEachScript(fs.Args(), lintInput)
+}
+
+func astCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	asJSON := fs.Bool("json", false, "print the AST as JSON instead of s-expressions")
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+	if *asJSON {
+		return withScript(fs.Arg(0), printASTJSON)
+	}
+	return withScript(fs.Arg(0), printAST)
+}
+
+func tokensCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+	return withScript(fs.Arg(0), printTokens)
+}
+
+func helpCmd(c *command, args []string) int {
+	if len(args) == 0 {
+		printCommands(os.Stdout)
+		return exitOK
+	}
+	hc, ok := lookupCommand(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		printCommands(os.Stderr)
+		return exitUsage
+	}
+	hc.usage(os.Stdout)
+	fmt.Printf("\n%s\n", hc.short)
+	return exitOK
+}
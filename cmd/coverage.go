@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lohvht/went/lang"
+)
+
+// fileCoverage records the number of times each statement of a script was
+// executed
+type fileCoverage struct {
+	name  string
+	input string
+	stmts map[lang.Stmt]int // hits of each statement, nil until the script runs
+}
+
+// hook is the interpreter hook that records the executed statements. The
+// statements are those of the AST the script is run from, blocks excepted as
+// only the statements they hold are executed. The nodes of the other ASTs run
+// with the hook, e.g. the calls of the test functions, are not recorded.
+func (fc *fileCoverage) hook(i *lang.Interpreter, node lang.Node) {
+	if fc.stmts == nil {
+		fc.stmts = map[lang.Stmt]int{}
+		lang.Inspect(i.Root, func(n lang.Node) bool {
+			if s, ok := n.(lang.Stmt); ok {
+				if _, ok := s.(*lang.BlockStmt); !ok {
+					fc.stmts[s] = 0
+				}
+			}
+			return true
+		})
+	}
+	if s, ok := node.(lang.Stmt); ok {
+		if _, ok := fc.stmts[s]; ok {
+			fc.stmts[s]++
+		}
+	}
+}
+
+// covered returns the number of statements that were executed, and the number
+// of statements
+func (fc *fileCoverage) covered() (covered, total int) {
+	for _, hits := range fc.stmts {
+		if hits > 0 {
+			covered++
+		}
+	}
+	return covered, len(fc.stmts)
+}
+
+// lines returns the hits of each line that a statement starts on, the least
+// hits of the statements starting on the line so that a line is only covered
+// if all of its statements were executed
+func (fc *fileCoverage) lines() map[int]int {
+	lines := map[int]int{}
+	for s, hits := range fc.stmts {
+		line := s.Pos().Line()
+		if prev, ok := lines[line]; !ok || hits < prev {
+			lines[line] = hits
+		}
+	}
+	return lines
+}
+
+// coverage records the coverage of the scripts of a run
+type coverage struct {
+	files []*fileCoverage
+}
+
+// add starts recording the coverage of a script, returning the hook that
+// records it
+func (c *coverage) add(name, input string) lang.Hook {
+	fc := &fileCoverage{name: name, input: input}
+	c.files = append(c.files, fc)
+	return fc.hook
+}
+
+// report writes the coverage summary of each script followed by the total
+func (c *coverage) report(w io.Writer) {
+	var covered, total int
+	for _, fc := range c.files {
+		fcCovered, fcTotal := fc.covered()
+		covered, total = covered+fcCovered, total+fcTotal
+		fmt.Fprintf(w, "%s:\t%s\n", fc.name, coverageSummary(fcCovered, fcTotal))
+	}
+	if len(c.files) > 1 {
+		fmt.Fprintf(w, "total:\t%s\n", coverageSummary(covered, total))
+	}
+}
+
+func coverageSummary(covered, total int) string {
+	percent := 100.0
+	if total > 0 {
+		percent = 100 * float64(covered) / float64(total)
+	}
+	return fmt.Sprintf("coverage: %.1f%% of statements (%d/%d)", percent, covered, total)
+}
+
+// coverageLine is a line of the annotated source in the HTML report
+type coverageLine struct {
+	Num   int
+	Text  string
+	Class string // "covered", "uncovered" or "" if no statement starts on the line
+	Hits  int
+}
+
+// coverageFile is a script in the HTML report
+type coverageFile struct {
+	Name    string
+	Summary string
+	Lines   []coverageLine
+}
+
+// writeHTML writes the HTML report holding the source of the scripts, annotated
+// with the lines whose statements were executed
+func (c *coverage) writeHTML(w io.Writer) error {
+	files := make([]coverageFile, 0, len(c.files))
+	for _, fc := range c.files {
+		f := coverageFile{Name: fc.name, Summary: coverageSummary(fc.covered())}
+		lines := fc.lines()
+		for i, text := range strings.Split(fc.input, "\n") {
+			line := coverageLine{Num: i + 1, Text: text}
+			if hits, ok := lines[line.Num]; ok {
+				line.Hits, line.Class = hits, "uncovered"
+				if hits > 0 {
+					line.Class = "covered"
+				}
+			}
+			f.Lines = append(f.Lines, line)
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return coverageTemplate.Execute(w, files)
+}
+
+// writeHTMLFile writes the HTML report to the file at path
+func (c *coverage) writeHTMLFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.writeHTML(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>went coverage</title>
+<style>
+body { font-family: monospace; }
+table { border-collapse: collapse; }
+td { padding: 0 8px; white-space: pre; }
+td.num, td.hits { color: #888; text-align: right; }
+tr.covered td.src { background: #dfd; }
+tr.uncovered td.src { background: #fdd; }
+</style>
+</head>
+<body>
+{{range .}}<h2>{{.Name}}</h2>
+<p>{{.Summary}}</p>
+<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="num">{{.Num}}</td><td class="hits">{{if .Class}}{{.Hits}}{{end}}</td><td class="src">{{.Text}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lohvht/went/lang"
+)
+
+const debugPrompt = "(wdb) " // prompt shown while the debugger waits for a command
+
+// stepMode decides where the debugger stops next
+type stepMode int
+
+const (
+	modeContinue stepMode = iota // stop at the next breakpoint
+	modeStep                     // stop before the next node is evaluated
+	modeNext                     // stop at the next node on a different line
+)
+
+// debugger is an interactive debugger that stops the interpretation at
+// breakpoints and allows stepping through the evaluation of a script
+type debugger struct {
+	name        string // name of the script being debugged
+	input       *bufio.Scanner
+	breakpoints map[int]bool // lines at which the interpretation stops
+	mode        stepMode
+	lastLine    int                // line of the last node evaluated
+	cancel      context.CancelFunc // stops the interpretation when quitting
+	quit        bool
+}
+
+// hook is the interpreter hook that stops the interpretation when needed
+func (d *debugger) hook(i *lang.Interpreter, node lang.Node) {
+	if d.quit {
+		return
+	}
+	line := node.Pos().Line()
+	var stop bool
+	switch d.mode {
+	case modeStep:
+		stop = true
+	case modeNext:
+		stop = line != d.lastLine
+	case modeContinue:
+		stop = d.breakpoints[line] && line != d.lastLine
+	}
+	d.lastLine = line
+	if stop {
+		fmt.Printf("stopped at %s:%s: %s\n", d.name, node.Pos(), new(lang.AstPrinter).Print(node))
+		d.prompt(i)
+	}
+}
+
+// prompt reads and runs debugger commands until one of them resumes the
+// interpretation, i is nil if the interpretation has not started
+func (d *debugger) prompt(i *lang.Interpreter) {
+	for {
+		fmt.Print(debugPrompt)
+		if !d.input.Scan() {
+			d.stop()
+			return
+		}
+		fields := strings.Fields(d.input.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var arg string
+		if len(fields) > 1 {
+			arg = fields[1]
+		}
+		switch fields[0] {
+		case "s", "step":
+			d.mode = modeStep
+			return
+		case "n", "next":
+			d.mode = modeNext
+			return
+		case "c", "continue", "r", "run":
+			d.mode = modeContinue
+			return
+		case "b", "break", "clear":
+			line, err := strconv.Atoi(arg)
+			if err != nil || line < 1 {
+				fmt.Printf("usage: %s <line>\n", fields[0])
+				continue
+			}
+			d.breakpoints[line] = fields[0] != "clear"
+		case "bt", "stack":
+			d.printStack(i)
+		case "p", "print":
+			if i == nil {
+				fmt.Println("the script is not running")
+			} else if v, ok := i.Lookup(arg); ok {
+				fmt.Println(v)
+			} else {
+				fmt.Printf("name '%s' is not defined\n", arg)
+			}
+		case "vars":
+			d.printVars(i)
+		case "q", "quit":
+			d.stop()
+			return
+		case "h", "help":
+			fmt.Println(debugHelp)
+		default:
+			fmt.Printf("unknown command %q, type help for a list of commands\n", fields[0])
+		}
+	}
+}
+
+const debugHelp = `commands:
+  step, s          stop at the next expression to be evaluated
+  next, n          stop at the next line
+  continue, c      run until the next breakpoint (also run, r)
+  break, b <line>  set a breakpoint at the line
+  clear <line>     remove the breakpoint at the line
+  stack, bt        print the expressions being evaluated, innermost first
+  print, p <name>  print the value bound to the name
+  vars             list the names bound in the current and enclosing scopes
+  quit, q          stop debugging`
+
+// stop ends the debugging session, cancelling the interpretation
+func (d *debugger) stop() {
+	d.quit = true
+	d.cancel()
+}
+
+func (d *debugger) printStack(i *lang.Interpreter) {
+	if i == nil {
+		fmt.Println("the script is not running")
+		return
+	}
+	stack := i.Stack()
+	ap := &lang.AstPrinter{}
+	for k := len(stack) - 1; k >= 0; k-- {
+		fmt.Printf("#%d %s:%s: %s\n", len(stack)-1-k, d.name, stack[k].Pos(), ap.Print(stack[k]))
+	}
+}
+
+// printVars lists the names bound in the current scope and the scopes
+// enclosing it, a name shadowed by an inner scope is listed once, with the
+// value it is bound to in the innermost scope
+func (d *debugger) printVars(i *lang.Interpreter) {
+	if i == nil {
+		fmt.Println("the script is not running")
+		return
+	}
+	vars := map[string]lang.WType{}
+	for _, scope := range i.Scopes() {
+		for name, v := range scope {
+			if _, ok := vars[name]; !ok {
+				vars[name] = v
+			}
+		}
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s = %s\n", name, vars[name])
+	}
+}
+
+// debugInput runs the script under the debugger, returning the exit code of the
+// process
+func debugInput(name, input string, args []string) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &debugger{
+		name:        name,
+		input:       bufio.NewScanner(os.Stdin),
+		breakpoints: map[int]bool{},
+		cancel:      cancel,
+	}
+	fmt.Println("set breakpoints with \"break <line>\", then \"run\" or \"step\"; type help for all commands")
+	d.prompt(nil)
+	if d.quit {
+		return exitOK
+	}
+	p, errp := lang.Parse(name, input)
+	if errp != nil {
+		errPrinter.printError(input, errp)
+		return exitSyntax
+	}
+	_, erri := lang.InterpretContext(ctx, p.Root, lang.Config{Env: scriptEnv(args), Hook: d.hook})
+	if d.quit {
+		// the interpretation was cancelled on purpose, it is not an error
+		return exitOK
+	}
+	if erri != nil {
+		errPrinter.printError(input, erri)
+		return exitSoftware
+	}
+	return exitOK
+}
+
+func debugCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+	scriptArgs := fs.Args()[1:]
+	return withScript(fs.Arg(0), func(name, input string) int {
+		return debugInput(name, input, scriptArgs)
+	})
+}
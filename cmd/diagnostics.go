@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lohvht/went/lang"
+	"github.com/lohvht/went/lang/token"
+)
+
+// ANSI escape sequences used to color the diagnostics
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// Flags accepted before the command, e.g. "went -no-color run script.went",
+// that set how the diagnostics are printed
+const (
+	noColorFlag = "no-color" // disables the colors
+	errorsFlag  = "errors"   // format of the diagnostics, "text" or "json"
+	werrorFlag  = "Werror"   // promotes the warnings to errors
+)
+
+// Formats of the diagnostics
+const (
+	formatText = "text" // human readable, with the line of the input under each
+	formatJSON = "json" // one JSON object per line
+)
+
+// jsonDiagnostic is the JSON form of a diagnostic, the positions are zero if
+// they are not known
+type jsonDiagnostic struct {
+	File     string     `json:"file"`
+	Line     int        `json:"line"`
+	Col      int        `json:"col"`
+	End      jsonPos    `json:"end"`
+	Code     string     `json:"code"`
+	Severity string     `json:"severity"`
+	Message  string     `json:"message"`
+	Notes    []jsonNote `json:"notes,omitempty"`
+}
+
+type jsonPos struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+type jsonNote struct {
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Message string `json:"message"`
+}
+
+// diagnosticPrinter prints the errors and warnings of the commands and the
+// REPL. As text, the line of the input is printed with a caret under the
+// position of each, in color positions are cyan, errors red and warnings
+// yellow.
+type diagnosticPrinter struct {
+	w      io.Writer
+	color  bool
+	format string
+}
+
+// Printers shared by all commands and the REPL, errors are printed to stderr
+// while warnings, e.g. lint issues, are the output of a command
+var (
+	errPrinter  = newDiagnosticPrinter(os.Stderr)
+	warnPrinter = newDiagnosticPrinter(os.Stdout)
+)
+
+// newDiagnosticPrinter returns a text printer to the file, in color if the
+// file is a terminal and the NO_COLOR environment variable is not set
+func newDiagnosticPrinter(f *os.File) *diagnosticPrinter {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &diagnosticPrinter{w: f, color: !noColor && isTerminal(f), format: formatText}
+}
+
+// isTerminal reports whether the file is a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// disableColor turns off the colors of all the printers
+func disableColor() { errPrinter.color, warnPrinter.color = false, false }
+
+// werror is set by the -Werror flag, the warnings are then printed as errors
+// and fail the commands, e.g. for strict CI runs
+var werror bool
+
+// severity returns the severity of a diagnostic, promoting the warnings to
+// errors if -Werror is set
+func severity(s token.Severity) token.Severity {
+	if werror && s == token.SeverityWarning {
+		return token.SeverityError
+	}
+	return s
+}
+
+// setFormat sets the format of all the printers, reporting an unknown format
+func setFormat(format string) bool {
+	if format != formatText && format != formatJSON {
+		fmt.Fprintf(os.Stderr, "unknown -%s format %q, expected %q or %q\n", errorsFlag, format, formatText, formatJSON)
+		return false
+	}
+	errPrinter.format, warnPrinter.format = format, format
+	return true
+}
+
+// stripGlobalFlags removes the flags accepted before the command from args and
+// applies them, it returns false if a flag is invalid
+func stripGlobalFlags(args []string) ([]string, bool) {
+	for ; len(args) > 0; args = args[1:] {
+		name := strings.TrimPrefix(strings.TrimPrefix(args[0], "-"), "-")
+		switch {
+		case !strings.HasPrefix(args[0], "-"):
+			return args, true
+		case name == noColorFlag:
+			disableColor()
+		case name == werrorFlag:
+			werror = true
+		case strings.HasPrefix(name, errorsFlag+"="):
+			if !setFormat(strings.TrimPrefix(name, errorsFlag+"=")) {
+				return nil, false
+			}
+		case name == errorsFlag && len(args) > 1:
+			if !setFormat(args[1]) {
+				return nil, false
+			}
+			args = args[1:]
+		default:
+			return args, true
+		}
+	}
+	return args, true
+}
+
+// paint returns s in the color if colors are enabled
+func (d *diagnosticPrinter) paint(color, s string) string {
+	if !d.color || s == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// printError prints the diagnostics of the error returned by a phase, see
+// lang.Diagnostics.Add
+func (d *diagnosticPrinter) printError(input string, err error) {
+	diags := lang.NewDiagnostics(input)
+	diags.Add(err)
+	d.printDiagnostics(diags)
+}
+
+// printDiagnostics prints the diagnostics in the order they were found, the
+// warnings are printed as errors if -Werror is set
+func (d *diagnosticPrinter) printDiagnostics(diags *lang.Diagnostics) {
+	for _, diag := range diags.List() {
+		d.print(diag)
+	}
+}
+
+// print prints the diagnostic in the format of the printer
+func (d *diagnosticPrinter) print(diag *lang.Diagnostic) {
+	sev := severity(diag.Severity)
+	if d.format == formatJSON {
+		d.printJSON(diag, sev)
+		return
+	}
+	color := ansiRed
+	if sev == token.SeverityWarning {
+		color = ansiYellow
+	}
+	if diag.Name == "" {
+		fmt.Fprintln(d.w, d.paint(color, diag.Msg))
+		return
+	}
+	label := ""
+	if diag.Kind != "" {
+		label = diag.Kind + " - "
+	}
+	fmt.Fprintf(d.w, "%s %s%s\n", d.paint(ansiCyan, fmt.Sprintf("%s:%s:", diag.Name, diag.Pos)),
+		d.paint(color, label), diag.Msg)
+	d.snippet(color, diag.Line, diag.Pos)
+	for _, n := range diag.Notes {
+		fmt.Fprintf(d.w, "%s note - %s\n", d.paint(ansiCyan, fmt.Sprintf("%s:%s:", diag.Name, n.Pos)), n.Msg)
+		d.snippet(ansiCyan, n.Line, n.Pos)
+	}
+}
+
+// printJSON prints the diagnostic as a JSON object of the severity on a single
+// line
+func (d *diagnosticPrinter) printJSON(diag *lang.Diagnostic, sev token.Severity) {
+	jd := jsonDiagnostic{File: diag.Name, Line: diag.Pos.Line(), Col: diag.Pos.Col(),
+		End: jsonPos{diag.End.Line(), diag.End.Col()}, Code: diag.Code, Severity: sev.String(),
+		Message: diag.Msg}
+	for _, n := range diag.Notes {
+		jd.Notes = append(jd.Notes, jsonNote{n.Pos.Line(), n.Pos.Col(), n.Msg})
+	}
+	b, err := json.Marshal(jd)
+	if err != nil {
+		panic(err) // the diagnostic only holds strings and numbers
+	}
+	fmt.Fprintln(d.w, string(b))
+}
+
+// snippet prints the line with a caret in the color under the position, if the
+// line is not blank
+func (d *diagnosticPrinter) snippet(color, line string, pos token.Pos) {
+	snippet := token.Snippet(line, pos.Col())
+	if snippet == "" {
+		return
+	}
+	i := strings.LastIndexByte(snippet, '\n')
+	fmt.Fprintf(d.w, "%s\n%s%s\n", snippet[:i], snippet[i+1:len(snippet)-1], d.paint(color, "^"))
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lohvht/went/lang"
+	"github.com/lohvht/went/lang/token"
+)
+
+// moduleDoc extracts the documentation of a module, that is the block of line
+// comments ("//") or the block comment ("/* */") at the top of the script
+func moduleDoc(input string) string {
+	lines := strings.Split(strings.TrimPrefix(input, "\uFEFF"), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "#!") {
+		lines = lines[1:] // skip the shebang
+	}
+	// skip the leading blank lines
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return commentText(lines)
+}
+
+// commentText returns the text of the block of line comments or the block
+// comment that the lines start with, without the comment markers
+func commentText(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	var doc []string
+	if first := strings.TrimSpace(lines[0]); strings.HasPrefix(first, "/*") {
+		for i, line := range lines {
+			line = strings.TrimSpace(line)
+			if i == 0 {
+				line = strings.TrimPrefix(line, "/*")
+			}
+			end := strings.Index(line, "*/")
+			if end >= 0 {
+				line = line[:end]
+			}
+			if i != 0 {
+				// strip the decorative '*' that block comment lines often start with
+				line = strings.TrimPrefix(strings.TrimPrefix(line, "*"), " ")
+			}
+			doc = append(doc, strings.TrimSpace(line))
+			if end >= 0 {
+				break
+			}
+		}
+	} else {
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "//") {
+				break
+			}
+			doc = append(doc, strings.TrimPrefix(strings.TrimPrefix(line, "//"), " "))
+		}
+	}
+	return strings.TrimSpace(strings.Join(doc, "\n"))
+}
+
+// funcDoc is the documentation of a function declared at the top level
+type funcDoc struct {
+	signature string // e.g. "func f(a, b)"
+	doc       string
+}
+
+// funcDocs extracts the documentation of the functions declared at the top
+// level of the script, in the order of the source. The doc of a function is
+// the comment, or the block of comments, on the lines right above its
+// declaration, a comment that follows code on its line is not part of it.
+func funcDocs(f *lang.File, input string) []funcDoc {
+	lines := strings.Split(input, "\n")
+	// ownLine reports whether the comment is the first thing on its line
+	ownLine := func(c token.Token) bool {
+		line := lines[c.Pos.Line()-1]
+		return c.Pos.Col()-1 <= len(line) && strings.TrimSpace(line[:c.Pos.Col()-1]) == ""
+	}
+	var docs []funcDoc
+	for _, stmt := range f.Stmts() {
+		decl, ok := stmt.(*lang.FuncDecl)
+		if !ok {
+			continue
+		}
+		params := make([]string, len(decl.Params()))
+		for k, param := range decl.Params() {
+			params[k] = param.Name
+		}
+		d := funcDoc{signature: fmt.Sprintf("func %s(%s)", decl.Name().Name, strings.Join(params, ", "))}
+		// walk back from the last comment before the declaration while the
+		// comments are on consecutive lines
+		line := decl.Pos().Line()
+		first := -1
+		for k := len(f.Comments) - 1; k >= 0; k-- {
+			c := f.Comments[k]
+			if c.Pos >= decl.Pos() {
+				continue
+			}
+			if c.End.Line() != line-1 || !ownLine(c) {
+				break
+			}
+			first, line = k, c.Pos.Line()
+		}
+		if first >= 0 {
+			d.doc = commentText(lines[line-1 : decl.Pos().Line()-1])
+		}
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+// printIndented prints the text indented by four spaces, leaving blank lines
+// unindented
+func printIndented(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			fmt.Println()
+			continue
+		}
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// printDoc prints the documentation of the script and of its functions,
+// returning the exit code of the process
+func printDoc(name, input string) int {
+	fmt.Printf("module %s\n", strings.TrimSuffix(name, ".went"))
+	if doc := moduleDoc(input); doc != "" {
+		fmt.Println()
+		printIndented(doc)
+	}
+	p, err := lang.Parse(name, input)
+	if err != nil {
+		errPrinter.printError(input, err)
+		return exitSyntax
+	}
+	for _, d := range funcDocs(p.Root.(*lang.File), input) {
+		fmt.Println()
+		fmt.Println(d.signature)
+		if d.doc != "" {
+			printIndented(d.doc)
+		}
+	}
+	return exitOK
+}
+
+func docCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		c.usage(os.Stderr)
+		return exitUsage
+	}
+	return withScript(fs.Arg(0), printDoc)
+}
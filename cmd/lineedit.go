@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// lineScanner reads the lines of input of a REPL session, it is implemented by
+// bufio.Scanner and by lineEditor
+type lineScanner interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
+
+// Keys handled by the line editor
+const (
+	keyCtrlD     = 4
+	keyBackspace = '\b'
+	keyDelete    = 127
+	keyEscape    = 27
+)
+
+// lineEditor reads lines from a terminal in non-canonical mode, echoing the
+// keys itself so that the left and right arrow keys move within the line and
+// the up and down arrow keys recall the lines of the history
+type lineEditor struct {
+	in   *bufio.Reader
+	out  io.Writer
+	hist *history
+	// discarded reports whether the input was interrupted since the line was
+	// started, the line is then dropped as the prompt has been shown anew
+	discarded func() bool
+	line      string
+	pending   string // line that the next line starts with, e.g. after a completion
+	err       error
+}
+
+// Scan reads the next line, returning false at the end of the input or if
+// Ctrl-D is pressed on an empty line. Pressing tab ends the line with a tab
+// character, which the REPL answers with the completions of the line, the next
+// line then starts with the line as it was.
+func (e *lineEditor) Scan() bool {
+	line, cursor := []rune(nil), 0
+	// set replaces the line shown after the prompt and moves the cursor
+	set := func(next []rune, at int) {
+		if cursor > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", cursor)
+		}
+		fmt.Fprintf(e.out, "%s\x1b[K", string(next))
+		if back := len(next) - at; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+		line, cursor = next, at
+	}
+	if e.pending != "" {
+		pending := []rune(e.pending)
+		e.pending = ""
+		set(pending, len(pending))
+	}
+	recalled := len(e.hist.lines) // entry of the history shown, len(e.hist.lines) for the line being typed
+	var typed []rune              // line being typed, kept while the history is shown
+	discarded := false
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				e.err = err
+			}
+			return false
+		}
+		if !discarded && e.discarded != nil && e.discarded() {
+			discarded = true
+			line, cursor, typed, recalled = nil, 0, nil, len(e.hist.lines)
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprintln(e.out)
+			e.line = string(line)
+			return true
+		case '\t':
+			fmt.Fprintln(e.out)
+			e.line, e.pending = string(line)+"\t", string(line)
+			return true
+		case keyCtrlD:
+			if len(line) == 0 {
+				return false
+			}
+		case keyBackspace, keyDelete:
+			if cursor > 0 {
+				set(append(line[:cursor-1:cursor-1], line[cursor:]...), cursor-1)
+			}
+		case keyEscape:
+			// the arrow keys are sent as ESC [ A to ESC [ D
+			if b, _ := e.in.ReadByte(); b != '[' {
+				continue
+			}
+			switch b, _ := e.in.ReadByte(); b {
+			case 'A':
+				if recalled > 0 {
+					if recalled == len(e.hist.lines) {
+						typed = line
+					}
+					recalled--
+					set([]rune(e.hist.lines[recalled]), len([]rune(e.hist.lines[recalled])))
+				}
+			case 'B':
+				if recalled < len(e.hist.lines) {
+					recalled++
+					next := typed
+					if recalled < len(e.hist.lines) {
+						next = []rune(e.hist.lines[recalled])
+					}
+					set(next, len(next))
+				}
+			case 'C':
+				if cursor < len(line) {
+					set(line, cursor+1)
+				}
+			case 'D':
+				if cursor > 0 {
+					set(line, cursor-1)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				next := append(append(append([]rune(nil), line[:cursor]...), r), line[cursor:]...)
+				set(next, cursor+1)
+			}
+		}
+	}
+}
+
+// Text returns the line read by the last call to Scan
+func (e *lineEditor) Text() string { return e.line }
+
+// Err returns the error that ended the input, nil at the end of the input
+func (e *lineEditor) Err() error { return e.err }
+
+// rawTerminal switches the terminal of stdin to non-canonical mode without
+// echo, so that the keys are read as they are pressed, returning the function
+// that restores its state. Interrupts are still sent as signals. It fails if
+// stdin is not a terminal or the stty command is not available.
+func rawTerminal() (restore func(), err error) {
+	if !isTerminal(os.Stdin) {
+		return nil, fmt.Errorf("stdin is not a terminal")
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(state)) }, nil
+}
+
+// stty runs the stty command on the terminal of stdin, returning its output
+func stty(args ...string) (string, error) {
+	c := exec.Command("stty", args...)
+	c.Stdin = os.Stdin
+	out, err := c.Output()
+	return string(out), err
+}
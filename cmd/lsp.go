@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lohvht/went/lang"
+	"github.com/lohvht/went/lang/token"
+)
+
+// JSON-RPC error codes used by the language server
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// rpcMessage is a JSON-RPC request, response or notification, requests and
+// notifications are told apart by whether they have an ID
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// LSP structures, only the fields used by the server are declared
+type (
+	lspPosition struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+	lspRange struct {
+		Start lspPosition `json:"start"`
+		End   lspPosition `json:"end"`
+	}
+	lspDiagnostic struct {
+		Range    lspRange `json:"range"`
+		Severity int      `json:"severity"`
+		Source   string   `json:"source"`
+		Message  string   `json:"message"`
+	}
+	lspTextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	}
+	lspDocumentParams struct {
+		TextDocument   lspTextDocument `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Position lspPosition `json:"position"`
+	}
+	lspCompletionItem struct {
+		Label string `json:"label"`
+		Kind  int    `json:"kind"`
+	}
+	lspLocation struct {
+		URI   string   `json:"uri"`
+		Range lspRange `json:"range"`
+	}
+)
+
+// LSP enumerations
+const (
+	lspSeverityError      = 1
+	lspSeverityWarning    = 2
+	lspSyncFull           = 1
+	lspCompletionKeyword  = 14
+	lspCompletionFunction = 3
+	lspCompletionVariable = 6
+	lspCompletionClass    = 7
+)
+
+// languageServer serves the Language Server Protocol over a pair of streams,
+// keeping the text of the documents opened by the editor
+type languageServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]string   // text of each open document by URI
+	globals  map[string][]string // names bound outside of functions by each open document, as of its last parse without errors
+	shutdown bool                // whether the client has requested a shutdown
+}
+
+// serve handles messages until the client exits, returning the exit code of
+// the process
+func (s *languageServer) serve() int {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return exitFailure
+		}
+		if err != nil {
+			s.reply(nil, nil, &rpcError{rpcParseError, err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return exitOK
+			}
+			return exitFailure
+		}
+		result, rpcErr := s.handle(msg)
+		if msg.ID != nil {
+			s.reply(msg.ID, result, rpcErr)
+		}
+	}
+}
+
+// handle handles a single request or notification, returning the result of
+// the request
+func (s *languageServer) handle(msg *rpcMessage) (interface{}, *rpcError) {
+	var params lspDocumentParams
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   lspSyncFull,
+				"completionProvider": map[string]interface{}{},
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "went"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = params.TextDocument.Text
+		s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.docs[uri] = params.ContentChanges[n-1].Text
+		}
+		s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		delete(s.globals, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri": uri, "diagnostics": []lspDiagnostic{},
+		})
+	case "textDocument/completion":
+		return completionItems(s.globals[uri]), nil
+	case "textDocument/definition":
+		if loc, ok := s.definition(uri, params.Position); ok {
+			return loc, nil
+		}
+		return nil, nil
+	default:
+		if msg.ID != nil {
+			return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("method %q is not supported", msg.Method)}
+		}
+	}
+	return nil, nil
+}
+
+// publishDiagnostics sends the errors found in the document and the issues
+// reported by the lint checks to the client
+func (s *languageServer) publishDiagnostics(uri string) {
+	diags := lang.NewDiagnostics(s.docs[uri])
+	f, err := lang.ParseFile(uri, s.docs[uri])
+	diags.Add(err)
+	if err == nil {
+		diags.AddLint(uri, lang.Lint(f))
+		s.globals[uri] = f.Symbols.Globals().Names()
+	}
+	diagnostics := []lspDiagnostic{}
+	for _, d := range diags.List() {
+		severity := lspSeverityError
+		if d.Severity == token.SeverityWarning {
+			severity = lspSeverityWarning
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{Range: lspRange{lspPos(d.Pos), lspPos(d.End)},
+			Severity: severity, Source: "went", Message: d.Msg})
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri": uri, "diagnostics": diagnostics,
+	})
+}
+
+// lspPos converts a position to the zero based position used by LSP
+func lspPos(pos token.Pos) lspPosition {
+	p := lspPosition{Line: pos.Line() - 1, Character: pos.Col() - 1}
+	if p.Line < 0 {
+		p.Line = 0
+	}
+	if p.Character < 0 {
+		p.Character = 0
+	}
+	return p
+}
+
+// definition returns the location where the name at the position of the
+// document is bound, false if there is no name there or it is not bound in the
+// document, see lang.Definition
+func (s *languageServer) definition(uri string, pos lspPosition) (lspLocation, bool) {
+	f, err := lang.ParseFile(uri, s.docs[uri])
+	if err != nil {
+		return lspLocation{}, false
+	}
+	id := lang.IdentAt(f, token.NewPos(pos.Line+1, pos.Character+1))
+	if id == nil {
+		return lspLocation{}, false
+	}
+	def := lang.Definition(f, id)
+	if def == nil {
+		return lspLocation{}, false
+	}
+	return lspLocation{URI: uri, Range: lspRange{lspPos(def.Pos()), lspPos(def.End())}}, true
+}
+
+// completionItems returns the keywords, builtins, built-in types and the
+// globals of the script
+func completionItems(globals []string) []lspCompletionItem {
+	var items []lspCompletionItem
+	seen := map[string]bool{}
+	add := func(kind int, labels ...string) {
+		for _, label := range labels {
+			if !seen[label] {
+				seen[label] = true
+				items = append(items, lspCompletionItem{Label: label, Kind: kind})
+			}
+		}
+	}
+	add(lspCompletionKeyword, token.Keywords()...)
+	add(lspCompletionFunction, lang.BuiltinNames()...)
+	add(lspCompletionClass, lang.NewSymbolTable().Globals().Names()...)
+	add(lspCompletionVariable, scriptArgsName)
+	add(lspCompletionVariable, globals...)
+	return items
+}
+
+// read reads a single message, framed by its Content-Length header
+func (s *languageServer) read() (*rpcMessage, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length header: %s", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	msg := &rpcMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// write writes a single message, framed by its Content-Length header
+func (s *languageServer) write(msg *rpcMessage) {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// reply sends the response to the request with the given ID, a null result is
+// sent if there is neither a result nor an error
+func (s *languageServer) reply(id *json.RawMessage, result interface{}, rpcErr *rpcError) {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := &rpcMessage{ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		msg.Result = json.RawMessage("null")
+		if result != nil {
+			msg.Result = result
+		}
+	}
+	s.write(msg)
+}
+
+func (s *languageServer) notify(method string, params interface{}) {
+	b, err := json.Marshal(params)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	s.write(&rpcMessage{Method: method, Params: b})
+}
+
+func lspCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	s := &languageServer{in: bufio.NewReader(os.Stdin), out: os.Stdout, docs: map[string]string{},
+		globals: map[string][]string{}}
+	return s.serve()
+}
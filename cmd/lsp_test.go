@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// frame frames the message as sent by a client, with its Content-Length header
+func frame(t *testing.T, msg map[string]interface{}) string {
+	t.Helper()
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// serveMessages runs a language server over the messages followed by a
+// shutdown and an exit, returning the messages it sent
+func serveMessages(t *testing.T, msgs ...map[string]interface{}) []*rpcMessage {
+	t.Helper()
+	var in strings.Builder
+	for _, msg := range append(msgs, map[string]interface{}{"id": 99, "method": "shutdown"},
+		map[string]interface{}{"method": "exit"}) {
+		in.WriteString(frame(t, msg))
+	}
+	var out bytes.Buffer
+	s := &languageServer{in: bufio.NewReader(strings.NewReader(in.String())), out: &out,
+		docs: map[string]string{}, globals: map[string][]string{}}
+	if code := s.serve(); code != exitOK {
+		t.Errorf("got exit code %d, expected %d", code, exitOK)
+	}
+	var sent []*rpcMessage
+	reader := &languageServer{in: bufio.NewReader(&out)}
+	for {
+		msg, err := reader.read()
+		if err == io.EOF {
+			return sent
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, msg)
+	}
+}
+
+// response returns the result of the response to the request with the ID,
+// decoded into result
+func response(t *testing.T, sent []*rpcMessage, id int, result interface{}) {
+	t.Helper()
+	for _, msg := range sent {
+		if msg.ID != nil && string(*msg.ID) == fmt.Sprint(id) {
+			if msg.Error != nil {
+				t.Fatalf("request %d failed: %s", id, msg.Error.Message)
+			}
+			b, err := json.Marshal(msg.Result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(b, result); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no response to request %d", id)
+}
+
+func openDoc(uri, text string) map[string]interface{} {
+	return map[string]interface{}{"method": "textDocument/didOpen",
+		"params": map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri, "text": text}}}
+}
+
+func TestLspInitialize(t *testing.T) {
+	sent := serveMessages(t, map[string]interface{}{"id": 1, "method": "initialize", "params": map[string]interface{}{}})
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	response(t, sent, 1, &result)
+	for _, capability := range []string{"textDocumentSync", "completionProvider", "definitionProvider"} {
+		if _, ok := result.Capabilities[capability]; !ok {
+			t.Errorf("got capabilities %v, expected %s", result.Capabilities, capability)
+		}
+	}
+}
+
+func TestLspDiagnostics(t *testing.T) {
+	sent := serveMessages(t, openDoc("file:///a.went", "x = 1\ny = x == x\nz = = 1\n"))
+	var params struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+	if len(sent) == 0 || sent[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("got messages %v, expected diagnostics first", sent)
+	}
+	if err := json.Unmarshal(sent[0].Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.URI != "file:///a.went" || len(params.Diagnostics) != 1 {
+		t.Fatalf("got diagnostics %+v for %s, expected 1 for file:///a.went", params.Diagnostics, params.URI)
+	}
+	if d := params.Diagnostics[0]; d.Severity != lspSeverityError || d.Range.Start != (lspPosition{2, 4}) {
+		t.Errorf("got diagnostic %+v, expected a syntax error at 2:4", d)
+	}
+}
+
+func TestLspCompletion(t *testing.T) {
+	sent := serveMessages(t, openDoc("file:///a.went", "total = 1\nfunc double(a) { b = a * 2; return b }\n"),
+		map[string]interface{}{"id": 2, "method": "textDocument/completion",
+			"params": map[string]interface{}{"textDocument": map[string]string{"uri": "file:///a.went"}}})
+	var items []lspCompletionItem
+	response(t, sent, 2, &items)
+	kinds := map[string]int{}
+	for _, item := range items {
+		kinds[item.Label] = item.Kind
+	}
+	for label, kind := range map[string]int{"while": lspCompletionKeyword, "print": lspCompletionFunction,
+		"total": lspCompletionVariable, "double": lspCompletionVariable} {
+		if kinds[label] != kind {
+			t.Errorf("got kind %d for %s, expected %d", kinds[label], label, kind)
+		}
+	}
+	if _, ok := kinds["b"]; ok {
+		t.Errorf("got completion of the local b, expected only globals")
+	}
+}
+
+func TestLspDefinition(t *testing.T) {
+	uri := "file:///a.went"
+	sent := serveMessages(t, openDoc(uri, "x = 1\nfunc f(x) {\n\treturn x\n}\ny = x + f(2)\nz = w\n"),
+		map[string]interface{}{"id": 2, "method": "textDocument/definition", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri}, "position": lspPosition{2, 8}}},
+		map[string]interface{}{"id": 3, "method": "textDocument/definition", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri}, "position": lspPosition{4, 4}}},
+		map[string]interface{}{"id": 4, "method": "textDocument/definition", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri}, "position": lspPosition{4, 8}}},
+		map[string]interface{}{"id": 5, "method": "textDocument/definition", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri}, "position": lspPosition{5, 4}}})
+	for id, expected := range map[int]*lspPosition{
+		2: {1, 7}, // the parameter x
+		3: {0, 0}, // the global x
+		4: {1, 5}, // the function f
+		5: nil,    // w is not bound
+	} {
+		var loc *lspLocation
+		response(t, sent, id, &loc)
+		switch {
+		case expected == nil && loc != nil:
+			t.Errorf("request %d: got definition %+v, expected none", id, loc)
+		case expected != nil && (loc == nil || loc.URI != uri || loc.Range.Start != *expected):
+			t.Errorf("request %d: got definition %+v, expected %s at %+v", id, loc, uri, *expected)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lohvht/went/lang"
+)
+
+const (
+	prettyWidth    = 72   // collections that fit within this width are printed on a single line
+	prettyMaxItems = 100  // maximum number of elements printed for a single collection
+	prettyMaxLen   = 1000 // maximum number of characters printed for a single string
+	prettyIndent   = "  " // indentation used for each nesting level
+)
+
+// prettyString formats a value for display in the REPL, printing collections
+// that do not fit on a single line with one element per line, and truncating
+// extremely long values with an ellipsis and a note of their length
+func prettyString(w lang.WType) string {
+	var buffer bytes.Buffer
+	writePretty(&buffer, w, 0, map[nested]bool{})
+	return buffer.String()
+}
+
+// nested identifies a list or map being written, so that one holding itself is
+// written as [...] or {...} where it is nested rather than forever
+type nested struct {
+	ptr uintptr
+	len int
+}
+
+// writePretty writes the pretty-printed value to the buffer, tabLevel is the
+// current level of nesting of the value and seen holds the lists and maps
+// being written
+func writePretty(buffer *bytes.Buffer, w lang.WType, tabLevel int, seen map[nested]bool) {
+	switch w.(type) {
+	case lang.WList, lang.Wmap:
+		key, held := nested{reflect.ValueOf(w).Pointer(), -1}, "{...}"
+		if l, ok := w.(lang.WList); ok {
+			key.len, held = len(l), "[...]"
+		}
+		if seen[key] {
+			buffer.WriteString(held)
+			return
+		}
+		seen[key] = true
+		defer delete(seen, key)
+	}
+	switch v := w.(type) {
+	case lang.WString:
+		if n := v.Len(); n > prettyMaxLen {
+			head, _ := v.Slice(0, prettyMaxLen)
+			fmt.Fprintf(buffer, "%s... (length %d)", head, n)
+			return
+		}
+		buffer.WriteString(v.String())
+	case lang.WList:
+		if s := v.String(); len(v) <= prettyMaxItems && fitsOnLine(s, tabLevel) {
+			buffer.WriteString(s)
+			return
+		}
+		buffer.WriteString("[\n")
+		for i, el := range v {
+			if i == prettyMaxItems {
+				writeTruncated(buffer, len(v), tabLevel+1)
+				break
+			}
+			buffer.WriteString(strings.Repeat(prettyIndent, tabLevel+1))
+			writePretty(buffer, el, tabLevel+1, seen)
+			buffer.WriteString(",\n")
+		}
+		buffer.WriteString(strings.Repeat(prettyIndent, tabLevel))
+		buffer.WriteString("]")
+	case lang.Wmap:
+		if len(v) == 0 {
+			buffer.WriteString("{}")
+			return
+		}
+		keys := v.Keys()
+		buffer.WriteString("{\n")
+		for i, k := range keys {
+			if i == prettyMaxItems {
+				writeTruncated(buffer, len(v), tabLevel+1)
+				break
+			}
+			fmt.Fprintf(buffer, "%s%s: ", strings.Repeat(prettyIndent, tabLevel+1), k)
+			value, _ := v.Get(k)
+			writePretty(buffer, value, tabLevel+1, seen)
+			buffer.WriteString(",\n")
+		}
+		buffer.WriteString(strings.Repeat(prettyIndent, tabLevel))
+		buffer.WriteString("}")
+	default:
+		buffer.WriteString(w.String())
+	}
+}
+
+// writeTruncated writes the note in place of the elements of a collection that
+// are not printed
+func writeTruncated(buffer *bytes.Buffer, length, tabLevel int) {
+	fmt.Fprintf(buffer, "%s... (%d more, length %d)\n",
+		strings.Repeat(prettyIndent, tabLevel), length-prettyMaxItems, length)
+}
+
+// fitsOnLine reports whether the single line form of a value fits within the
+// line width at the given nesting level
+func fitsOnLine(s string, tabLevel int) bool {
+	return !strings.Contains(s, "\n") && len(prettyIndent)*tabLevel+len(s) <= prettyWidth
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/lohvht/went/lang"
+)
+
+// lineProfile holds the profile of a single line of a script
+type lineProfile struct {
+	line  int
+	hits  int           // number of nodes on the line that were evaluated
+	spent time.Duration // time spent evaluating the line, excluding the other lines
+}
+
+// funcProfile holds the profile of a single function of a script
+type funcProfile struct {
+	name  string
+	spent time.Duration // time spent evaluating the body of the function, excluding the functions it calls
+}
+
+// topLevel is the name under which the time spent outside of any function is
+// reported
+const topLevel = "(top level)"
+
+// profiler records the time spent on each line of a script and in each of its
+// functions, the time between two consecutive evaluations is attributed to
+// the line and to the function of the former. The function of an evaluation is
+// the one whose call the interpreter is evaluating, so that the definition of
+// a function is attributed to the code defining it. The profile is only
+// reported as text, it is not written in the pprof format.
+type profiler struct {
+	last     time.Time
+	lastLine int          // line of the last node evaluated, 0 if none has been evaluated
+	lastFunc *funcProfile // function of the last node evaluated
+	lines    map[int]*lineProfile
+	funcs    map[*lang.FuncLit]*funcProfile // functions called, by their literal
+	top      *funcProfile                   // time spent outside of any function
+}
+
+func newProfiler() *profiler {
+	return &profiler{lines: map[int]*lineProfile{}, funcs: map[*lang.FuncLit]*funcProfile{},
+		top: &funcProfile{name: topLevel}}
+}
+
+// hook is the interpreter hook that records the evaluations
+func (p *profiler) hook(i *lang.Interpreter, node lang.Node) {
+	p.record(node.Pos().Line(), p.funcOf(i.Func()))
+}
+
+// funcOf returns the profile of the function, the top level profile if fn is
+// nil. A function is named after the name it is declared with, or else after
+// the position of its literal.
+func (p *profiler) funcOf(fn *lang.WFunc) *funcProfile {
+	if fn == nil {
+		return p.top
+	}
+	fp, ok := p.funcs[fn.Lit()]
+	if !ok {
+		name := fn.Name()
+		if name == "func" {
+			name = fmt.Sprintf("func literal at %s", fn.Lit().Pos())
+		}
+		fp = &funcProfile{name: name}
+		p.funcs[fn.Lit()] = fp
+	}
+	return fp
+}
+
+// record attributes the time since the last evaluation to its line and
+// function, and starts timing the evaluation of line in fn, a line of 0 stops
+// the timing
+func (p *profiler) record(line int, fn *funcProfile) {
+	now := time.Now()
+	if p.lastLine > 0 {
+		spent := now.Sub(p.last)
+		p.lines[p.lastLine].spent += spent
+		p.lastFunc.spent += spent
+	}
+	if line > 0 {
+		lp, ok := p.lines[line]
+		if !ok {
+			lp = &lineProfile{line: line}
+			p.lines[line] = lp
+		}
+		lp.hits++
+	}
+	p.last, p.lastLine, p.lastFunc = now, line, fn
+}
+
+// stop stops the timing of the last evaluation
+func (p *profiler) stop() { p.record(0, nil) }
+
+// report writes the profile of each line, sorted by the time spent on the
+// line, followed by the profile of each function, sorted likewise
+func (p *profiler) report(w io.Writer, name string) {
+	profiles := make([]*lineProfile, 0, len(p.lines))
+	var total time.Duration
+	for _, lp := range p.lines {
+		profiles = append(profiles, lp)
+		total += lp.spent
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].spent != profiles[j].spent {
+			return profiles[i].spent > profiles[j].spent
+		}
+		return profiles[i].line < profiles[j].line
+	})
+	fmt.Fprintf(w, "profile of %s, total %s\n", name, total)
+	fmt.Fprintf(w, "%8s %8s %14s %7s\n", "line", "hits", "time", "%")
+	for _, lp := range profiles {
+		var percent float64
+		if total > 0 {
+			percent = 100 * float64(lp.spent) / float64(total)
+		}
+		fmt.Fprintf(w, "%8d %8d %14s %6.2f%%\n", lp.line, lp.hits, lp.spent, percent)
+	}
+	funcs := []*funcProfile{p.top}
+	for _, fp := range p.funcs {
+		funcs = append(funcs, fp)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].spent != funcs[j].spent {
+			return funcs[i].spent > funcs[j].spent
+		}
+		return funcs[i].name < funcs[j].name
+	})
+	fmt.Fprintf(w, "\n%14s %7s  %s\n", "time", "%", "function")
+	for _, fp := range funcs {
+		var percent float64
+		if total > 0 {
+			percent = 100 * float64(fp.spent) / float64(total)
+		}
+		fmt.Fprintf(w, "%14s %6.2f%%  %s\n", fp.spent, percent, fp.name)
+	}
+}
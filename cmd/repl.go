@@ -0,0 +1,470 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/lohvht/went/lang"
+	"github.com/lohvht/went/lang/token"
+)
+
+const (
+	replName       = "<stdin>"       // input name used for error reporting in the REPL
+	promptPrefix   = ">>> "          // prompt shown when waiting for a new statement
+	continuePrefix = "... "          // prompt shown when the current statement is incomplete
+	historyFile    = ".went_history" // history file name, relative to the user's home directory
+	historyMaxLen  = 1000            // maximum number of history entries kept on disk
+)
+
+// history holds the lines entered into the REPL, it is loaded from and persisted
+// to a file so that previous inputs survive across sessions
+type history struct {
+	path  string   // path to the history file, empty if it could not be determined
+	lines []string // history entries, oldest first
+}
+
+// loadHistory reads the history file in the user's home directory, a missing
+// or unreadable file simply results in an empty history
+func loadHistory() *history {
+	h := &history{}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return h
+	}
+	h.path = filepath.Join(home, historyFile)
+	b, err := ioutil.ReadFile(h.path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	h.trim()
+	return h
+}
+
+// add appends a line to the history, skipping consecutive duplicates
+func (h *history) add(line string) {
+	if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	h.trim()
+}
+
+// trim drops the oldest entries so that at most historyMaxLen entries are kept
+func (h *history) trim() {
+	if len(h.lines) > historyMaxLen {
+		h.lines = h.lines[len(h.lines)-historyMaxLen:]
+	}
+}
+
+// save writes the history back to the history file
+func (h *history) save() error {
+	if h.path == "" {
+		return nil
+	}
+	data := strings.Join(h.lines, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	return ioutil.WriteFile(h.path, []byte(data), 0600)
+}
+
+// replSession holds the state of a single REPL session
+type replSession struct {
+	hist   *history
+	symtab *lang.SymbolTable // symbols visible to the session, used for completion
+	env    lang.Environment  // values bound to names during the session
+	quit   bool              // set when the session should be terminated
+	intr   interruptHandler
+	input  lineScanner // reads the lines of input of the session
+	// restore restores the state of the terminal on exit, nil if the state was
+	// not changed
+	restore func()
+}
+
+func newReplSession() *replSession {
+	return &replSession{
+		hist:   loadHistory(),
+		symtab: lang.NewSymbolTable(),
+		env:    lang.Environment{},
+		input:  bufio.NewScanner(os.Stdin),
+	}
+}
+
+// interruptHandler decides what an interrupt (Ctrl-C) does depending on the
+// state of the session: it cancels a running evaluation, discards the input of
+// an incomplete statement, or exits if it is pressed twice at an empty prompt
+type interruptHandler struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc // cancels the running evaluation, nil if none is running
+	discard bool               // set when the input read so far should be discarded
+	armed   bool               // set after an interrupt at the prompt, the next one exits
+}
+
+// start returns a context for a new evaluation that is cancelled on interrupt
+func (ih *interruptHandler) start() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	ih.mu.Lock()
+	ih.cancel = cancel
+	ih.mu.Unlock()
+	return ctx
+}
+
+// stop marks the end of the evaluation started by start
+func (ih *interruptHandler) stop() {
+	ih.mu.Lock()
+	ih.cancel()
+	ih.cancel = nil
+	ih.mu.Unlock()
+}
+
+// discarded reports whether the input read so far should be discarded, without
+// resetting the handler
+func (ih *interruptHandler) discarded() bool {
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	return ih.discard
+}
+
+// read resets the handler after a line of input has been read, returning true
+// if the input read before it should be discarded
+func (ih *interruptHandler) read() (discard bool) {
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	discard = ih.discard
+	ih.discard, ih.armed = false, false
+	return
+}
+
+// interrupt handles a single interrupt, returning true if the session should exit
+func (ih *interruptHandler) interrupt() (exit bool) {
+	ih.mu.Lock()
+	defer ih.mu.Unlock()
+	if ih.cancel != nil {
+		ih.cancel()
+		return false
+	}
+	if ih.armed {
+		return true
+	}
+	ih.discard, ih.armed = true, true
+	return false
+}
+
+// handleInterrupts processes interrupts for the session until the process exits
+func (s *replSession) handleInterrupts() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	for range sigs {
+		if s.intr.interrupt() {
+			fmt.Println()
+			if err := s.hist.save(); err != nil {
+				fmt.Fprintf(os.Stderr, "unable to save history to %s: %s\n", s.hist.path, err)
+			}
+			if s.restore != nil {
+				s.restore()
+			}
+			os.Exit(0)
+		}
+		fmt.Printf("\n(To exit, press Ctrl-C again or type %squit)\n%s", metaPrefix, promptPrefix)
+	}
+}
+
+// interpreterMode runs line-by-line interpretation in a manner similar to
+// Python IDLE or javascript consoles for browsers. If stdin is a terminal the
+// lines are read with a line editor, so that the lines of the history can be
+// recalled with the arrow keys, otherwise they are read as they are.
+func interpreterMode() int {
+	s := newReplSession()
+	if restore, err := rawTerminal(); err == nil {
+		s.restore = restore
+		s.input = &lineEditor{in: bufio.NewReader(os.Stdin), out: os.Stdout, hist: s.hist,
+			discarded: s.intr.discarded}
+	}
+	defer func() {
+		if err := s.hist.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to save history to %s: %s\n", s.hist.path, err)
+		}
+		if s.restore != nil {
+			s.restore()
+		}
+	}()
+	go s.handleInterrupts()
+	return s.run()
+}
+
+// run reads and runs the inputs of the session until its input ends or it is
+// quit, returning the exit code of the process
+func (s *replSession) run() int {
+	var buf []string // lines of a statement that spans multiple lines
+	for {
+		if len(buf) == 0 {
+			fmt.Print(promptPrefix)
+		} else {
+			fmt.Print(continuePrefix)
+		}
+		if !s.input.Scan() {
+			fmt.Println()
+			return exitOK
+		}
+		in := s.input.Text()
+		if s.intr.read() {
+			buf = buf[:0]
+		}
+		// a line ending with a tab character lists the completions of its last
+		// word, the line editor ends the line as soon as the tab key is pressed
+		if strings.HasSuffix(in, "\t") {
+			if suggestions := s.completer(strings.TrimRight(in, "\t")); len(suggestions) > 0 {
+				fmt.Println(strings.Join(suggestions, "  "))
+			}
+			continue
+		}
+		if strings.TrimSpace(in) == "" && len(buf) == 0 {
+			continue
+		}
+		s.hist.add(in)
+		buf = append(buf, in)
+		input := strings.Join(buf, "\n")
+		// an empty line forces the evaluation of an incomplete input
+		if strings.TrimSpace(in) != "" && !strings.HasPrefix(input, metaPrefix) && needsMoreInput(input) {
+			continue
+		}
+		buf = buf[:0]
+		s.interpretExecutor(input)
+		if s.quit {
+			return exitOK
+		}
+	}
+}
+
+// needsMoreInput reports whether the input is incomplete and should be continued
+// on the next line, this is the case when the input ends within an unclosed
+// bracket, string or comment, or right after an operator
+func needsMoreInput(in string) bool {
+	l := token.Tokenise(replName, in)
+	var last token.Token
+	for tkn := l.Next(); tkn.Type != token.EOF && tkn.Type != token.ERROR; tkn = l.Next() {
+		if tkn.Type != token.SEMICOLON {
+			last = tkn
+		}
+	}
+	l.Drain()
+	if l.Incomplete {
+		return true
+	}
+	return last.Type.IsOperator() || last.Type == token.COMMA || last.Type == token.DOT
+}
+
+// completer returns the keywords and names visible in the session's global
+// scope that complete the last word of the input, or within the brackets of
+// an index of a map, the keys of the map that complete what follows '['
+func (s *replSession) completer(in string) []string {
+	if keys, ok := s.keyCompleter(in); ok {
+		return keys
+	}
+	i := strings.LastIndexFunc(in, notNameChar)
+	word := in[i+1:]
+	if word == "" {
+		return nil
+	}
+	seen := map[string]bool{}
+	var suggestions []string
+	for _, candidates := range [][]string{token.Keywords(), lang.BuiltinNames(), s.symtab.Globals().Names()} {
+		for _, c := range candidates {
+			if strings.HasPrefix(c, word) && !seen[c] {
+				seen[c] = true
+				suggestions = append(suggestions, c)
+			}
+		}
+	}
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+// keyCompleter returns the keys of the map named before the last '[' of the
+// input, written as literals, that complete the input after the '['. It
+// returns false unless the input ends within the brackets of an index of a map
+// bound in the session.
+func (s *replSession) keyCompleter(in string) ([]string, bool) {
+	open := strings.LastIndex(in, "[")
+	if open < 0 || strings.Contains(in[open:], "]") {
+		return nil, false
+	}
+	name := in[strings.LastIndexFunc(in[:open], notNameChar)+1 : open]
+	m, ok := s.env[name].(lang.Wmap)
+	if name == "" || !ok {
+		return nil, false
+	}
+	var keys []string
+	for _, k := range m.Keys() {
+		if lit := k.String(); strings.HasPrefix(lit, in[open+1:]) {
+			keys = append(keys, lit)
+		}
+	}
+	return keys, true
+}
+
+// notNameChar reports whether the character cannot be part of a name
+func notNameChar(r rune) bool { return !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) }
+
+// interpretExecutor parses and interprets a single line of input, reporting
+// errors without terminating the REPL session
+func (s *replSession) interpretExecutor(in string) {
+	if strings.HasPrefix(strings.TrimSpace(in), metaPrefix) {
+		s.runMetaCommand(strings.TrimSpace(in))
+		return
+	}
+	s.execute(replName, in)
+}
+
+// execute parses and interprets the input, reporting errors without terminating
+// the REPL session, name is the name of the input used for error reporting
+func (s *replSession) execute(name, input string) {
+	p, err := lang.Parse(name, input)
+	if err != nil {
+		errPrinter.printError(input, err)
+		return
+	}
+	ctx := s.intr.start()
+	defer s.intr.stop()
+	i, err := lang.InterpretContext(ctx, p.Root, lang.Config{Env: s.env})
+	if err != nil {
+		errPrinter.printError(input, err)
+		return
+	}
+	for name := range s.env {
+		// the names bound by the input are offered by the completer
+		s.symtab.Globals().Define(lang.NewVarSymbol(name))
+	}
+	if i.Result != nil {
+		fmt.Println(prettyString(i.Result))
+	}
+}
+
+// Meta commands
+
+const (
+	metaPrefix = ":"    // prefix that marks an input as a REPL meta command
+	pasteEnd   = ":end" // line that terminates the input of the :paste command
+)
+
+// metaCommand is a REPL command that is handled by the REPL itself instead of
+// being interpreted as went code
+type metaCommand struct {
+	usage string                           // usage of the command, shown by :help
+	help  string                           // short description of the command, shown by :help
+	run   func(s *replSession, arg string) // arg is the remaining input after the command name
+}
+
+var metaCommands map[string]metaCommand
+
+func init() {
+	metaCommands = map[string]metaCommand{
+		"help": {":help", "show this help text", func(s *replSession, arg string) {
+			names := make([]string, 0, len(metaCommands))
+			for name := range metaCommands {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("  %-16s %s\n", metaCommands[name].usage, metaCommands[name].help)
+			}
+		}},
+		"load": {":load <file>", "run a script file in this session", func(s *replSession, arg string) {
+			if arg == "" {
+				fmt.Fprintf(os.Stderr, "usage: %sload <file>\n", metaPrefix)
+				return
+			}
+			b, err := ioutil.ReadFile(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "unable to load %s: %s\n", arg, err)
+				return
+			}
+			s.execute(filepath.Base(arg), string(b))
+		}},
+		"paste": {":paste", "run the lines that follow, up to " + pasteEnd + " or EOF, as a single input", func(s *replSession, arg string) {
+			fmt.Printf("(paste mode, finish with %s or Ctrl-D)\n", pasteEnd)
+			var lines []string
+			for {
+				if !s.input.Scan() {
+					if err := s.input.Err(); err != nil {
+						fmt.Fprintf(os.Stderr, "unable to read input: %s\n", err)
+						return
+					}
+					// a scanner stops scanning for good once it reaches EOF, use a
+					// new one so that the session continues after a Ctrl-D
+					if _, ok := s.input.(*bufio.Scanner); ok {
+						s.input = bufio.NewScanner(os.Stdin)
+					}
+					break
+				}
+				if strings.TrimSpace(s.input.Text()) == pasteEnd {
+					break
+				}
+				lines = append(lines, s.input.Text())
+			}
+			if len(lines) > 0 {
+				s.execute(replName, strings.Join(lines, "\n"))
+			}
+		}},
+		"quit": {":quit", "exit the interpreter", func(s *replSession, arg string) { s.quit = true }},
+		"reset": {":reset", "discard all variables bound in this session", func(s *replSession, arg string) {
+			s.symtab = lang.NewSymbolTable()
+			s.env = lang.Environment{}
+		}},
+		"time": {":time <expr>", "evaluate the expression and print how long it took", func(s *replSession, arg string) {
+			if arg == "" {
+				fmt.Fprintf(os.Stderr, "usage: %stime <expr>\n", metaPrefix)
+				return
+			}
+			start := time.Now()
+			s.execute(replName, arg)
+			fmt.Printf("took %s\n", time.Since(start))
+		}},
+		"vars": {":vars", "list the variables bound in this session", func(s *replSession, arg string) {
+			for _, name := range s.envNames() {
+				fmt.Printf("%s: %s\n", name, lang.TypeName(s.env[name]))
+			}
+		}},
+	}
+}
+
+// runMetaCommand parses and runs a meta command such as ":help"
+func (s *replSession) runMetaCommand(in string) {
+	fields := strings.SplitN(strings.TrimPrefix(in, metaPrefix), " ", 2)
+	cmd, ok := metaCommands[fields[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s%s, type %shelp for a list of commands\n",
+			metaPrefix, fields[0], metaPrefix)
+		return
+	}
+	var arg string
+	if len(fields) > 1 {
+		arg = strings.TrimSpace(fields[1])
+	}
+	cmd.run(s, arg)
+}
+
+// envNames returns the sorted names bound in the session's environment
+func (s *replSession) envNames() []string {
+	names := make([]string, 0, len(s.env))
+	for name := range s.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
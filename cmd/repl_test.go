@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lohvht/went/lang"
+)
+
+// runSession runs a REPL session over the lines of input, returning the
+// session and what it wrote to stdout and stderr
+func runSession(t *testing.T, input string) (s *replSession, stdout, stderr string) {
+	t.Helper()
+	s = &replSession{
+		hist:   &history{}, // not persisted
+		symtab: lang.NewSymbolTable(),
+		env:    lang.Environment{},
+		input:  bufio.NewScanner(strings.NewReader(input)),
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errBuf bytes.Buffer
+	savedStdout, savedErrW := os.Stdout, errPrinter.w
+	os.Stdout, errPrinter.w = w, &errBuf
+	defer func() { os.Stdout, errPrinter.w = savedStdout, savedErrW }()
+	done := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		done <- b
+	}()
+	if code := s.run(); code != exitOK {
+		t.Errorf("got exit code %d, expected %d", code, exitOK)
+	}
+	w.Close()
+	return s, string(<-done), errBuf.String()
+}
+
+func TestReplEnv(t *testing.T) {
+	s, stdout, stderr := runSession(t, "x = 1\ny = x + 1\ny\n")
+	if stderr != "" {
+		t.Errorf("got errors %q, expected none", stderr)
+	}
+	if x, y := s.env["x"], s.env["y"]; x != lang.WNum(1) || y != lang.WNum(2) {
+		t.Errorf("got x = %v and y = %v, expected 1 and 2", x, y)
+	}
+	if expected := ">>> >>> >>> 2\n>>> \n"; stdout != expected {
+		t.Errorf("got output %q, expected %q", stdout, expected)
+	}
+}
+
+func TestReplContinuation(t *testing.T) {
+	// the statement is only run once its brackets are closed
+	s, stdout, stderr := runSession(t, "l = [1,\n2,\n3]\nf = func(a) {\nreturn a * 2\n}\nf(l[2])\n")
+	if stderr != "" {
+		t.Errorf("got errors %q, expected none", stderr)
+	}
+	if expected := ">>> ... ... >>> ... ... >>> 6\n>>> \n"; stdout != expected {
+		t.Errorf("got output %q, expected %q", stdout, expected)
+	}
+	if l, ok := s.env["l"].(lang.WList); !ok || len(l) != 3 {
+		t.Errorf("got l = %v, expected a list of 3 elements", s.env["l"])
+	}
+}
+
+func TestReplForcedEvaluation(t *testing.T) {
+	// an empty line runs an incomplete input, reporting its error
+	_, stdout, stderr := runSession(t, "x = (1 +\n\nx = 2\nx\n")
+	if !strings.Contains(stderr, "SyntaxError") {
+		t.Errorf("got errors %q, expected a syntax error", stderr)
+	}
+	if expected := ">>> ... >>> >>> 2\n>>> \n"; stdout != expected {
+		t.Errorf("got output %q, expected %q", stdout, expected)
+	}
+}
+
+func TestReplErrorsKeepSession(t *testing.T) {
+	s, stdout, stderr := runSession(t, "x = 1\nx = = 2\ny = 1 / 0\nz = x + 1\nz\n:quit\nw = 1\n")
+	for _, expected := range []string{"SyntaxError", "ZeroDivisionError"} {
+		if !strings.Contains(stderr, expected) {
+			t.Errorf("got errors %q, expected a %s", stderr, expected)
+		}
+	}
+	if _, ok := s.env["y"]; ok {
+		t.Errorf("y is bound to %v, expected it to be unbound after the error", s.env["y"])
+	}
+	if z := s.env["z"]; z != lang.WNum(2) {
+		t.Errorf("got z = %v, expected 2", z)
+	}
+	if _, ok := s.env["w"]; ok || !s.quit {
+		t.Errorf("the session went on after :quit")
+	}
+	if !strings.HasSuffix(stdout, "2\n>>> ") {
+		t.Errorf("got output %q, expected it to end with the result of z and a prompt", stdout)
+	}
+}
+
+func TestReplCyclicValue(t *testing.T) {
+	// a list too long for a single line that holds itself is written once
+	long := strings.Repeat("a", prettyWidth)
+	_, stdout, stderr := runSession(t, "l = ['"+long+"', 1]\nl[1] = l\nl\n")
+	if stderr != "" {
+		t.Errorf("got errors %q, expected none", stderr)
+	}
+	if expected := "[\n  '" + long + "',\n  [...],\n]\n"; !strings.Contains(stdout, expected) {
+		t.Errorf("got output %q, expected it to contain %q", stdout, expected)
+	}
+}
+
+func TestReplVars(t *testing.T) {
+	_, stdout, stderr := runSession(t, "s = 'a'\nm = {}\nfunc f() {}\n:vars\n")
+	if stderr != "" {
+		t.Errorf("got errors %q, expected none", stderr)
+	}
+	if expected := "f: function\nm: map\ns: string\n"; !strings.Contains(stdout, expected) {
+		t.Errorf("got output %q, expected it to contain %q", stdout, expected)
+	}
+}
+
+func TestReplCompletion(t *testing.T) {
+	s, stdout, stderr := runSession(t, "counter = 1\nm = {'apple': 1, 'avocado': 2, 'banana': 3, 4: 0}\ncou\t\nm['a\t\nm[\t\n")
+	if stderr != "" {
+		t.Errorf("got errors %q, expected none", stderr)
+	}
+	if _, ok := s.symtab.Globals().Resolve("counter"); !ok {
+		t.Errorf("counter is not in the symbol table of the session")
+	}
+	for _, expected := range []string{"counter\n", "'apple'  'avocado'\n", "4  'apple'  'avocado'  'banana'\n"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("got output %q, expected it to contain %q", stdout, expected)
+		}
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	var out bytes.Buffer
+	e := &lineEditor{in: bufio.NewReader(strings.NewReader("\x1b[A\x1b[A\n" + "xy\x1b[D!\n" + "c\x1b[A\x1b[B\n" +
+		"ab\x7f\n" + "ab\t" + "c\n" + "\x1b[A\x1b[A\x1b[A\x1b[B\r" + "d\x04\n" + "\x04")),
+		out: &out, hist: &history{lines: []string{"a = 1", "b = 2"}}}
+	for _, expected := range []string{
+		"a = 1", // recalled
+		"x!y",   // inserted before the cursor
+		"c",     // the typed line is restored past the last entry
+		"a",     // erased
+		"ab\t",  // completed
+		"abc",   // continued after the completion
+		"b = 2", // up stops at the first entry
+		"d",     // Ctrl-D only ends an empty line
+	} {
+		if !e.Scan() {
+			t.Fatalf("got the end of the input, expected %q", expected)
+		}
+		if e.Text() != expected {
+			t.Errorf("got line %q, expected %q", e.Text(), expected)
+		}
+	}
+	if e.Scan() || e.Err() != nil {
+		t.Errorf("got line %q and error %v, expected the end of the input", e.Text(), e.Err())
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lohvht/went/lang"
+)
+
+const testFileSuffix = "_test.went" // suffix of the scripts run by "went test"
+
+// findTestFiles returns the test files found at the paths, directories are
+// searched recursively for files ending with testFileSuffix
+func findTestFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(p, testFileSuffix) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+const testFuncPrefix = "test_" // prefix of the functions run as tests by "went test"
+
+// testResult is the result of a test, either a whole test file or one of its
+// test functions
+type testResult struct {
+	name    string // path of the file, followed by the name of the test function if any
+	output  string
+	err     error // error that caused the test to fail, nil if it passed
+	elapsed time.Duration
+}
+
+// runTestFile runs a test file and then, as separate tests, each function that
+// it binds in the global scope whose name starts with testFuncPrefix, in the
+// order of their names. The file is a single test if it has no test functions
+// or if it fails before they are run. The coverage of the tests is recorded in
+// cov if it is not nil.
+func runTestFile(path string, cov *coverage) []testResult {
+	start := time.Now()
+	fail := func(output string, err error) []testResult {
+		return []testResult{{name: path, output: output, err: err, elapsed: time.Since(start)}}
+	}
+	name, input, err := readScript(path)
+	if err != nil {
+		return fail("", err)
+	}
+	p, err := lang.Parse(name, input)
+	if err != nil {
+		return fail("", err)
+	}
+	var out bytes.Buffer
+	cfg := lang.Config{Env: lang.Environment{}, Stdout: &out, Stderr: &out, Stdin: strings.NewReader("")}
+	if cov != nil {
+		cfg.Hook = cov.add(path, input)
+	}
+	if _, err := lang.InterpretContext(context.Background(), p.Root, cfg); err != nil {
+		return fail(out.String(), err)
+	}
+	var funcs []string
+	for fname, v := range cfg.Env {
+		if _, ok := v.(*lang.WFunc); ok && strings.HasPrefix(fname, testFuncPrefix) {
+			funcs = append(funcs, fname)
+		}
+	}
+	if len(funcs) == 0 {
+		return fail(out.String(), nil)
+	}
+	sort.Strings(funcs)
+	var results []testResult
+	for _, fname := range funcs {
+		out.Reset()
+		start := time.Now()
+		// the call is interpreted as a script of its own over the globals of
+		// the file, errors within the function are reported at their position
+		// in the file. The coverage hook only records the statements of the
+		// file, those of the function and not the call.
+		call, err := lang.Parse(name, fname+"()")
+		if err == nil {
+			_, err = lang.InterpretContext(context.Background(), call.Root, cfg)
+		}
+		results = append(results, testResult{name: path + ":" + fname, output: out.String(), err: err,
+			elapsed: time.Since(start)})
+	}
+	return results
+}
+
+// printTestOutput prints the output of a test, indented under its result
+func printTestOutput(output string) {
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if line != "" {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
+
+func testCmd(c *command, args []string) int {
+	fs := c.flagSet()
+	verbose := fs.Bool("v", false, "print the result of every test, not only failures")
+	cover := fs.Bool("cover", false, "print the statement coverage of the test files")
+	coverHTML := fs.String("coverhtml", "", "write an HTML report of the statement coverage of the test files to the `file`")
+	if ok, code := c.parseFlags(fs, args); !ok {
+		return code
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := findTestFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitNoInput
+	}
+	if len(files) == 0 {
+		fmt.Printf("no test files (%s) found\n", testFileSuffix)
+		return exitOK
+	}
+	var cov *coverage
+	if *cover || *coverHTML != "" {
+		cov = &coverage{}
+	}
+	var passed, failed int
+	start := time.Now()
+	for _, file := range files {
+		for _, res := range runTestFile(file, cov) {
+			if res.err != nil {
+				failed++
+				fmt.Printf("--- FAIL: %s (%s)\n", res.name, res.elapsed)
+				printTestOutput(res.output)
+				diags := lang.NewDiagnostics("")
+				diags.Add(res.err)
+				for _, d := range diags.List() {
+					fmt.Printf("    %s\n", d)
+				}
+				continue
+			}
+			passed++
+			if *verbose {
+				fmt.Printf("--- PASS: %s (%s)\n", res.name, res.elapsed)
+				printTestOutput(res.output)
+			}
+		}
+	}
+	status := "ok"
+	if failed > 0 {
+		status = "FAIL"
+	}
+	fmt.Printf("%s\t%d passed, %d failed (%s)\n", status, passed, failed, time.Since(start))
+	if cov != nil {
+		if code := reportCoverage(cov, runOptions{cover: *cover, coverHTML: *coverHTML}); code != exitOK {
+			return code
+		}
+	}
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,448 @@
+package lang
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// WBuiltin is a function of the interpreter implemented in Go, the builtins are
+// bound in a scope enclosing the global scope so that a script may shadow them
+type WBuiltin struct {
+	name  string
+	arity int // number of arguments it takes, -1 if it takes any number
+	fn    func(i *Interpreter, node *CallExpr, args []WType) WType
+}
+
+// IsZeroValue always returns false as functions have no zero value
+func (w *WBuiltin) IsZeroValue() WBool { return false }
+
+// Equals returns true only if both are the same builtin
+func (w *WBuiltin) Equals(w2 WType) WBool {
+	v, ok := w2.(*WBuiltin)
+	return WBool(ok && v == w)
+}
+
+// Sm will always return false and an error for WBuiltin as WBuiltin has
+// no order relation
+func (w *WBuiltin) Sm(w2 WType, orEq bool) (WBool, error) {
+	operator := sm
+	if orEq {
+		operator = smE
+	}
+	return false, opError(w, w2, operator)
+}
+
+// Gr (see Sm)
+func (w *WBuiltin) Gr(w2 WType, orEq bool) (WBool, error) {
+	operator := gr
+	if orEq {
+		operator = grE
+	}
+	return false, opError(w, w2, operator)
+}
+
+func (w *WBuiltin) String() string { return fmt.Sprintf("<builtin %s>", w.name) }
+
+// builtins are the builtin functions by name
+var builtins = map[string]*WBuiltin{
+	"abs":       {name: "abs", arity: 1, fn: builtinAbs},
+	"copy":      {name: "copy", arity: 1, fn: builtinCopy},
+	"deepcopy":  {name: "deepcopy", arity: 1, fn: builtinDeepcopy},
+	"enumerate": {name: "enumerate", arity: 1, fn: builtinEnumerate},
+	"filter":    {name: "filter", arity: 2, fn: builtinFilter},
+	"format":    {name: "format", arity: -1, fn: builtinFormat},
+	"iter":      {name: "iter", arity: -1, fn: builtinIter},
+	"len":       {name: "len", arity: 1, fn: builtinLen},
+	"map":       {name: "map", arity: 2, fn: builtinMap},
+	"max":       {name: "max", arity: -1, fn: builtinMax},
+	"min":       {name: "min", arity: -1, fn: builtinMin},
+	"next":      {name: "next", arity: -1, fn: builtinNext},
+	"print":     {name: "print", arity: -1, fn: builtinPrint},
+	"range":     {name: "range", arity: -1, fn: builtinRange},
+	"reduce":    {name: "reduce", arity: 3, fn: builtinReduce},
+	"repr":      {name: "repr", arity: 1, fn: builtinRepr},
+	"round":     {name: "round", arity: -1, fn: builtinRound},
+	"sorted":    {name: "sorted", arity: -1, fn: builtinSorted},
+	"str":       {name: "str", arity: 1, fn: builtinStr},
+	"sum":       {name: "sum", arity: 1, fn: builtinSum},
+	"zip":       {name: "zip", arity: 2, fn: builtinZip},
+}
+
+// BuiltinNames returns the sorted names of the builtin functions
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// callBuiltin calls the builtin with the arguments
+func (i *Interpreter) callBuiltin(fn *WBuiltin, args []WType, node *CallExpr) WType {
+	if fn.arity >= 0 && len(args) != fn.arity {
+		i.typeErrorf("%s() takes %d argument(s) but %d were given", node, fn.name, fn.arity, len(args))
+	}
+	return fn.fn(i, node, args)
+}
+
+// intArg returns the argument of the builtin as an int, panicking with a type
+// error if it is not an integer
+func (i *Interpreter) intArg(fn string, arg WType, node *CallExpr) int {
+	if k, ok := arg.(WNum); ok && k.IsInt() {
+		return int(k)
+	}
+	i.typeErrorf("%s() argument must be an integer, not '%s'", node, fn, TypeName(arg))
+	// Should not reach here as typeErrorf will panic
+	return 0
+}
+
+// builtinPrint writes its arguments to the output of the script separated by
+// spaces and followed by a newline, see Str
+func builtinPrint(i *Interpreter, node *CallExpr, args []WType) WType {
+	strs := make([]interface{}, len(args))
+	for k, arg := range args {
+		strs[k] = Str(arg)
+	}
+	if _, err := fmt.Fprintln(i.stdout, strs...); err != nil {
+		i.fail(node, "", RuntimeOtherError, "%s", err)
+	}
+	return WNull{}
+}
+
+// builtinLen returns the number of characters of a string, or the number of
+// elements of a list, tuple or map
+func builtinLen(i *Interpreter, node *CallExpr, args []WType) WType {
+	switch v := args[0].(type) {
+	case WString:
+		return WNum(v.Len())
+	case WList:
+		return WNum(len(v))
+	case WTuple:
+		return WNum(len(v))
+	case Wmap:
+		return WNum(len(v))
+	}
+	i.typeErrorf("object of type '%s' has no len()", node, TypeName(args[0]))
+	// Should not reach here as typeErrorf will panic
+	return nil
+}
+
+// builtinStr returns the value as it is printed, see Str
+func builtinStr(i *Interpreter, node *CallExpr, args []WType) WType {
+	return WString(Str(args[0]))
+}
+
+// builtinRepr returns the debug form of the value, strings are quoted
+func builtinRepr(i *Interpreter, node *CallExpr, args []WType) WType {
+	return WString(args[0].String())
+}
+
+// Assigning a value or passing it as an argument never copies it, lists and
+// maps are shared by every name bound to them so that changing the elements
+// through one name is seen through the others, e.g.
+//
+//	a = [1, 2]
+//	b = a
+//	b[0] = 3 // a is [3, 2]
+//
+// copy and deepcopy return values that may be changed independently.
+
+// builtinCopy returns a shallow copy of a list or a map, a new list or map
+// holding the same elements, other values, including tuples, are immutable and
+// returned as is
+func builtinCopy(i *Interpreter, node *CallExpr, args []WType) WType {
+	switch v := args[0].(type) {
+	case WList:
+		return append(WList{}, v...)
+	case Wmap:
+		m := make(Wmap, len(v))
+		for k, e := range v {
+			m[k] = e
+		}
+		return m
+	}
+	return args[0]
+}
+
+// builtinDeepcopy returns a deep copy of a list, a map or a tuple, copying the
+// lists, maps and tuples that it holds recursively. A list or map held more than once,
+// including one that holds itself, is copied once so that the copy has the
+// same shape. Functions are returned as is, sharing the scope they close over.
+func builtinDeepcopy(i *Interpreter, node *CallExpr, args []WType) WType {
+	return deepcopy(args[0], map[container]WType{})
+}
+
+// deepcopy returns a deep copy of w, copied holds the copies of the lists and
+// maps copied so far
+func deepcopy(w WType, copied map[container]WType) WType {
+	switch v := w.(type) {
+	case WList:
+		if len(v) == 0 {
+			return WList{}
+		}
+		key := container{reflect.ValueOf(v).Pointer(), len(v)}
+		if c, ok := copied[key]; ok {
+			return c
+		}
+		l := make(WList, len(v))
+		copied[key] = l
+		for k, el := range v {
+			l[k] = deepcopy(el, copied)
+		}
+		return l
+	case Wmap:
+		key := container{reflect.ValueOf(v).Pointer(), -1}
+		if c, ok := copied[key]; ok {
+			return c
+		}
+		m := make(Wmap, len(v))
+		copied[key] = m
+		for k, e := range v {
+			// the keys are hashable so they hold no lists or maps to copy
+			m[k] = mapEntry{e.key, deepcopy(e.value, copied)}
+		}
+		return m
+	case WTuple:
+		t := make(WTuple, len(v))
+		for k, el := range v {
+			t[k] = deepcopy(el, copied)
+		}
+		return t
+	}
+	return w
+}
+
+// elements returns the elements of a list, the characters of a string, the
+// values of a generator or iterator, or the items of a map, each item being a
+// list of its key and its value in the sorted order of the keys
+func (i *Interpreter) elements(x WType, node Node) []WType {
+	keys, values := i.iterate(x, node)
+	if _, ok := x.(Wmap); ok {
+		for k := range values {
+			values[k] = WList{keys[k], values[k]}
+		}
+	}
+	return values
+}
+
+// builtinMap returns a new list of the results of calling the function with
+// each element of the list, string or map, see elements
+func builtinMap(i *Interpreter, node *CallExpr, args []WType) WType {
+	res := WList{}
+	for _, el := range i.elements(args[1], node) {
+		res = append(res, i.callValue(args[0], []WType{el}, node))
+	}
+	return res
+}
+
+// builtinFilter returns a new list of the elements of the list, string or map
+// for which the function returns a value that is not a zero value
+func builtinFilter(i *Interpreter, node *CallExpr, args []WType) WType {
+	res := WList{}
+	for _, el := range i.elements(args[1], node) {
+		if !i.callValue(args[0], []WType{el}, node).IsZeroValue() {
+			res = append(res, el)
+		}
+	}
+	return res
+}
+
+// builtinReduce folds the elements of the list, string or map from the left,
+// calling the function with the result so far, starting with the initial
+// value, and each element
+func builtinReduce(i *Interpreter, node *CallExpr, args []WType) WType {
+	acc := args[2]
+	for _, el := range i.elements(args[1], node) {
+		acc = i.callValue(args[0], []WType{acc, el}, node)
+	}
+	return acc
+}
+
+// numArg returns the argument of the builtin as a number, panicking with a
+// type error if it is not a number
+func (i *Interpreter) numArg(fn string, arg WType, node *CallExpr) WNum {
+	if n, ok := arg.(WNum); ok {
+		return n
+	}
+	i.typeErrorf("%s() argument must be a number, not '%s'", node, fn, TypeName(arg))
+	// Should not reach here as typeErrorf will panic
+	return 0
+}
+
+// builtinRange returns an iterator over the integers from start up to but not
+// including stop, counting by step, the integers are only produced as the
+// iteration advances. It is called as range(stop), range(start, stop) or
+// range(start, stop, step), start defaults to 0 and step to 1, a negative step
+// counts down.
+func builtinRange(i *Interpreter, node *CallExpr, args []WType) WType {
+	if len(args) < 1 || len(args) > 3 {
+		i.typeErrorf("range() takes 1 to 3 argument(s) but %d were given", node, len(args))
+	}
+	start, step := 0, 1
+	stop := i.intArg("range", args[0], node)
+	if len(args) > 1 {
+		start, stop = stop, i.intArg("range", args[1], node)
+	}
+	if len(args) > 2 {
+		step = i.intArg("range", args[2], node)
+	}
+	if step == 0 {
+		i.valueErrorf("range() step must not be zero", node)
+	}
+	return &WIterator{&rangeIterator{n: start, stop: stop, step: step}}
+}
+
+// builtinEnumerate returns a new list pairing the index of each element of the
+// list, string or map with the element, as tuples of two elements
+func builtinEnumerate(i *Interpreter, node *CallExpr, args []WType) WType {
+	res := WList{}
+	for k, el := range i.elements(args[0], node) {
+		res = append(res, WTuple{WNum(k), el})
+	}
+	return res
+}
+
+// builtinZip returns a new list pairing the elements of both lists, strings or
+// maps at the same index, as tuples of two elements, up to the shortest of both
+func builtinZip(i *Interpreter, node *CallExpr, args []WType) WType {
+	a, b := i.elements(args[0], node), i.elements(args[1], node)
+	res := WList{}
+	for k := 0; k < min(len(a), len(b)); k++ {
+		res = append(res, WTuple{a[k], b[k]})
+	}
+	return res
+}
+
+// builtinSorted returns a new list of the elements of the list, string or map
+// in ascending order, elements that are equal keep their order. It is called
+// as sorted(xs) comparing the elements with '<', or sorted(xs, f) where f is
+// either a key function of one argument whose results are compared instead,
+// or a comparator of two arguments returning a negative number if its first
+// argument comes before its second, zero if they are equal and a positive
+// number otherwise.
+func builtinSorted(i *Interpreter, node *CallExpr, args []WType) WType {
+	if len(args) < 1 || len(args) > 2 {
+		i.typeErrorf("sorted() takes 1 or 2 argument(s) but %d were given", node, len(args))
+	}
+	res := WList(i.elements(args[0], node))
+	if len(args) == 1 {
+		sort.SliceStable(res, func(a, b int) bool { return i.less(res[a], res[b], node) })
+		return res
+	}
+	fn := args[1]
+	if params(fn) == 2 {
+		sort.SliceStable(res, func(a, b int) bool {
+			order, ok := i.callValue(fn, []WType{res[a], res[b]}, node).(WNum)
+			if !ok {
+				i.typeErrorf("sorted() comparator must return a number", node)
+			}
+			return order < 0
+		})
+		return res
+	}
+	keys := make(WList, len(res))
+	for k, el := range res {
+		keys[k] = i.callValue(fn, []WType{el}, node)
+	}
+	// sort the indices rather than the elements so that the keys are
+	// computed only once per element
+	order := make([]int, len(res))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool { return i.less(keys[order[a]], keys[order[b]], node) })
+	sorted := make(WList, len(res))
+	for k, idx := range order {
+		sorted[k] = res[idx]
+	}
+	return sorted
+}
+
+// less reports whether a is smaller than b, panicking with a type error if they
+// have no order relation
+func (i *Interpreter) less(a, b WType, node *CallExpr) bool {
+	res, err := a.Sm(b, false)
+	if err != nil {
+		i.typeError(node, err)
+	}
+	return bool(res)
+}
+
+// params returns the number of arguments that a function or a builtin takes,
+// -1 if it is not callable or takes any number
+func params(fn WType) int {
+	switch fn := fn.(type) {
+	case *WFunc:
+		return len(fn.lit.params)
+	case *WBuiltin:
+		return fn.arity
+	}
+	return -1
+}
+
+// builtinMin returns the smallest of its arguments, or of the elements of its
+// only argument, e.g. min(xs) or min(a, b), the first is returned if several
+// are the smallest
+func builtinMin(i *Interpreter, node *CallExpr, args []WType) WType {
+	return i.extremum("min", args, node, func(a, b WType) bool { return i.less(a, b, node) })
+}
+
+// builtinMax returns the largest of its arguments, or of the elements of its
+// only argument, e.g. max(xs) or max(a, b), the first is returned if several
+// are the largest
+func builtinMax(i *Interpreter, node *CallExpr, args []WType) WType {
+	return i.extremum("max", args, node, func(a, b WType) bool { return i.less(b, a, node) })
+}
+
+// extremum returns the first of the arguments, or of the elements of the only
+// argument, that no other comes before
+func (i *Interpreter) extremum(fn string, args []WType, node *CallExpr, before func(a, b WType) bool) WType {
+	if len(args) == 0 {
+		i.typeErrorf("%s() takes at least 1 argument(s) but 0 were given", node, fn)
+	}
+	if len(args) == 1 {
+		args = i.elements(args[0], node)
+		if len(args) == 0 {
+			i.valueErrorf("%s() argument is empty", node, fn)
+		}
+	}
+	res := args[0]
+	for _, arg := range args[1:] {
+		if before(arg, res) {
+			res = arg
+		}
+	}
+	return res
+}
+
+// builtinSum returns the sum of the numbers of an iterable, 0 if it is empty
+func builtinSum(i *Interpreter, node *CallExpr, args []WType) WType {
+	var res WNum
+	for _, x := range i.elements(args[0], node) {
+		res += i.numArg("sum", x, node)
+	}
+	return res
+}
+
+// builtinAbs returns the absolute value of a number
+func builtinAbs(i *Interpreter, node *CallExpr, args []WType) WType {
+	return WNum(math.Abs(float64(i.numArg("abs", args[0], node))))
+}
+
+// builtinRound returns a number rounded to the nearest integer, or to the given
+// number of digits after the decimal point, e.g. round(2.5) is 3 and
+// round(3.14159, 2) is 3.14. Halves are rounded away from zero.
+func builtinRound(i *Interpreter, node *CallExpr, args []WType) WType {
+	if len(args) < 1 || len(args) > 2 {
+		i.typeErrorf("round() takes 1 or 2 argument(s) but %d were given", node, len(args))
+	}
+	x := float64(i.numArg("round", args[0], node))
+	if len(args) == 1 {
+		return WNum(math.Round(x))
+	}
+	scale := math.Pow(10, float64(i.intArg("round", args[1], node)))
+	return WNum(math.Round(x*scale) / scale)
+}
@@ -0,0 +1,345 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+// CheckError is a broken invariant of an AST found by Check
+type CheckError struct {
+	Pos  token.Pos // position of the node breaking the invariant, if known
+	Node Node
+	Msg  string
+}
+
+// Error returns the error in the form line:col: msg
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos.String(), e.Msg)
+}
+
+// CheckErrorList is the error returned by Check, holding the broken invariants
+// in the order they were found
+type CheckErrorList []*CheckError
+
+// Error returns the errors one per line
+func (el CheckErrorList) Error() string {
+	msgs := make([]string, len(el))
+	for i, e := range el {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Check validates the invariants of the AST rooted at node that Parse always
+// upholds, so that ASTs built by hand or returned by Rewrite are caught before
+// they are printed or run. It reports required children that are nil, lists
+// that must not be empty, operators and literals of unexpected token types,
+// and nodes that end before they start or are not enclosed by their parent.
+// Nodes at the zero Pos are taken to have no known position and their
+// positions are not checked. The error returned is a CheckErrorList, or nil if
+// the AST is valid.
+func Check(node Node) error {
+	c := &checker{}
+	if isNilNode(node) {
+		c.errorf(node, "nil node")
+	} else {
+		c.check(node)
+	}
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return c.errors
+}
+
+// checker walks the AST, recording the broken invariants of each node
+type checker struct {
+	errors   CheckErrorList
+	children []Node // the checked children of the node being checked
+}
+
+// nodePos returns the position of the node, or the zero Pos if it cannot be
+// computed because a child is missing
+func nodePos(n Node) (pos token.Pos) {
+	defer func() {
+		if recover() != nil {
+			pos = 0
+		}
+	}()
+	return n.Pos()
+}
+
+// nodeEnd returns the end of the node, or the zero Pos if it cannot be
+// computed because a child is missing
+func nodeEnd(n Node) (end token.Pos) {
+	defer func() {
+		if recover() != nil {
+			end = 0
+		}
+	}()
+	return n.End()
+}
+
+func (c *checker) errorf(n Node, format string, args ...interface{}) {
+	var pos token.Pos
+	if !isNilNode(n) {
+		pos = nodePos(n)
+	}
+	c.errors = append(c.errors, &CheckError{Pos: pos, Node: n, Msg: fmt.Sprintf(format, args...)})
+}
+
+// check checks the node and its children, the positions of the node are only
+// checked if no other invariant of its subtree is broken
+func (c *checker) check(n Node) {
+	before := len(c.errors)
+	siblings := c.children
+	c.children = nil
+	switch x := n.(type) {
+	case *File:
+		c.stmts(x, "statement", x.stmts, false)
+	case *ExprStmt:
+		c.exprs(x, "expression", x.exprs, true)
+	case *AssignStmt:
+		c.assign(x, x.left, x.right, true)
+	case *PlusAssignStmt:
+		c.assign(x, x.left, x.right, false)
+	case *MinusAssignStmt:
+		c.assign(x, x.left, x.right, false)
+	case *DivAssignStmt:
+		c.assign(x, x.left, x.right, false)
+	case *MultAssignStmt:
+		c.assign(x, x.left, x.right, false)
+	case *ModAssignStmt:
+		c.assign(x, x.left, x.right, false)
+	case *VarDecl:
+		c.idents(x, "name", x.names, true)
+		c.exprs(x, "value", x.values, false)
+		if len(x.values) > 0 && len(x.values) != len(x.names) {
+			c.errorf(x, "%T declares %d variables but has %d values", x, len(x.names), len(x.values))
+		}
+	case *FuncDecl:
+		if x.name == nil {
+			c.errorf(x, "%T has no name", x)
+		} else {
+			c.check(x.name)
+		}
+		if x.fn == nil {
+			c.errorf(x, "%T has no function literal", x)
+		} else {
+			c.check(x.fn)
+		}
+	case *BlockStmt:
+		c.stmts(x, "statement", x.stmts, false)
+	case *IfStmt:
+		c.required(x, "condition", x.cond)
+		c.block(x, x.body)
+		switch x.elseStmt.(type) {
+		case nil, *IfStmt, *BlockStmt:
+			c.optional(x.elseStmt)
+		default:
+			c.errorf(x, "%T has an else branch of type %T, expected *lang.IfStmt or *lang.BlockStmt", x, x.elseStmt)
+		}
+	case *WhileStmt:
+		c.required(x, "condition", x.cond)
+		c.block(x, x.body)
+	case *ForStmt:
+		if x.key == nil {
+			c.errorf(x, "%T has no key", x)
+		} else {
+			c.check(x.key)
+		}
+		if x.value != nil {
+			c.check(x.value)
+		}
+		c.required(x, "iterable", x.iter)
+		c.block(x, x.body)
+	case *BranchStmt:
+		if x.Type != token.BREAK && x.Type != token.CONT {
+			c.errorf(x, "%T has token %s, expected break or continue", x, x.Type)
+		}
+	case *ReturnStmt:
+		if x.Type != token.RETURN {
+			c.errorf(x, "%T has token %s, expected return", x, x.Type)
+		}
+		c.optional(x.result)
+	case *YieldStmt:
+		if x.Type != token.YIELD {
+			c.errorf(x, "%T has token %s, expected yield", x, x.Type)
+		}
+		c.required(x, "value", x.value)
+	case *BinExpr:
+		if _, ok := binaryPrecs[x.op.Type]; !ok {
+			c.errorf(x, "%T has operator %s, expected a binary operator", x, x.op.Type)
+		}
+		c.required(x, "left operand", x.left)
+		c.required(x, "right operand", x.right)
+	case *UnExpr:
+		if _, ok := unaryPrecs[x.op.Type]; !ok {
+			c.errorf(x, "%T has operator %s, expected a prefix operator", x, x.op.Type)
+		}
+		c.required(x, "operand", x.operand)
+	case *GrpExpr:
+		c.required(x, "expression", x.x)
+	case *CallExpr:
+		c.required(x, "function", x.fn)
+		c.exprs(x, "argument", x.args, false)
+	case *IndexExpr:
+		c.required(x, "operand", x.x)
+		c.required(x, "index", x.index)
+	case *SliceExpr:
+		c.required(x, "operand", x.x)
+		c.optional(x.lo)
+		c.optional(x.hi)
+	case *BasicLit:
+		switch x.Type {
+		case token.STR, token.CHAR, token.INT, token.FLOAT, token.FALSE, token.TRUE, token.NULL:
+		default:
+			c.errorf(x, "%T has token %s, expected a literal", x, x.Type)
+		}
+	case *List:
+		c.exprs(x, "element", x.elements, false)
+	case *Tuple:
+		c.exprs(x, "element", x.elements, false)
+		if x.LRoundPos == 0 && len(x.elements) < 2 {
+			c.errorf(x, "%T without round brackets has %d elements, expected at least 2", x, len(x.elements))
+		}
+	case *Map:
+		c.exprs(x, "key", x.keys, false)
+		c.exprs(x, "value", x.values, false)
+		if len(x.keys) != len(x.values) {
+			c.errorf(x, "%T has %d keys but %d values", x, len(x.keys), len(x.values))
+		}
+	case *Comprehension:
+		c.optional(x.key)
+		c.required(x, "value", x.value)
+		if x.forKey == nil {
+			c.errorf(x, "%T has no key to bind", x)
+		} else {
+			c.check(x.forKey)
+		}
+		if x.forValue != nil {
+			c.check(x.forValue)
+		}
+		c.required(x, "iterable", x.iter)
+		c.optional(x.cond)
+	case *FuncLit:
+		c.idents(x, "parameter", x.params, false)
+		c.block(x, x.body)
+	case *Ident:
+		if x.Name == "" {
+			c.errorf(x, "%T has no name", x)
+		}
+	default:
+		c.errorf(n, "unexpected node %T", n)
+	}
+	if len(c.errors) == before {
+		c.positions(n)
+	}
+	c.children = append(siblings, n)
+}
+
+// positions checks that the node does not end before it starts, and that its
+// children are enclosed by it
+func (c *checker) positions(n Node) {
+	pos, end := n.Pos(), n.End()
+	if pos == 0 {
+		return
+	}
+	if end < pos {
+		c.errorf(n, "%T ends at %s before it starts", n, end)
+		return
+	}
+	for _, child := range c.children {
+		if cpos, cend := child.Pos(), child.End(); cpos != 0 && (cpos < pos || cend > end) {
+			c.errorf(child, "%T at %s-%s is outside of its parent %T at %s-%s",
+				child, cpos, cend, n, pos, end)
+		}
+	}
+}
+
+// required checks a child that must not be nil
+func (c *checker) required(parent Node, what string, x Expr) {
+	if isNilNode(x) {
+		c.errorf(parent, "%T has no %s", parent, what)
+		return
+	}
+	c.check(x)
+}
+
+// optional checks a child that may be nil
+func (c *checker) optional(n Node) {
+	if !isNilNode(n) {
+		c.check(n)
+	}
+}
+
+// block checks a body that must not be nil
+func (c *checker) block(parent Node, body *BlockStmt) {
+	if body == nil {
+		c.errorf(parent, "%T has no body", parent)
+		return
+	}
+	c.check(body)
+}
+
+// exprs checks a list of expressions, none of which may be nil
+func (c *checker) exprs(parent Node, what string, xs []Expr, nonEmpty bool) {
+	if nonEmpty && len(xs) == 0 {
+		c.errorf(parent, "%T has no %s", parent, what)
+	}
+	for i, x := range xs {
+		if isNilNode(x) {
+			c.errorf(parent, "%T has a nil %s at index %d", parent, what, i)
+			continue
+		}
+		c.check(x)
+	}
+}
+
+// stmts checks a list of statements, none of which may be nil
+func (c *checker) stmts(parent Node, what string, xs []Stmt, nonEmpty bool) {
+	if nonEmpty && len(xs) == 0 {
+		c.errorf(parent, "%T has no %s", parent, what)
+	}
+	for i, x := range xs {
+		if isNilNode(x) {
+			c.errorf(parent, "%T has a nil %s at index %d", parent, what, i)
+			continue
+		}
+		c.check(x)
+	}
+}
+
+// idents checks a list of names, none of which may be nil
+func (c *checker) idents(parent Node, what string, xs []*Ident, nonEmpty bool) {
+	if nonEmpty && len(xs) == 0 {
+		c.errorf(parent, "%T has no %s", parent, what)
+	}
+	for i, x := range xs {
+		if x == nil {
+			c.errorf(parent, "%T has a nil %s at index %d", parent, what, i)
+			continue
+		}
+		c.check(x)
+	}
+}
+
+// assign checks the targets and values of an assignment, only a plain
+// assignment may have multiple targets
+func (c *checker) assign(n Stmt, left, right []Expr, multiple bool) {
+	c.exprs(n, "target", left, true)
+	c.exprs(n, "value", right, true)
+	for _, target := range left {
+		switch target.(type) {
+		case nil, *Ident, *IndexExpr:
+		default:
+			c.errorf(n, "%T cannot assign to %T", n, target)
+		}
+	}
+	if len(left) != len(right) && !(multiple && len(right) == 1) {
+		c.errorf(n, "%T has %d targets but %d values", n, len(left), len(right))
+	} else if !multiple && len(left) > 1 {
+		c.errorf(n, "%T has %d targets, expected 1", n, len(left))
+	}
+}
@@ -0,0 +1,84 @@
+package lang
+
+import (
+	"testing"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+func TestCheckParsed(t *testing.T) {
+	for _, input := range []string{
+		"x = 1 + 2 * -y",
+		"var a, b = 1, 2",
+		"a, b = 1, 2\nc = (a, b)",
+		"func f(a) { if a { return } elif !a { yield a } else { return (a) } }",
+		"for k, v in {'a': [1, 2.5, c'x'], 'b': (1,)} { while k { break } }",
+		"s = xs[1:][:2] + [x * x for x in xs if x > 1]",
+		"x += 1",
+	} {
+		f, err := ParseFile("check", input)
+		if err != nil {
+			t.Fatalf("%q: %s", input, err)
+		}
+		if err := Check(f); err != nil {
+			t.Errorf("%q: got errors\n%s\nexpected none", input, err)
+		}
+	}
+}
+
+func TestCheckMalformed(t *testing.T) {
+	pos := func(col int) token.Pos { return token.NewPos(1, col) }
+	name := func(s string, col int) *Ident {
+		return newID(token.Token{Type: token.NAME, Value: s, Pos: pos(col), End: pos(col + len(s))})
+	}
+	lit := func(s string, col int) *BasicLit {
+		return newBasicLit(token.Token{Type: token.INT, Value: s, Pos: pos(col), End: pos(col + len(s))})
+	}
+	plus := token.Token{Type: token.PLUS, Value: "+", Pos: pos(3)}
+	for _, tc := range []struct {
+		name     string
+		node     Node
+		expected string // the first error
+	}{
+		{"nil node", nil, "0:0: nil node"},
+		{"missing operand", newBinExpr(nil, lit("2", 5), plus), "0:0: *lang.BinExpr has no left operand"},
+		{"not a binary operator", newBinExpr(lit("1", 1), lit("2", 5), token.Token{Type: token.ASSIGN, Pos: pos(3)}),
+			"1:1: *lang.BinExpr has operator =, expected a binary operator"},
+		{"not a prefix operator", newUnExpr(name("x", 2), token.Token{Type: token.MULT, Pos: pos(1)}),
+			"1:1: *lang.UnExpr has operator *, expected a prefix operator"},
+		{"not a literal", newBasicLit(token.Token{Type: token.NAME, Value: "x", Pos: pos(1), End: pos(2)}),
+			"1:1: *lang.BasicLit has token NAME, expected a literal"},
+		{"empty name", name("", 1), "1:1: *lang.Ident has no name"},
+		{"empty expression statement", newExprStmt(nil), "0:0: *lang.ExprStmt has no expression"},
+		{"nil statement", &File{stmts: []Stmt{nil}}, "0:0: *lang.File has a nil statement at index 0"},
+		{"assignment to a literal", newAssignStmt([]Expr{lit("1", 1)}, []Expr{lit("2", 5)}, token.ASSIGN),
+			"1:1: *lang.AssignStmt cannot assign to *lang.BasicLit"},
+		{"compound assignment of many targets", newAssignStmt([]Expr{name("a", 1), name("b", 4)},
+			[]Expr{lit("1", 9)}, token.PLUSASSIGN), "1:1: *lang.PlusAssignStmt has 2 targets but 1 values"},
+		{"values of a declaration", newVarDecl([]*Ident{name("a", 5), name("b", 8)}, []Expr{lit("1", 12)},
+			token.Token{Type: token.VAR, Pos: pos(1)}), "1:1: *lang.VarDecl declares 2 variables but has 1 values"},
+		{"keys of a map", newMap([]Expr{lit("1", 2)}, nil, token.Token{Pos: pos(1)}, token.Token{Pos: pos(4)}),
+			"1:1: *lang.Map has 1 keys but 0 values"},
+		{"tuple of one element without brackets", newTuple([]Expr{lit("1", 1)}, token.Token{}, token.Token{}),
+			"1:1: *lang.Tuple without round brackets has 1 elements, expected at least 2"},
+		{"else branch", newIfStmt(name("x", 4), newBlockStmt(nil, token.Token{Pos: pos(6)}, token.Token{Pos: pos(7)}),
+			newExprStmt([]Expr{name("y", 14)}), token.Token{Type: token.IF, Pos: pos(1)}),
+			"1:1: *lang.IfStmt has an else branch of type *lang.ExprStmt, expected *lang.IfStmt or *lang.BlockStmt"},
+		{"missing body", newWhileStmt(name("x", 7), nil, token.Token{Type: token.WHILE, Pos: pos(1)}),
+			"1:1: *lang.WhileStmt has no body"},
+		{"end before the start", newID(token.Token{Type: token.NAME, Value: "x", Pos: pos(5), End: pos(1)}),
+			"1:5: *lang.Ident ends at 1:1 before it starts"},
+		{"child outside of its parent", newGrpExpr(name("x", 9), token.Token{Pos: pos(1)}, token.Token{Pos: pos(3)}),
+			"1:9: *lang.Ident at 1:9-1:10 is outside of its parent *lang.GrpExpr at 1:1-1:4"},
+	} {
+		err := Check(tc.node)
+		errs, ok := err.(CheckErrorList)
+		if !ok || len(errs) == 0 {
+			t.Errorf("%s: got error %v, expected a lang.CheckErrorList", tc.name, err)
+			continue
+		}
+		if got := errs[0].Error(); got != tc.expected {
+			t.Errorf("%s: got error %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package lang
+
+import "github.com/lohvht/went/lang/token"
+
+// Desugar lowers the syntactic sugar of the AST rooted at node into the core
+// nodes, so that later stages only need to handle the core AST. The original
+// AST is not modified, see Rewrite.
+//
+// A compound assignment "x op= y" is lowered to "x = x op y" if evaluating its
+// target twice has no side effects, i.e. it is a name or an index whose
+// operands are names or literals. Other compound assignments are kept as they
+// evaluate the operands of their target only once.
+//
+// Compound assignments are the only sugar lowered, ranges are calls of the
+// range builtin rather than syntax of their own and strings have no
+// interpolation. The interpreter runs the AST as parsed, Desugar is for the
+// tools that only handle the core nodes.
+func Desugar(node Node) Node { return Rewrite(node, desugar) }
+
+// desugar lowers a single node, returning it unchanged if it is not sugar
+func desugar(n Node) Node {
+	switch x := n.(type) {
+	case *PlusAssignStmt:
+		return lowerAugAssign(x, x.left, x.right, token.PLUS, "+")
+	case *MinusAssignStmt:
+		return lowerAugAssign(x, x.left, x.right, token.MINUS, "-")
+	case *DivAssignStmt:
+		return lowerAugAssign(x, x.left, x.right, token.DIV, "/")
+	case *MultAssignStmt:
+		return lowerAugAssign(x, x.left, x.right, token.MULT, "*")
+	case *ModAssignStmt:
+		return lowerAugAssign(x, x.left, x.right, token.MOD, "%")
+	}
+	return n
+}
+
+// lowerAugAssign returns the plain assignment of the compound assignment n,
+// or n if its target cannot be evaluated twice
+func lowerAugAssign(n Stmt, left, right []Expr, op token.Type, opStr string) Stmt {
+	if len(left) != 1 || len(right) != 1 || !pure(left[0]) {
+		return n
+	}
+	target := left[0]
+	// the operator has no position of its own in a compound assignment, it
+	// takes the position of the target as in the interpreter
+	value := newBinExpr(target, right[0], token.Token{Type: op, Value: opStr, Pos: target.Pos()})
+	return newAssignStmt([]Expr{target}, []Expr{value}, token.ASSIGN)
+}
+
+// pure reports whether evaluating the expression has no side effects, it only
+// holds for names, literals and indexes of those
+func pure(x Expr) bool {
+	switch x := x.(type) {
+	case *Ident, *BasicLit:
+		return true
+	case *GrpExpr:
+		return pure(x.x)
+	case *IndexExpr:
+		return pure(x.x) && pure(x.index)
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDesugar(t *testing.T) {
+	for _, tc := range []struct {
+		input, expected string // expected is the source of the desugared AST
+	}{
+		{"x = 1\nx *= 2 + 3", "x = 1\nx = x * (2 + 3)"},
+		{"x = 10\nx -= 2 - 3", "x = 10\nx = x - (2 - 3)"},
+		{"x = 20\nx %= 5 + 2", "x = 20\nx = x % (5 + 2)"},
+		{"x = 8\nx /= 2 * 2", "x = 8\nx = x / (2 * 2)"},
+		{"x = 7\nx %= 4", "x = 7\nx = x % 4"},
+		{"x = 'a'\nx += 'b'", "x = 'a'\nx = x + 'b'"},
+		{"m = {'a': 1}\nm['a'] += 2", "m = {'a': 1}\nm['a'] = m['a'] + 2"},
+		{"x = 1\nfunc f(a) { a *= 2 + 1; return a }\ny = f(x)",
+			"x = 1\nfunc f(a) {\n\ta = a * (2 + 1)\n\treturn a\n}\ny = f(x)"},
+		// the target is kept as it has side effects, evaluating it twice
+		// would call f twice
+		{"n = 0\nxs = [1, 2]\nfunc f() { n += 1; return 0 }\nxs[f()] += 5",
+			"n = 0\nxs = [1, 2]\nfunc f() {\n\tn = n + 1\n\treturn 0\n}\nxs[f()] += 5"},
+		{"x = 1\ny = x + 2", "x = 1\ny = x + 2"},
+	} {
+		f, err := ParseFile("desugar", tc.input)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.input, err)
+		}
+		d := Desugar(f)
+		if got := strings.TrimSpace(new(SourcePrinter).Print(d)); got != tc.expected {
+			t.Errorf("%q: got\n%s\nexpected\n%s", tc.input, got, tc.expected)
+		}
+		if err := Check(d); err != nil {
+			t.Errorf("%q: invalid AST:\n%s", tc.input, err)
+		}
+		if got := strings.TrimSpace(new(SourcePrinter).Print(f)); !Equal(f, mustParse(t, tc.input), false) {
+			t.Errorf("%q: the original AST was modified to\n%s", tc.input, got)
+		}
+		// the desugared AST evaluates to the same values
+		i1, err1 := Interpret(f)
+		i2, err2 := Interpret(d)
+		if err1 != nil || err2 != nil {
+			t.Errorf("%q: got errors %v and %v", tc.input, err1, err2)
+			continue
+		}
+		for name, v := range i1.Env() {
+			if _, ok := v.(*WFunc); ok {
+				continue
+			}
+			if !bool(v.Equals(i2.Env()[name])) {
+				t.Errorf("%q: got %s = %v once desugared, expected %v", tc.input, name, i2.Env()[name], v)
+			}
+		}
+	}
+}
+
+func mustParse(t *testing.T, input string) *File {
+	t.Helper()
+	f, err := ParseFile("desugar", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
@@ -0,0 +1,142 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+// Diagnostic is an error or a warning reported by one of the phases run over
+// an input, i.e. the lexer, the parser, the lint checks or the interpreter
+type Diagnostic struct {
+	Name     string    // name of the input, empty if the position is not known
+	Pos, End token.Pos // the zero Pos if the position is not known
+	Severity token.Severity
+	Code     string // the stable code of the diagnostic, if any
+	Kind     string // kind of the diagnostic, e.g. "SyntaxError", if any
+	Msg      string
+	Line     string       // the line of the input at Pos, without its line ending
+	Notes    []token.Note // secondary messages about other positions of the input
+}
+
+// Error returns the diagnostic in the form name:line:col: kind - msg
+func (d *Diagnostic) Error() string {
+	msg := d.Msg
+	if d.Kind != "" {
+		msg = d.Kind + " - " + msg
+	}
+	if d.Name == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s:%s: %s", d.Name, d.Pos, msg)
+}
+
+// Diagnostics collects the diagnostics of all the phases run over an input, so
+// that they may be reported together in the order they were found. The errors
+// of each phase are added as they are returned, e.g.
+//
+//	diags := NewDiagnostics(input)
+//	f, err := ParseFile(name, input)
+//	diags.Add(err)
+//	if err == nil {
+//		diags.AddLint(name, Lint(f))
+//	}
+type Diagnostics struct {
+	input string
+	list  []*Diagnostic
+}
+
+// NewDiagnostics returns an empty collector for the diagnostics of the input
+func NewDiagnostics(input string) *Diagnostics { return &Diagnostics{input: input} }
+
+// Add adds the diagnostics of the error returned by a phase, the errors of the
+// lexer, the syntax errors of Parse, the runtime errors of Interpret and the
+// errors of Check are at a position of the input, other errors are added as
+// they are. A nil error is ignored.
+func (ds *Diagnostics) Add(err error) {
+	switch err := err.(type) {
+	case nil:
+	case token.ErrorList:
+		for _, e := range err {
+			ds.Add(e)
+		}
+	case *token.Error:
+		notes := make([]token.Note, len(err.Notes))
+		for i, n := range err.Notes {
+			if n.Line == "" {
+				n.Line = token.SourceLine(ds.input, n.Pos.Line())
+			}
+			notes[i] = n
+		}
+		ds.add(&Diagnostic{Name: err.Name, Pos: err.Pos, End: err.End, Severity: err.Severity,
+			Code: err.Code, Msg: err.Msg, Notes: notes})
+	case SyntaxErrorList:
+		for _, e := range err {
+			ds.Add(e)
+		}
+	case *SyntaxError:
+		ds.add(&Diagnostic{Name: err.Name, Pos: err.Pos, End: err.End, Code: err.Code,
+			Kind: "SyntaxError", Msg: err.Msg, Line: err.Line, Notes: err.Notes})
+	case *RuntimeError:
+		if err.Name == "" {
+			ds.add(&Diagnostic{Code: err.Code, Msg: err.Error()})
+			return
+		}
+		ds.add(&Diagnostic{Name: err.Name, Pos: err.Pos, End: err.End, Code: err.Code,
+			Kind: err.Kind, Msg: err.Msg})
+	case CheckErrorList:
+		for _, e := range err {
+			ds.add(&Diagnostic{Msg: e.Error()})
+		}
+	default:
+		ds.add(&Diagnostic{Msg: err.Error()})
+	}
+}
+
+// AddLint adds the issues reported by the lint checks over the input of the
+// given name
+func (ds *Diagnostics) AddLint(name string, issues []LintIssue) {
+	for _, issue := range issues {
+		ds.add(&Diagnostic{Name: name, Pos: issue.Pos, End: issue.End, Severity: issue.Severity,
+			Code: issue.CheckID, Kind: issue.CheckID, Msg: issue.Message})
+	}
+}
+
+// add adds the diagnostic, filling in its line of the input, its end is its
+// position if it is not known
+func (ds *Diagnostics) add(d *Diagnostic) {
+	if d.End < d.Pos {
+		d.End = d.Pos
+	}
+	if d.Name != "" && d.Line == "" {
+		d.Line = token.SourceLine(ds.input, d.Pos.Line())
+	}
+	ds.list = append(ds.list, d)
+}
+
+// List returns the diagnostics in the order they were added
+func (ds *Diagnostics) List() []*Diagnostic { return ds.list }
+
+// Len returns the number of diagnostics
+func (ds *Diagnostics) Len() int { return len(ds.list) }
+
+// HasErrors reports whether a diagnostic of SeverityError was added, i.e. one
+// that is not only a warning
+func (ds *Diagnostics) HasErrors() bool {
+	for _, d := range ds.list {
+		if d.Severity == token.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Error returns the diagnostics one per line
+func (ds *Diagnostics) Error() string {
+	msgs := make([]string, len(ds.list))
+	for i, d := range ds.list {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,160 @@
+package lang
+
+// Equal reports whether the ASTs rooted at a and b are structurally equal, i.e.
+// they have nodes of the same types holding the same operators, names and
+// literals. The positions of the nodes are compared as well unless
+// ignorePositions is set, in which case two ASTs parsed from differently
+// formatted sources of the same script are equal. Round brackets are kept in
+// the AST and are compared like any other node, comments are not part of the
+// AST and are not compared.
+func Equal(a, b Node, ignorePositions bool) bool {
+	return equaler{positions: !ignorePositions}.equal(a, b)
+}
+
+// equaler compares two ASTs, and their positions if set
+type equaler struct{ positions bool }
+
+func (eq equaler) equal(a, b Node) bool {
+	if isNilNode(a) || isNilNode(b) {
+		return isNilNode(a) && isNilNode(b)
+	}
+	if eq.positions && (a.Pos() != b.Pos() || a.End() != b.End()) {
+		return false
+	}
+	switch x := a.(type) {
+	case *File:
+		y, ok := b.(*File)
+		return ok && eq.stmts(x.stmts, y.stmts)
+	case *ExprStmt:
+		y, ok := b.(*ExprStmt)
+		return ok && eq.exprs(x.exprs, y.exprs)
+	case *AssignStmt:
+		y, ok := b.(*AssignStmt)
+		return ok && eq.exprs(x.left, y.left) && eq.exprs(x.right, y.right)
+	case *PlusAssignStmt:
+		y, ok := b.(*PlusAssignStmt)
+		return ok && eq.exprs(x.left, y.left) && eq.exprs(x.right, y.right)
+	case *MinusAssignStmt:
+		y, ok := b.(*MinusAssignStmt)
+		return ok && eq.exprs(x.left, y.left) && eq.exprs(x.right, y.right)
+	case *DivAssignStmt:
+		y, ok := b.(*DivAssignStmt)
+		return ok && eq.exprs(x.left, y.left) && eq.exprs(x.right, y.right)
+	case *MultAssignStmt:
+		y, ok := b.(*MultAssignStmt)
+		return ok && eq.exprs(x.left, y.left) && eq.exprs(x.right, y.right)
+	case *ModAssignStmt:
+		y, ok := b.(*ModAssignStmt)
+		return ok && eq.exprs(x.left, y.left) && eq.exprs(x.right, y.right)
+	case *VarDecl:
+		y, ok := b.(*VarDecl)
+		return ok && eq.idents(x.names, y.names) && eq.exprs(x.values, y.values)
+	case *FuncDecl:
+		y, ok := b.(*FuncDecl)
+		return ok && eq.equal(x.name, y.name) && eq.equal(x.fn, y.fn)
+	case *BlockStmt:
+		y, ok := b.(*BlockStmt)
+		return ok && eq.stmts(x.stmts, y.stmts)
+	case *IfStmt:
+		y, ok := b.(*IfStmt)
+		return ok && eq.equal(x.cond, y.cond) && eq.equal(x.body, y.body) &&
+			eq.equal(x.elseStmt, y.elseStmt)
+	case *WhileStmt:
+		y, ok := b.(*WhileStmt)
+		return ok && eq.equal(x.cond, y.cond) && eq.equal(x.body, y.body)
+	case *ForStmt:
+		y, ok := b.(*ForStmt)
+		return ok && eq.equal(x.key, y.key) && eq.equal(x.value, y.value) &&
+			eq.equal(x.iter, y.iter) && eq.equal(x.body, y.body)
+	case *BranchStmt:
+		y, ok := b.(*BranchStmt)
+		return ok && x.Type == y.Type
+	case *ReturnStmt:
+		y, ok := b.(*ReturnStmt)
+		return ok && eq.equal(x.result, y.result)
+	case *YieldStmt:
+		y, ok := b.(*YieldStmt)
+		return ok && eq.equal(x.value, y.value)
+	case *BinExpr:
+		y, ok := b.(*BinExpr)
+		return ok && x.op.Type == y.op.Type && (!eq.positions || x.opPos == y.opPos) &&
+			eq.equal(x.left, y.left) && eq.equal(x.right, y.right)
+	case *UnExpr:
+		y, ok := b.(*UnExpr)
+		return ok && x.op.Type == y.op.Type && eq.equal(x.operand, y.operand)
+	case *GrpExpr:
+		y, ok := b.(*GrpExpr)
+		return ok && eq.equal(x.x, y.x)
+	case *CallExpr:
+		y, ok := b.(*CallExpr)
+		return ok && (!eq.positions || x.LRoundPos == y.LRoundPos) &&
+			eq.equal(x.fn, y.fn) && eq.exprs(x.args, y.args)
+	case *IndexExpr:
+		y, ok := b.(*IndexExpr)
+		return ok && (!eq.positions || x.LSqPos == y.LSqPos) &&
+			eq.equal(x.x, y.x) && eq.equal(x.index, y.index)
+	case *SliceExpr:
+		y, ok := b.(*SliceExpr)
+		return ok && (!eq.positions || x.LSqPos == y.LSqPos) &&
+			eq.equal(x.x, y.x) && eq.equal(x.lo, y.lo) && eq.equal(x.hi, y.hi)
+	case *BasicLit:
+		y, ok := b.(*BasicLit)
+		return ok && x.Type == y.Type && x.Text == y.Text
+	case *List:
+		y, ok := b.(*List)
+		return ok && eq.exprs(x.elements, y.elements)
+	case *Tuple:
+		y, ok := b.(*Tuple)
+		return ok && (!eq.positions || x.LRoundPos == y.LRoundPos) && eq.exprs(x.elements, y.elements)
+	case *Map:
+		y, ok := b.(*Map)
+		return ok && eq.exprs(x.keys, y.keys) && eq.exprs(x.values, y.values)
+	case *Comprehension:
+		y, ok := b.(*Comprehension)
+		return ok && eq.equal(x.key, y.key) && eq.equal(x.value, y.value) && eq.equal(x.forKey, y.forKey) &&
+			eq.equal(x.forValue, y.forValue) && eq.equal(x.iter, y.iter) && eq.equal(x.cond, y.cond)
+	case *FuncLit:
+		y, ok := b.(*FuncLit)
+		return ok && eq.idents(x.params, y.params) && eq.equal(x.body, y.body)
+	case *Ident:
+		y, ok := b.(*Ident)
+		return ok && x.Name == y.Name
+	}
+	return false
+}
+
+func (eq equaler) exprs(xs, ys []Expr) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.equal(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq equaler) stmts(xs, ys []Stmt) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.equal(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq equaler) idents(xs, ys []*Ident) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.equal(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package lang
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b                      string
+		equal, equalWithPositions bool
+	}{
+		{"x = 1 + 2", "x = 1 + 2", true, true},
+		{"x = 1 + 2", "x   =   1+2", true, false},
+		{"func f(a) { return a }", "func f(a) {\n\treturn a\n}", true, false},
+		{"x = [1, 2] // comment", "x = [1, 2]", true, true},
+		{"if x { y } else { z }", "if x {\n\ty\n} else {\n\tz\n}", true, false},
+		{"x = 1 + 2", "x = 1 - 2", false, false},
+		{"x = 1 + 2", "x = (1 + 2)", false, false},
+		{"x = 'a'", "x = `a`", true, true},
+		{"x = 1", "x = 1.0", false, false},
+		{"x = 1", "y = 1", false, false},
+		{"x = 1", "x += 1", false, false},
+		{"f(a, b)", "f(a)", false, false},
+		{"func f() { return }", "func f() { return null }", false, false},
+		{"if x { y }", "if x { y } else { z }", false, false},
+		{"for k in m { }", "for k, v in m { }", false, false},
+		{"x = 1\ny = 2", "x = 1", false, false},
+	} {
+		a, err := ParseFile("a", tc.a)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.a, err)
+		}
+		b, err := ParseFile("b", tc.b)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.b, err)
+		}
+		if got := Equal(a, b, true); got != tc.equal {
+			t.Errorf("%q and %q: got %t ignoring positions, expected %t", tc.a, tc.b, got, tc.equal)
+		}
+		if got := Equal(a, b, false); got != tc.equalWithPositions {
+			t.Errorf("%q and %q: got %t with positions, expected %t", tc.a, tc.b, got, tc.equalWithPositions)
+		}
+		if Equal(a, b, true) != Equal(b, a, true) || Equal(a, b, false) != Equal(b, a, false) {
+			t.Errorf("%q and %q: Equal is not symmetric", tc.a, tc.b)
+		}
+	}
+}
+
+func TestEqualNil(t *testing.T) {
+	f, err := ParseFile("f", "x = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id *Ident
+	for _, tc := range []struct {
+		a, b  Node
+		equal bool
+	}{
+		{nil, nil, true},
+		{nil, id, true}, // a nil optional child
+		{f, nil, false},
+		{nil, f, false},
+		{f, f, true},
+	} {
+		if got := Equal(tc.a, tc.b, false); got != tc.equal {
+			t.Errorf("%#v and %#v: got %t, expected %t", tc.a, tc.b, got, tc.equal)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// builtinFormat returns its first argument, a format string, with each field
+// in braces replaced by one of the other arguments. A field is of the form
+// {index:spec}, both parts being optional: the index selects an argument, 0
+// being the first after the format string, and defaults to the argument after
+// that of the previous field without an index. The spec is of the form
+//
+//	[<][0][width][.precision][type]
+//
+// where '<' aligns the value to the left of the width rather than the right,
+// '0' pads numbers with zeros, and the type is one of
+//
+//	d   an integer in decimal
+//	x   an integer in hexadecimal
+//	f   a number with precision digits after the decimal point, 6 by default
+//	e   a number in scientific notation
+//	%   a number multiplied by 100 as f, followed by a percent sign
+//	s   the value as printed, which is the default
+//
+// e.g. format('x={} y={:.2f}', 1, 2) is 'x=1 y=2.00'. Braces are written
+// literally as "{{" and "}}".
+func builtinFormat(i *Interpreter, node *CallExpr, args []WType) WType {
+	if len(args) == 0 {
+		i.typeErrorf("format() takes at least 1 argument(s) but 0 were given", node)
+	}
+	f, ok := args[0].(WString)
+	if !ok {
+		i.typeErrorf("format() argument must be a string, not '%s'", node, TypeName(args[0]))
+	}
+	args = args[1:]
+	var b strings.Builder
+	next := 0
+	for s := string(f); s != ""; {
+		k := strings.IndexAny(s, "{}")
+		if k < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:k])
+		if k+1 < len(s) && s[k+1] == s[k] {
+			b.WriteByte(s[k])
+			s = s[k+2:]
+			continue
+		}
+		if s[k] == '}' {
+			i.valueErrorf("single '}' in format string", node)
+		}
+		end := strings.IndexByte(s[k:], '}')
+		if end < 0 {
+			i.valueErrorf("unclosed '{' in format string", node)
+		}
+		field := s[k+1 : k+end]
+		s = s[k+end+1:]
+		index, spec := field, ""
+		if c := strings.IndexByte(field, ':'); c >= 0 {
+			index, spec = field[:c], field[c+1:]
+		}
+		arg := next
+		if index == "" {
+			next++
+		} else if n, err := strconv.Atoi(index); err == nil && n >= 0 {
+			arg = n
+		} else {
+			i.valueErrorf("invalid field {%s} in format string", node, field)
+		}
+		if arg >= len(args) {
+			i.indexErrorf("format() field %d out of range with %d argument(s)", node, arg, len(args))
+		}
+		b.WriteString(i.formatValue(args[arg], spec, node))
+	}
+	return WString(b.String())
+}
+
+// formatValue returns the value formatted by the spec of a field, see
+// builtinFormat
+func (i *Interpreter) formatValue(v WType, spec string, node *CallExpr) string {
+	verb := spec
+	flags := ""
+	if strings.HasPrefix(verb, "<") {
+		flags, verb = "-", verb[1:]
+	}
+	if strings.HasPrefix(verb, "0") {
+		flags, verb = flags+"0", verb[1:]
+	}
+	width := strings.TrimLeft(verb, "0123456789")
+	width, verb = verb[:len(verb)-len(width)], width
+	prec := ""
+	if strings.HasPrefix(verb, ".") {
+		digits := strings.TrimLeft(verb[1:], "0123456789")
+		if len(digits) == len(verb)-1 {
+			i.valueErrorf("missing precision in format spec '%s'", node, spec)
+		}
+		prec, verb = verb[:len(verb)-len(digits)], digits
+	}
+	if len(verb) > 1 || (verb != "" && !strings.Contains("dxfe%s", verb)) {
+		i.valueErrorf("invalid format spec '%s'", node, spec)
+	}
+	format := "%" + flags + width + prec
+	switch verb {
+	case "", "s":
+		if n, ok := v.(WNum); ok && verb == "" && prec != "" {
+			return fmt.Sprintf(format+"g", float64(n))
+		}
+		return fmt.Sprintf(format+"s", Str(v))
+	case "d", "x":
+		n, ok := v.(WNum)
+		if !ok || !n.IsInt() {
+			i.typeErrorf("format code '%s' requires an integer, not '%s'", node, verb, TypeName(v))
+		}
+		return fmt.Sprintf(format+verb, int64(n))
+	case "%":
+		n := i.formatNum(v, verb, node)
+		return fmt.Sprintf(format+"f%%", float64(n)*100)
+	}
+	return fmt.Sprintf(format+verb, float64(i.formatNum(v, verb, node)))
+}
+
+// formatNum returns the value as a number, panicking with a type error if it
+// is not a number
+func (i *Interpreter) formatNum(v WType, verb string, node *CallExpr) WNum {
+	n, ok := v.(WNum)
+	if !ok {
+		i.typeErrorf("format code '%s' requires a number, not '%s'", node, verb, TypeName(v))
+	}
+	return n
+}
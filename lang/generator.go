@@ -0,0 +1,158 @@
+package lang
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// WGenerator is the lazily evaluated sequence of the values yielded by a call
+// of a generator function, i.e. a function whose body has a yield statement.
+// The body runs in its own goroutine, with an interpreter of its own, that is
+// resumed when the next value is needed and suspended at each yield, so that
+// only one of the caller and the body runs at a time.
+type WGenerator struct {
+	name string
+	*generator
+}
+
+// generator is the state of a generator shared with the goroutine running its
+// body, the goroutine never refers to the WGenerator so that a generator that
+// is no longer reachable may be finalized, stopping the goroutine
+type generator struct {
+	interp  *Interpreter      // runs the body in the frame of the call
+	body    *BlockStmt        // body of the generator function
+	resume  chan struct{}     // sent by the caller to run the body up to its next yield
+	yield   chan yieldedValue // sent by the body at each yield and once it is done
+	stop    chan struct{}     // closed once the generator is no longer reachable
+	started bool              // whether the goroutine running the body was started
+	running bool              // whether the body is running, i.e. the generator is being resumed
+	done    bool              // whether the body returned or failed
+}
+
+// yieldedValue is a value yielded by the body of a generator, or the end of
+// the body if done is set, failure holds the panic of a failed body
+type yieldedValue struct {
+	value   WType
+	done    bool
+	failure interface{}
+}
+
+// IsZeroValue always returns false as generators have no zero value
+func (w *WGenerator) IsZeroValue() WBool { return false }
+
+// Equals returns true only if both are the same generator
+func (w *WGenerator) Equals(w2 WType) WBool {
+	v, ok := w2.(*WGenerator)
+	return WBool(ok && v == w)
+}
+
+// Sm will always return false and an error for WGenerator as WGenerator has
+// no order relation
+func (w *WGenerator) Sm(w2 WType, orEq bool) (WBool, error) {
+	operator := sm
+	if orEq {
+		operator = smE
+	}
+	return false, opError(w, w2, operator)
+}
+
+// Gr (see Sm)
+func (w *WGenerator) Gr(w2 WType, orEq bool) (WBool, error) {
+	operator := gr
+	if orEq {
+		operator = grE
+	}
+	return false, opError(w, w2, operator)
+}
+
+func (w *WGenerator) String() string { return fmt.Sprintf("<generator %s>", w.name) }
+
+// isGenerator reports whether the body of the function has a yield statement,
+// not counting those of the functions defined in it
+func isGenerator(lit *FuncLit) bool {
+	found := false
+	Inspect(lit.body, func(n Node) bool {
+		switch n.(type) {
+		case *FuncLit:
+			return false
+		case *YieldStmt:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// newGenerator returns the generator of a call of the generator function, its
+// body runs in the frame f once the first value is needed
+func (i *Interpreter) newGenerator(fn *WFunc, f *frame) *WGenerator {
+	g := &generator{body: fn.lit.body, resume: make(chan struct{}), yield: make(chan yieldedValue),
+		stop: make(chan struct{})}
+	g.interp = &Interpreter{Root: i.Root, name: i.name, ctx: i.ctx, env: i.env, hook: i.hook,
+		stdout: i.stdout, stderr: i.stderr, stdin: i.stdin, frame: f, depth: i.depth + 1, gen: g}
+	w := &WGenerator{name: fn.name, generator: g}
+	runtime.SetFinalizer(w, func(w *WGenerator) { close(w.stop) })
+	return w
+}
+
+// run runs the body of the generator in the goroutine of the generator, a
+// panic of the body is handed to the caller resuming the generator
+func (g *generator) run() {
+	defer func() {
+		select {
+		case g.yield <- yieldedValue{done: true, failure: recover()}:
+		case <-g.stop:
+		}
+	}()
+	g.wait()
+	g.interp.eval(g.body)
+}
+
+// wait suspends the body of the generator until the generator is resumed, the
+// goroutine exits if the generator is no longer reachable
+func (g *generator) wait() {
+	select {
+	case <-g.resume:
+	case <-g.stop:
+		runtime.Goexit()
+	}
+}
+
+// next resumes the generator, returning the next value it yields, or false if
+// its body is done
+func (i *Interpreter) next(w *WGenerator, node Node) (WType, bool) {
+	if w.done {
+		return nil, false
+	}
+	if w.running {
+		i.valueErrorf("generator %s is already running", node, w.name)
+	}
+	if !w.started {
+		w.started = true
+		go w.run()
+	}
+	w.running = true
+	w.resume <- struct{}{}
+	y := <-w.yield
+	w.running = false
+	if y.done {
+		w.done = true
+		if y.failure != nil {
+			panic(y.failure)
+		}
+		return nil, false
+	}
+	return y.value, true
+}
+
+func (i *Interpreter) visitYieldStmt(node *YieldStmt) WType {
+	value := i.eval(node.value)
+	if i.gen == nil {
+		// a yield outside of the body of a generator, e.g. in an AST built by
+		// hand, Parse reports those outside of functions
+		i.fail(node, "", RuntimeOtherError, "yield outside of a generator")
+	}
+	i.gen.yield <- yieldedValue{value: value}
+	i.gen.wait()
+	return nil
+}
@@ -1,37 +1,236 @@
 package lang
 
 import (
+	"context"
 	"fmt"
-	"runtime"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lohvht/went/lang/token"
 )
 
 // Interpreter implements NodeWalker
-// TODO: scopes
 type Interpreter struct {
-	Root Node
-	name string // name of the interpreter, used for debugging purposes
+	Root   Node
+	Result WType           // value of the last statement if it is an expression statement, nil otherwise
+	name   string          // name of the interpreter, used for debugging purposes
+	ctx    context.Context // interpretation is cancelled once ctx is done
+	env    Environment     // values bound to names in the global scope
+	hook   Hook            // called before each node is evaluated, may be nil
+	stack  []Node          // nodes that are being evaluated, innermost last
+	stdout io.Writer       // output of the script, e.g. of print
+	stderr io.Writer       // error output of the script
+	stdin  io.Reader       // input of the script, e.g. of input
+	frame  *frame          // locals of the function being called, nil in the global scope
+	depth  int             // number of nested function calls
+	branch branch          // the jump taken by the last break, continue or return
+	retVal WType           // value returned by the last return statement
+	gen    *generator      // the generator whose body is interpreted, nil if not a generator
+}
+
+// frame holds the values bound to names in the scope of a function call
+type frame struct {
+	locals Environment
+	parent *frame // frame of the scope that the function was defined in, nil for the global scope
+	fn     *WFunc // function called, nil for the for clause of a comprehension
+}
+
+// branch is a jump out of the normal flow of statements, the statements of a
+// block are skipped until the loop or the function call that handles it
+type branch int
+
+const (
+	noBranch branch = iota
+	breakBranch
+	continueBranch
+	returnBranch
+)
+
+// maxCallDepth is the number of nested function calls after which a
+// RecursionError is raised, instead of exhausting the Go stack
+const maxCallDepth = 1000
+
+// Environment holds the values bound to names, it may be shared across
+// interpretations so that the values persist, e.g. within a REPL session
+type Environment map[string]WType
+
+// Hook is called by the interpreter right before it evaluates a node, it allows
+// tools such as debuggers to observe the interpretation
+type Hook func(i *Interpreter, node Node)
+
+// Config holds the optional settings of an interpretation
+type Config struct {
+	Env  Environment // values bound to names in the global scope, a new one is used if nil
+	Hook Hook        // called before each node is evaluated
+
+	// Streams of the script, os.Stdout, os.Stderr and os.Stdin are used if nil
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
 }
 
-// typeErrorf formats the error string before passing into errorf() for panicking
+// Env returns the values bound to names in the global scope
+func (i *Interpreter) Env() Environment { return i.env }
+
+// Stdout returns the writer that the script writes its output to
+func (i *Interpreter) Stdout() io.Writer { return i.stdout }
+
+// Stderr returns the writer that the script writes its errors to
+func (i *Interpreter) Stderr() io.Writer { return i.stderr }
+
+// Stdin returns the reader that the script reads its input from
+func (i *Interpreter) Stdin() io.Reader { return i.stdin }
+
+// Scopes returns the values bound to names in the scopes enclosing the node
+// being evaluated, innermost first and ending with the global scope
+func (i *Interpreter) Scopes() []Environment {
+	var scopes []Environment
+	for f := i.frame; f != nil; f = f.parent {
+		scopes = append(scopes, f.locals)
+	}
+	return append(scopes, i.env)
+}
+
+// Func returns the function whose body is being evaluated, nil outside of any
+// function
+func (i *Interpreter) Func() *WFunc {
+	for f := i.frame; f != nil; f = f.parent {
+		if f.fn != nil {
+			return f.fn
+		}
+	}
+	return nil
+}
+
+// Lookup returns the value bound to the name in the innermost scope that binds
+// it, the global scope being enclosed by the scope of the builtins
+func (i *Interpreter) Lookup(name string) (WType, bool) {
+	for f := i.frame; f != nil; f = f.parent {
+		if v, ok := f.locals[name]; ok {
+			return v, true
+		}
+	}
+	if v, ok := i.env[name]; ok {
+		return v, true
+	}
+	if b, ok := builtins[name]; ok {
+		return b, true
+	}
+	return nil, false
+}
+
+// assign rebinds the name in the innermost scope that binds it, defining it in
+// the current scope if no scope does
+func (i *Interpreter) assign(name string, value WType) {
+	for f := i.frame; f != nil; f = f.parent {
+		if _, ok := f.locals[name]; ok {
+			f.locals[name] = value
+			return
+		}
+	}
+	if _, ok := i.env[name]; ok || i.frame == nil {
+		i.env[name] = value
+		return
+	}
+	i.frame.locals[name] = value
+}
+
+// define binds the name in the current scope
+func (i *Interpreter) define(name string, value WType) {
+	if i.frame == nil {
+		i.env[name] = value
+		return
+	}
+	i.frame.locals[name] = value
+}
+
+// Stack returns the nodes that are currently being evaluated, from the root to
+// the innermost node
+func (i *Interpreter) Stack() []Node { return append([]Node(nil), i.stack...) }
+
+// Codes of the runtime errors, these are stable and may be used to refer to a
+// specific error, e.g. in the documentation
+const (
+	RuntimeOtherError        = "W3000" // an error that is not of one of the kinds below
+	RuntimeTypeError         = "W3001" // an operation on a value of the wrong type
+	RuntimeNameError         = "W3002" // a name that is not defined
+	RuntimeIndexError        = "W3003" // an index or a slice out of range
+	RuntimeKeyError          = "W3004" // a key missing from a map
+	RuntimeZeroDivisionError = "W3005" // a division or modulo by zero
+	RuntimeRecursionError    = "W3006" // function calls nested beyond the maximum call depth
+	RuntimeInterrupted       = "W3007" // the interpretation was cancelled
+	RuntimeValueError        = "W3008" // an argument of the right type but of an invalid value
+)
+
+// RuntimeError is an error that terminates the interpretation of a script
+type RuntimeError struct {
+	Name string    // name of the input, if the root of the interpretation is a File
+	Pos  token.Pos // position of the node being evaluated
+	End  token.Pos // end of the node being evaluated
+	Kind string    // kind of the error, e.g. "TypeError", empty for other errors
+	Code string    // the stable code of the error, e.g. RuntimeTypeError
+	Msg  string
+}
+
+// Error returns the error in the form name:line:col: kind - msg, the name is
+// omitted if it is not known
+func (e *RuntimeError) Error() string {
+	msg := e.Msg
+	if e.Kind != "" {
+		msg = e.Kind + " - " + msg
+	}
+	if e.Name == "" {
+		return fmt.Sprintf("%s: %s", e.Pos.String(), msg)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.Name, e.Pos.String(), msg)
+}
+
+// typeErrorf formats the error string before passing into fail() for panicking
 func (i *Interpreter) typeErrorf(format string, node Node, args ...interface{}) {
-	format = fmt.Sprintf("%s: TypeError - %s", node.Pos().String(), format)
-	i.errorf(format, args...)
+	i.fail(node, "TypeError", RuntimeTypeError, format, args...)
 }
 
-// zeroDivisionErrorf formats the error string before passing into errorf() for panicking
+// zeroDivisionErrorf formats the error string before passing into fail() for panicking
 func (i *Interpreter) zeroDivisionErrorf(format string, node Node, args ...interface{}) {
-	format = fmt.Sprintf("%s: ZeroDivisionError - %s", node.Pos().String(), format)
-	i.errorf(format, args...)
+	i.fail(node, "ZeroDivisionError", RuntimeZeroDivisionError, format, args...)
 }
 
-func (i *Interpreter) errorf(format string, args ...interface{}) {
-	i.Root = nil // Discard the AST
-	panic(fmt.Errorf(format, args...))
+// indexErrorf formats the error string before passing into fail() for panicking
+func (i *Interpreter) indexErrorf(format string, node Node, args ...interface{}) {
+	i.fail(node, "IndexError", RuntimeIndexError, format, args...)
+}
+
+// keyErrorf formats the error string before passing into fail() for panicking
+func (i *Interpreter) keyErrorf(format string, node Node, args ...interface{}) {
+	i.fail(node, "KeyError", RuntimeKeyError, format, args...)
+}
+
+// recursionErrorf formats the error string before passing into fail() for panicking
+func (i *Interpreter) recursionErrorf(format string, node Node, args ...interface{}) {
+	i.fail(node, "RecursionError", RuntimeRecursionError, format, args...)
+}
+
+// valueErrorf formats the error string before passing into fail() for panicking
+func (i *Interpreter) valueErrorf(format string, node Node, args ...interface{}) {
+	i.fail(node, "ValueError", RuntimeValueError, format, args...)
 }
 
-// error panics a general error
-func (i *Interpreter) error(err error) {
-	i.errorf("%s", err)
+// nameErrorf formats the error string before passing into fail() for panicking
+func (i *Interpreter) nameErrorf(format string, node Node, args ...interface{}) {
+	i.fail(node, "NameError", RuntimeNameError, format, args...)
+}
+
+// fail terminates the interpretation with a RuntimeError of the kind and code
+// at the node
+func (i *Interpreter) fail(node Node, kind, code, format string, args ...interface{}) {
+	i.Root = nil // Discard the AST
+	panic(&RuntimeError{Name: i.name, Pos: node.Pos(), End: node.End(), Kind: kind, Code: code, Msg: fmt.Sprintf(format, args...)})
 }
 
 // typeError panics a type error
@@ -39,26 +238,61 @@ func (i *Interpreter) typeError(node Node, err error) {
 	i.typeErrorf("%s", node, err)
 }
 
+// recover is the handler that turns panics into returns from the top level
+// of the interpretation, a panic other than a RuntimeError is a bug in the
+// interpreter or in a builtin, it is returned as a RuntimeError at the node
+// being evaluated rather than crashing the embedder
 func (i *Interpreter) recover(erri *error) {
 	e := recover()
-	if e != nil {
-		if _, ok := e.(runtime.Error); ok {
-			panic(e)
-		}
-		*erri = e.(error)
+	if e == nil {
+		return
+	}
+	if err, ok := e.(*RuntimeError); ok {
+		*erri = err
+		return
 	}
+	var pos, end token.Pos
+	if len(i.stack) > 0 {
+		pos, end = nodePos(i.stack[len(i.stack)-1]), nodeEnd(i.stack[len(i.stack)-1])
+	}
+	*erri = &RuntimeError{Name: i.name, Pos: pos, End: end, Code: RuntimeOtherError,
+		Msg: fmt.Sprintf("internal interpreter error: %v", e)}
 }
 
 // initInterp creates a new interpreter object with the root as the Node
 // being passed in
-func initInterp(rootNode Node) *Interpreter {
-	i := &Interpreter{Root: rootNode}
+func initInterp(ctx context.Context, rootNode Node, cfg Config) *Interpreter {
+	env := cfg.Env
+	if env == nil {
+		env = Environment{}
+	}
+	i := &Interpreter{Root: rootNode, ctx: ctx, env: env, hook: cfg.Hook,
+		stdout: cfg.Stdout, stderr: cfg.Stderr, stdin: cfg.Stdin}
+	if f, ok := rootNode.(*File); ok {
+		i.name = f.Name
+	}
+	if i.stdout == nil {
+		i.stdout = os.Stdout
+	}
+	if i.stderr == nil {
+		i.stderr = os.Stderr
+	}
+	if i.stdin == nil {
+		i.stdin = os.Stdin
+	}
 	return i
 }
 
 // Interpret interprets the AST tree from its root
 func Interpret(rootNode Node) (interp *Interpreter, err error) {
-	i := initInterp(rootNode)
+	return InterpretContext(context.Background(), rootNode, Config{})
+}
+
+// InterpretContext interprets the AST tree from its root using the settings in
+// cfg, stopping with an error if ctx is cancelled before interpretation is
+// finished
+func InterpretContext(ctx context.Context, rootNode Node, cfg Config) (interp *Interpreter, err error) {
+	i := initInterp(ctx, rootNode, cfg)
 	defer i.recover(&err)
 	i.interpret()
 	return i, nil
@@ -67,252 +301,742 @@ func Interpret(rootNode Node) (interp *Interpreter, err error) {
 // interpret walks the tree from its root, exploring its children while making
 // its walk downwards
 func (i *Interpreter) interpret() {
-	res := i.Root.accept(i)
-	fmt.Printf("result is: %v of type %T\n", res, res)
+	i.Result = i.eval(i.Root)
+}
+
+// eval visits the node, terminating the interpretation if it has been cancelled
+func (i *Interpreter) eval(node Node) WType {
+	i.stack = append(i.stack, node)
+	if i.hook != nil {
+		i.hook(i, node)
+	}
+	if err := i.ctx.Err(); err != nil {
+		i.fail(node, "interrupted", RuntimeInterrupted, "%s", err)
+	}
+	res := node.accept(i)
+	i.stack = i.stack[:len(i.stack)-1]
+	return res
+}
+
+func (i *Interpreter) visitFile(node *File) WType {
+	// the result of a file is the value of its last statement, which is nil
+	// unless the statement is an expression statement
+	var res WType
+	for _, stmt := range node.stmts {
+		res = i.eval(stmt)
+	}
+	return res
+}
+
+func (i *Interpreter) visitExprStmt(node *ExprStmt) WType {
+	var res WType
+	for _, expr := range node.exprs {
+		res = i.eval(expr)
+	}
+	return res
+}
+
+func (i *Interpreter) visitAssignStmt(node *AssignStmt) WType {
+	// evaluate all the values before assigning any of them so that values may
+	// be swapped, e.g. "a, b = b, a"
+	values := make([]WType, len(node.right))
+	for k, expr := range node.right {
+		values[k] = i.eval(expr)
+	}
+	if len(node.left) > 1 && len(values) == 1 {
+		// a single value is unpacked into the targets, e.g. "a, b = f()"
+		values = i.unpack(values[0], len(node.left), node.right[0])
+	}
+	for k, target := range node.left {
+		i.assignTo(target, values[k])
+	}
+	return nil
+}
+
+func (i *Interpreter) visitPlusAssignStmt(node *PlusAssignStmt) WType {
+	i.augAssign(node.left[0], node.right[0], token.PLUS, "+")
+	return nil
+}
+
+func (i *Interpreter) visitMinusAssignStmt(node *MinusAssignStmt) WType {
+	i.augAssign(node.left[0], node.right[0], token.MINUS, "-")
+	return nil
+}
+
+func (i *Interpreter) visitDivAssignStmt(node *DivAssignStmt) WType {
+	i.augAssign(node.left[0], node.right[0], token.DIV, "/")
+	return nil
+}
+
+func (i *Interpreter) visitMultAssignStmt(node *MultAssignStmt) WType {
+	i.augAssign(node.left[0], node.right[0], token.MULT, "*")
+	return nil
+}
+
+func (i *Interpreter) visitModAssignStmt(node *ModAssignStmt) WType {
+	i.augAssign(node.left[0], node.right[0], token.MOD, "%")
+	return nil
 }
 
-// TODO: Implement me!
-func (i *Interpreter) visitExprStmt(node *ExprStmt) WType { return nil }
+// augAssign applies the binary operator to the target and the value and
+// assigns the result back to the target, the operands of an index target are
+// evaluated only once
+func (i *Interpreter) augAssign(target, value Expr, op token.Type, opStr string) {
+	binExpr := newBinExpr(target, value, token.Token{Type: op, Value: opStr, Pos: target.Pos()})
+	switch t := target.(type) {
+	case *Ident:
+		i.assign(t.Name, i.binaryOp(i.eval(t), i.eval(value), binExpr))
+	case *IndexExpr:
+		x, index := i.eval(t.x), i.eval(t.index)
+		res := i.binaryOp(i.index(x, index, t), i.eval(value), binExpr)
+		i.setIndex(x, index, res, t)
+	}
+}
+
+// unpack returns the n elements of a tuple or list, panicking if the value is
+// not a tuple or list or does not have n elements
+func (i *Interpreter) unpack(value WType, n int, node Node) []WType {
+	var values []WType
+	switch v := value.(type) {
+	case WTuple:
+		values = v
+	case WList:
+		values = v
+	default:
+		i.typeErrorf("cannot unpack non-sequence '%s'", node, TypeName(value))
+	}
+	if len(values) != n {
+		i.valueErrorf("expected %d values to unpack, got %d", node, n, len(values))
+	}
+	return values
+}
+
+// assignTo assigns the value to the target of an assignment, either a name or
+// an element of a list or map
+func (i *Interpreter) assignTo(target Expr, value WType) {
+	switch t := target.(type) {
+	case *Ident:
+		i.assign(t.Name, value)
+	case *IndexExpr:
+		i.setIndex(i.eval(t.x), i.eval(t.index), value, t)
+	}
+}
+
+func (i *Interpreter) visitVarDecl(node *VarDecl) WType {
+	values := make([]WType, len(node.names))
+	for k, expr := range node.values {
+		values[k] = i.eval(expr)
+	}
+	for k, name := range node.names {
+		if values[k] == nil {
+			values[k] = WNull{}
+		}
+		i.define(name.Name, values[k])
+	}
+	return nil
+}
+
+func (i *Interpreter) visitFuncDecl(node *FuncDecl) WType {
+	i.define(node.name.Name, &WFunc{name: node.name.Name, lit: node.fn, closure: i.frame,
+		generator: isGenerator(node.fn)})
+	return nil
+}
+
+func (i *Interpreter) visitBlockStmt(node *BlockStmt) WType {
+	for _, stmt := range node.stmts {
+		i.eval(stmt)
+		if i.branch != noBranch {
+			break
+		}
+	}
+	return nil
+}
 
-// TODO: Implement me!
-func (i *Interpreter) visitAssignStmt(node *AssignStmt) WType { return nil }
+func (i *Interpreter) visitIfStmt(node *IfStmt) WType {
+	if !i.eval(node.cond).IsZeroValue() {
+		i.eval(node.body)
+	} else if node.elseStmt != nil {
+		i.eval(node.elseStmt)
+	}
+	return nil
+}
 
-// TODO: Implement me!
-func (i *Interpreter) visitPlusAssignStmt(node *PlusAssignStmt) WType { return nil }
+func (i *Interpreter) visitWhileStmt(node *WhileStmt) WType {
+	for !i.eval(node.cond).IsZeroValue() {
+		i.eval(node.body)
+		if i.endIteration() {
+			break
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) visitForStmt(node *ForStmt) WType {
+	i.forEach(node.key, node.value, i.eval(node.iter), node.iter, func() bool {
+		i.eval(node.body)
+		return !i.endIteration()
+	})
+	return nil
+}
+
+// forEach assigns the names of a for loop or comprehension the index (or key)
+// and the value of each element of the iterable in turn, or only the value if
+// there is a single name, the elements of a map then being its keys. body is
+// called after each assignment, the iteration stops once it returns false
+func (i *Interpreter) forEach(key, value *Ident, iterable WType, node Node, body func() bool) {
+	it := i.iter(iterable, node)
+	_, isMap := iterable.(Wmap)
+	for {
+		k, v, ok := it.next(i, node)
+		if !ok {
+			return
+		}
+		if value == nil {
+			if isMap {
+				v = k
+			}
+			i.assign(key.Name, v)
+		} else {
+			i.assign(key.Name, k)
+			i.assign(value.Name, v)
+		}
+		if !body() {
+			return
+		}
+	}
+}
 
-// TODO: Implement me!
-func (i *Interpreter) visitMinusAssignStmt(node *MinusAssignStmt) WType { return nil }
+// iterate returns the indices (or keys) and the values of an iterable, the keys
+// of a map are in sorted order and a generator or iterator is run until it is
+// done, see iter
+func (i *Interpreter) iterate(iterable WType, node Node) (keys, values []WType) {
+	it := i.iter(iterable, node)
+	for {
+		key, value, ok := it.next(i, node)
+		if !ok {
+			return keys, values
+		}
+		keys, values = append(keys, key), append(values, value)
+	}
+}
 
-// TODO: Implement me!
-func (i *Interpreter) visitDivAssignStmt(node *DivAssignStmt) WType { return nil }
+// endIteration resets a break or continue taken in the body of a loop,
+// reporting whether the loop has to stop
+func (i *Interpreter) endIteration() bool {
+	switch i.branch {
+	case breakBranch:
+		i.branch = noBranch
+		return true
+	case continueBranch:
+		i.branch = noBranch
+	case returnBranch:
+		return true
+	}
+	return false
+}
 
-// TODO: Implement me!
-func (i *Interpreter) visitMultAssignStmt(node *MultAssignStmt) WType { return nil }
+func (i *Interpreter) visitBranchStmt(node *BranchStmt) WType {
+	if node.Type == token.BREAK {
+		i.branch = breakBranch
+	} else {
+		i.branch = continueBranch
+	}
+	return nil
+}
 
-// TODO: Implement me!
-func (i *Interpreter) visitModAssignStmt(node *ModAssignStmt) WType { return nil }
+func (i *Interpreter) visitReturnStmt(node *ReturnStmt) WType {
+	i.retVal = WNull{}
+	if node.result != nil {
+		i.retVal = i.eval(node.result)
+	}
+	i.branch = returnBranch
+	return nil
+}
 
 // NOTE: Should we allow functional overloading for arithmetic expressions?
 
-// // additiveOp handles visit method for "additive" operators such as
-// // '+', '-', '*' for arithmetic operations
-// func (i *Interpreter) additiveOp(leftRes, rightRes WType, node Node) WType {
-// 	a, aOk := leftRes.(WNum)
-// 	b, bOk := rightRes.(WNum)
-// 	if aOk && bOk {
-// 		switch node.(type) {
-// 		case *AddExpr:
-// 			return a + b
-// 		case *SubtractExpr:
-// 			return a - b
-// 		case *MultExpr:
-// 			return a * b
-// 		}
-// 	}
-// 	// If reached here, force a type error, especially if they're adding in
-// 	// incompatible types
-// 	typ1Str := reflect.TypeOf(leftRes).Name()
-// 	typ2Str := reflect.TypeOf(rightRes).Name()
-// 	i.typeErrorf("unsupported operand type(s) for %s: '%s' and '%s'",
-// 		node, node, typ1Str, typ2Str,
-// 	)
-// 	// Should not reach here as typeErrorf will panic
-// 	return WNull{}
-// }
-
-// // divisiveOp handles visit method for "divisive" operators such as
-// // '/' and '%' for arithmetic operations such that they handle zero divisions
-// // properly
-// func (i *Interpreter) divisiveOp(leftRes, rightRes WType, node Node) WType {
-// 	a, aOk := leftRes.(WNum)
-// 	b, bOk := rightRes.(WNum)
-// 	if aOk && bOk {
-// 		if b.IsZeroValue() {
-// 			if b.IsInt() {
-// 				i.zeroDivisionErrorf("int division by zero", node)
-// 			} else {
-// 				i.zeroDivisionErrorf("float division by zero", node)
-// 			}
-// 		}
-// 		switch node.(type) {
-// 		case *DivExpr:
-// 			return a / b
-// 		case *ModExpr:
-// 			if a.IsInt() && b.IsInt() {
-// 				return WNum(int64(a) % int64(b))
-// 			}
-// 			return WNum(math.Mod(float64(a), float64(b)))
-// 		}
-// 	}
-// 	// If reached here, force a type error, especially if they're adding in
-// 	// incompatible types
-// 	typ1Str := reflect.TypeOf(leftRes).Name()
-// 	typ2Str := reflect.TypeOf(rightRes).Name()
-// 	i.typeErrorf("unsupported operand type(s) for %s: '%s' and '%s'",
-// 		node, node, typ1Str, typ2Str,
-// 	)
-// 	// Should not reach here as typeErrorf will panic
-// 	return WNull{}
-// }
+// additiveOp handles the arithmetic operators '+', '-', '*'
+func (i *Interpreter) additiveOp(leftRes, rightRes WType, node *BinExpr) WType {
+	a, aOk := leftRes.(WNum)
+	b, bOk := rightRes.(WNum)
+	if aOk && bOk {
+		switch node.op.Type {
+		case token.PLUS:
+			return a + b
+		case token.MINUS:
+			return a - b
+		case token.MULT:
+			return a * b
+		}
+	}
+	// If reached here, force a type error, especially if they're adding in
+	// incompatible types
+	i.operandTypeError(leftRes, rightRes, node)
+	// Should not reach here as typeErrorf will panic
+	return WNull{}
+}
 
-func (i *Interpreter) visitBinExpr(node *BinExpr) WType {
-	// lhs := node.left.Accept(i)
+// divisiveOp handles the arithmetic operators '/' and '%' such that they
+// handle zero divisions properly
+func (i *Interpreter) divisiveOp(leftRes, rightRes WType, node *BinExpr) WType {
+	a, aOk := leftRes.(WNum)
+	b, bOk := rightRes.(WNum)
+	if aOk && bOk {
+		if b.IsZeroValue() {
+			if b.IsInt() {
+				i.zeroDivisionErrorf("int division by zero", node)
+			} else {
+				i.zeroDivisionErrorf("float division by zero", node)
+			}
+		}
+		switch node.op.Type {
+		case token.DIV:
+			return a / b
+		case token.MOD:
+			if a.IsInt() && b.IsInt() {
+				return WNum(int64(a) % int64(b))
+			}
+			return WNum(math.Mod(float64(a), float64(b)))
+		}
+	}
+	// If reached here, force a type error, especially if they're dividing
+	// incompatible types
+	i.operandTypeError(leftRes, rightRes, node)
+	// Should not reach here as typeErrorf will panic
+	return WNull{}
+}
 
+// inOp returns true if the left operand is contained within the right operand,
+// an element of a list, a key of a map or a substring of a string
+func (i *Interpreter) inOp(leftRes, rightRes WType, node *BinExpr) WType {
+	switch container := rightRes.(type) {
+	case WList:
+		return contains(container, leftRes)
+	case WTuple:
+		return contains(container, leftRes)
+	case Wmap:
+		_, found := container.Get(i.mapKey(leftRes, node))
+		return WBool(found)
+	case WString:
+		if sub, ok := leftRes.(WString); ok {
+			return WBool(strings.Contains(string(container), string(sub)))
+		}
+	}
+	i.operandTypeError(leftRes, rightRes, node)
+	// Should not reach here as typeErrorf will panic
 	return WNull{}
 }
 
-func (i *Interpreter) visitUnExpr(node *UnExpr) WType {
-	// lhs := node.operand.Accept(i)
+// contains returns true if an element of the sequence is equal to the value
+func contains(seq []WType, value WType) WBool {
+	for _, el := range seq {
+		if el.Equals(value) {
+			return true
+		}
+	}
+	return false
+}
 
+// operandTypeError panics with a type error for operands of a binary
+// expression that the operator does not support
+func (i *Interpreter) operandTypeError(leftRes, rightRes WType, node *BinExpr) {
+	i.typeErrorf("unsupported operand type(s) for %s: '%s' and '%s'",
+		node, node.op.Value, TypeName(leftRes), TypeName(rightRes),
+	)
+}
+
+func (i *Interpreter) visitBinExpr(node *BinExpr) WType {
+	leftRes := i.eval(node.left)
+	switch node.op.Type {
+	case token.LOGICALAND:
+		// if 'expr1 && expr2', expr1 if expr1 is false (i.e. zero-value), else expr2
+		if leftRes.IsZeroValue() {
+			return leftRes
+		}
+		return i.eval(node.right)
+	case token.LOGICALOR:
+		// if 'expr1 || expr2', expr1 if expr1 is true (i.e. not zero-value), else expr2
+		if !leftRes.IsZeroValue() {
+			return leftRes
+		}
+		return i.eval(node.right)
+	}
+	return i.binaryOp(leftRes, i.eval(node.right), node)
+}
+
+// binaryOp applies the operator of the binary expression to the operands, the
+// logical operators are handled by visitBinExpr as they short circuit
+func (i *Interpreter) binaryOp(leftRes, rightRes WType, node *BinExpr) WType {
+	switch node.op.Type {
+	case token.PLUS:
+		a, aOk := leftRes.(WString)
+		b, bOk := rightRes.(WString)
+		if aOk && bOk { // if they're both strings
+			return a + b
+		}
+		return i.additiveOp(leftRes, rightRes, node)
+	case token.MINUS, token.MULT:
+		return i.additiveOp(leftRes, rightRes, node)
+	case token.DIV, token.MOD:
+		return i.divisiveOp(leftRes, rightRes, node)
+	case token.EQ:
+		return leftRes.Equals(rightRes)
+	case token.NEQ:
+		return !leftRes.Equals(rightRes)
+	case token.SM, token.SMEQ:
+		// evaluates '<' and '<=' operators
+		smRes, err := leftRes.Sm(rightRes, node.op.Type == token.SMEQ)
+		if err != nil {
+			i.typeError(node, err)
+		}
+		return smRes
+	case token.GR, token.GREQ:
+		// evaluates '>' and '>=' operators
+		grRes, err := leftRes.Gr(rightRes, node.op.Type == token.GREQ)
+		if err != nil {
+			i.typeError(node, err)
+		}
+		return grRes
+	case token.IN:
+		return i.inOp(leftRes, rightRes, node)
+	}
+	i.fail(node, "", RuntimeOtherError, "unknown binary operator %s", node.op.Value)
+	// Should not reach here as fail will panic
 	return WNull{}
 }
 
-// func (i *Interpreter) visitAdd(node *AddExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-// 	a, aOk := leftRes.(WString)
-// 	b, bOk := rightRes.(WString)
-// 	if aOk && bOk { // if they're both strings
-// 		return a + b
-// 	}
-// 	return i.additiveOp(leftRes, rightRes, node)
-// }
-
-// func (i *Interpreter) visitSubtract(node *SubtractExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-// 	return i.additiveOp(leftRes, rightRes, node)
-// }
-
-// func (i *Interpreter) visitMult(node *MultExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-// 	return i.additiveOp(leftRes, rightRes, node)
-// }
-
-// func (i *Interpreter) visitDiv(node *DivExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-// 	return i.divisiveOp(leftRes, rightRes, node)
-// }
-
-// func (i *Interpreter) visitMod(node *ModExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-// 	return i.divisiveOp(leftRes, rightRes, node)
-// }
-
-// func (i *Interpreter) visitEq(node *EqExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-// 	if node.IsNot {
-// 		return !leftRes.Equals(rightRes)
-// 	}
-// 	return leftRes.Equals(rightRes)
-// }
-
-// // visitSm evaluates '<' and '<=' operators
-// func (i *Interpreter) visitSm(node *SmExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-
-// 	smRes, err := leftRes.Sm(rightRes, node.OrEq)
-// 	if err != nil {
-// 		i.typeError(node, err)
-// 	}
-// 	return smRes
-// }
-
-// // visitGr evaluates '>' and '>=' operators
-// func (i *Interpreter) visitGr(node *GrExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	rightRes := node.right.Accept(i)
-
-// 	grRes, err := leftRes.Gr(rightRes, node.OrEq)
-// 	if err != nil {
-// 		i.typeError(node, err)
-// 	}
-// 	return grRes
-// }
-
-// // TODO: confirm grammar spec for `in` keyword
-// func (i *Interpreter) visitIn(node *InExpr) WType { return WNull{} }
-
-// // visitAnd evaluates '&&' operators
-// // if 'expr1 && expr2', expr1 if expr1 is false (i.e. zero-value), else expr2
-// func (i *Interpreter) visitAnd(node *AndExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	if leftRes.IsZeroValue() {
-// 		return leftRes
-// 	}
-// 	return node.right.Accept(i)
-// }
-
-// // visitOr evaluates '||' operators
-// // if 'expr1 || expr2', expr2 if expr1 is false (i.e. zero-value), else expr2
-// func (i *Interpreter) visitOr(node *OrExpr) WType {
-// 	leftRes := node.left.Accept(i)
-// 	if !leftRes.IsZeroValue() {
-// 		return leftRes
-// 	}
-// 	return node.right.Accept(i)
-// }
-
-// // Unary Operators
-
-// // visitPlus evaluates a node
-// func (i *Interpreter) visitPlus(node *PlusExpr) WType {
-// 	switch v := node.operand.Accept(i).(type) {
-// 	case WNum:
-// 		return v
-// 	default:
-// 		typ := reflect.TypeOf(v).Name()
-// 		i.typeErrorf("bad operand type for unary %s: '%s'", node, node, typ)
-// 	}
-// 	// Should not reach here as typeErrorf will panic
-// 	return WNull{}
-// }
-
-// func (i *Interpreter) visitMinus(node *MinusExpr) WType {
-// 	switch v := node.operand.Accept(i).(type) {
-// 	case WNum:
-// 		return -v
-// 	default:
-// 		typ := reflect.TypeOf(v).Name()
-// 		i.typeErrorf("bad operand type for unary %s: '%s'", node, node, typ)
-// 	}
-// 	// Should not reach here as typeErrorf will panic
-// 	return WNull{}
-// }
-
-// // visitNot returns true if its operand are zero values (i.e. are false)
-// // else returns false
-// func (i *Interpreter) visitNot(node *NotExpr) WType {
-// 	switch v := node.operand.Accept(i).(type) {
-// 	case WType:
-// 		return v.IsZeroValue()
-// 	default:
-// 		typ := reflect.TypeOf(v).Name()
-// 		i.typeErrorf("bad operand type for unary %s: '%s'", node, node, typ)
-// 	}
-// 	// Should not reach here as typeErrorf will panic
-// 	return WNull{}
-// }
+func (i *Interpreter) visitGrpExpr(node *GrpExpr) WType { return i.eval(node.x) }
+
+func (i *Interpreter) visitUnExpr(node *UnExpr) WType {
+	operand := i.eval(node.operand)
+	switch node.op.Type {
+	case token.LOGICALNOT:
+		// returns true if its operand is a zero value (i.e. is false) else false
+		return operand.IsZeroValue()
+	case token.PLUS:
+		if v, ok := operand.(WNum); ok {
+			return v
+		}
+	case token.MINUS:
+		if v, ok := operand.(WNum); ok {
+			return -v
+		}
+	}
+	i.typeErrorf("bad operand type for unary %s: '%s'", node, node.op.Value, TypeName(operand))
+	// Should not reach here as typeErrorf will panic
+	return WNull{}
+}
 
 // visit literals ==> At its core, these will return WType values
 
-// TODO: visit literals for maps
-// func (i *Interpreter) visitNum(n *Num) WType   { return WNum(n.Float64) }
-// func (i *Interpreter) visitStr(n *Str) WType   { return WString(n.String()) }
-// func (i *Interpreter) visitNull(n *Null) WType { return WNull{} }
-func (i *Interpreter) visitBasicLit(n *BasicLit) WType { return WNull{} }
+func (i *Interpreter) visitBasicLit(n *BasicLit) WType {
+	switch n.Type {
+	case token.INT:
+		// base 0 handles the hexadecimal ("0x") and octal ("0") prefixes
+		v, err := strconv.ParseInt(n.Text, 0, 64)
+		if err != nil {
+			i.fail(n, "", RuntimeOtherError, "%s", err)
+		}
+		return WNum(v)
+	case token.FLOAT:
+		v, err := strconv.ParseFloat(n.Text, 64)
+		if err != nil {
+			i.fail(n, "", RuntimeOtherError, "%s", err)
+		}
+		return WNum(v)
+	case token.STR:
+		// escape sequences are already processed by the lexer
+		return WString(n.Text)
+	case token.CHAR:
+		r, _ := utf8.DecodeRuneInString(n.Text)
+		return WNum(r)
+	case token.TRUE:
+		return WBool(true)
+	case token.FALSE:
+		return WBool(false)
+	}
+	return WNull{}
+}
 
 func (i *Interpreter) visitList(n *List) WType {
 	wl := WList{}
 	for _, elNode := range n.elements {
-		wl = append(wl, elNode.accept(i))
+		wl = append(wl, i.eval(elNode))
 	}
 	return wl
 }
 
-func (i *Interpreter) visitID(n *Ident) WType { return WString(n.String()) }
+func (i *Interpreter) visitTuple(n *Tuple) WType {
+	wt := make(WTuple, len(n.elements))
+	for k, elNode := range n.elements {
+		wt[k] = i.eval(elNode)
+	}
+	return wt
+}
+
+func (i *Interpreter) visitMap(n *Map) WType {
+	wm := Wmap{}
+	for k, keyNode := range n.keys {
+		wm.Set(i.mapKey(i.eval(keyNode), keyNode), i.eval(n.values[k]))
+	}
+	return wm
+}
+
+func (i *Interpreter) visitComprehension(n *Comprehension) WType {
+	iterable := i.eval(n.iter)
+	// the names of the for clause are bound in a frame of their own so that
+	// they do not leak into the scope of the comprehension
+	enclosing := i.frame
+	i.frame = &frame{locals: Environment{n.forKey.Name: WNull{}}, parent: enclosing}
+	if n.forValue != nil {
+		i.frame.locals[n.forValue.Name] = WNull{}
+	}
+	list, wm := WList{}, Wmap{}
+	i.forEach(n.forKey, n.forValue, iterable, n.iter, func() bool {
+		if n.cond != nil && i.eval(n.cond).IsZeroValue() {
+			return true
+		}
+		if n.key != nil {
+			wm.Set(i.mapKey(i.eval(n.key), n.key), i.eval(n.value))
+		} else {
+			list = append(list, i.eval(n.value))
+		}
+		return true
+	})
+	i.frame = enclosing
+	if n.key != nil {
+		return wm
+	}
+	return list
+}
+
+func (i *Interpreter) visitFuncLit(n *FuncLit) WType {
+	return &WFunc{name: "func", lit: n, closure: i.frame, generator: isGenerator(n)}
+}
+
+func (i *Interpreter) visitID(n *Ident) WType {
+	v, ok := i.Lookup(n.Name)
+	if !ok {
+		if name := suggest(n.Name, i.names()); name != "" {
+			i.nameErrorf("name '%s' is not defined, did you mean '%s'?", n, n.Name, name)
+		}
+		i.nameErrorf("name '%s' is not defined", n, n.Name)
+	}
+	return v
+}
+
+// names returns the sorted names bound in the current scope and the scopes
+// enclosing it
+func (i *Interpreter) names() []string {
+	names := BuiltinNames()
+	for f := i.frame; f != nil; f = f.parent {
+		for name := range f.locals {
+			names = append(names, name)
+		}
+	}
+	for name := range i.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (i *Interpreter) visitCallExpr(n *CallExpr) WType {
+	fnRes := i.eval(n.fn)
+	args := make([]WType, len(n.args))
+	for k, arg := range n.args {
+		args[k] = i.eval(arg)
+	}
+	return i.callValue(fnRes, args, n)
+}
+
+// callValue calls a function or a builtin with the arguments, panicking with a
+// type error if the value is not callable
+func (i *Interpreter) callValue(fn WType, args []WType, node *CallExpr) WType {
+	switch fn := fn.(type) {
+	case *WFunc:
+		return i.call(fn, args, node)
+	case *WBuiltin:
+		return i.callBuiltin(fn, args, node)
+	}
+	i.typeErrorf("'%s' object is not callable", node, TypeName(fn))
+	// Should not reach here as typeErrorf will panic
+	return WNull{}
+}
+
+// call calls the function with the arguments, in a new frame enclosed by the
+// frame the function was defined in
+func (i *Interpreter) call(fn *WFunc, args []WType, node *CallExpr) WType {
+	params := fn.lit.params
+	if len(args) != len(params) {
+		i.typeErrorf("%s() takes %d argument(s) but %d were given", node, fn.name, len(params), len(args))
+	}
+	if i.depth == maxCallDepth {
+		i.recursionErrorf("maximum call depth of %d exceeded", node, maxCallDepth)
+	}
+	f := &frame{locals: Environment{}, parent: fn.closure, fn: fn}
+	for k, param := range params {
+		f.locals[param.Name] = args[k]
+	}
+	if fn.generator {
+		return i.newGenerator(fn, f)
+	}
+	caller := i.frame
+	i.frame = f
+	i.depth++
+	i.eval(fn.lit.body)
+	i.depth--
+	i.frame = caller
+
+	res := WType(WNull{})
+	if i.branch == returnBranch {
+		res = i.retVal
+		i.branch, i.retVal = noBranch, nil
+	}
+	return res
+}
+
+func (i *Interpreter) visitIndexExpr(n *IndexExpr) WType {
+	return i.index(i.eval(n.x), i.eval(n.index), n)
+}
+
+// index returns the element of the list or string at the index, or the value
+// of the map at the key
+func (i *Interpreter) index(x, index WType, node *IndexExpr) WType {
+	switch v := x.(type) {
+	case WList:
+		k := i.intIndex(index, node.index)
+		if k < 0 || k >= len(v) {
+			i.indexErrorf("list index %d out of range with length %d", node, k, len(v))
+		}
+		return v[k]
+	case WTuple:
+		k := i.intIndex(index, node.index)
+		if k < 0 || k >= len(v) {
+			i.indexErrorf("tuple index %d out of range with length %d", node, k, len(v))
+		}
+		return v[k]
+	case WString:
+		c, err := v.Index(i.intIndex(index, node.index))
+		if err != nil {
+			i.indexErrorf("%s", node, err)
+		}
+		return c
+	case Wmap:
+		key := i.mapKey(index, node.index)
+		el, ok := v.Get(key)
+		if !ok {
+			i.keyErrorf("%s", node, key)
+		}
+		return el
+	}
+	i.typeErrorf("'%s' object is not subscriptable", node, TypeName(x))
+	// Should not reach here as typeErrorf will panic
+	return WNull{}
+}
+
+// setIndex assigns the value to the element of the list at the index, or to
+// the key of the map
+func (i *Interpreter) setIndex(x, index, value WType, node *IndexExpr) {
+	switch v := x.(type) {
+	case WList:
+		k := i.intIndex(index, node.index)
+		if k < 0 || k >= len(v) {
+			i.indexErrorf("list assignment index %d out of range with length %d", node, k, len(v))
+		}
+		v[k] = value
+	case Wmap:
+		v.Set(i.mapKey(index, node.index), value)
+	default:
+		i.typeErrorf("'%s' object does not support item assignment", node, TypeName(x))
+	}
+}
+
+func (i *Interpreter) visitSliceExpr(n *SliceExpr) WType {
+	x := i.eval(n.x)
+	var length int
+	switch v := x.(type) {
+	case WList:
+		length = len(v)
+	case WTuple:
+		length = len(v)
+	case WString:
+		length = v.Len()
+	default:
+		i.typeErrorf("'%s' object is not sliceable", n, TypeName(x))
+	}
+	lo, hi := 0, length
+	if n.lo != nil {
+		lo = i.intIndex(i.eval(n.lo), n.lo)
+	}
+	if n.hi != nil {
+		hi = i.intIndex(i.eval(n.hi), n.hi)
+	}
+	if lo < 0 || hi < lo || hi > length {
+		i.indexErrorf("slice [%d:%d] out of range with length %d", n, lo, hi, length)
+	}
+	if v, ok := x.(WString); ok {
+		s, _ := v.Slice(lo, hi)
+		return s
+	}
+	if v, ok := x.(WTuple); ok {
+		return append(WTuple{}, v[lo:hi]...)
+	}
+	// slices are copies so that assigning to their elements leaves the list
+	// unchanged
+	return append(WList{}, x.(WList)[lo:hi]...)
+}
+
+// intIndex returns the index as an int, panicking with a type error if it is
+// not an integer
+func (i *Interpreter) intIndex(index WType, node Node) int {
+	if k, ok := index.(WNum); ok && k.IsInt() {
+		return int(k)
+	}
+	i.typeErrorf("indices must be integers, not '%s'", node, TypeName(index))
+	// Should not reach here as typeErrorf will panic
+	return 0
+}
+
+// mapKey returns the key of a map, panicking with a type error if it is not
+// hashable, see Hashable
+func (i *Interpreter) mapKey(key WType, node Node) WType {
+	if !Hashable(key) {
+		i.typeErrorf("unhashable type: '%s'", node, TypeName(key))
+	}
+	return key
+}
+
+// TypeName returns the name of the type of a went value, as written in error
+// messages, the types defined outside of went are named after their Go type
+func TypeName(w WType) string {
+	switch w.(type) {
+	case WNull:
+		return "null"
+	case WNum:
+		return "number"
+	case WString:
+		return "string"
+	case WBool:
+		return "bool"
+	case WList:
+		return "list"
+	case WTuple:
+		return "tuple"
+	case Wmap:
+		return "map"
+	case *WFunc:
+		return "function"
+	case *WBuiltin:
+		return "builtin"
+	case *WIterator:
+		return "iterator"
+	case *WGenerator:
+		return "generator"
+	}
+	t := reflect.TypeOf(w)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
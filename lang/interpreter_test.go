@@ -0,0 +1,77 @@
+package lang
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// benchInterpret parses the script once and interprets it b.N times, each
+// time in a new environment
+func benchInterpret(b *testing.B, script string) {
+	f, err := ParseFile("bench", script)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := InterpretContext(context.Background(), f,
+			Config{Stdout: ioutil.Discard, Stderr: ioutil.Discard, Stdin: strings.NewReader("")})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInterpretFib(b *testing.B) {
+	benchInterpret(b, `
+func fib(n) {
+	if n < 2 {
+		return n
+	}
+	return fib(n - 1) + fib(n - 2)
+}
+fib(20)
+`)
+}
+
+func BenchmarkInterpretArithmetic(b *testing.B) {
+	benchInterpret(b, `
+var total, i = 0, 0
+while i < 10000 {
+	total += i * 3 % 7 - i / 2
+	i += 1
+}
+`)
+}
+
+func BenchmarkInterpretStrings(b *testing.B) {
+	benchInterpret(b, `
+var s, i = '', 0
+while i < 1000 {
+	s += 'ab'
+	i += 1
+}
+`)
+}
+
+func BenchmarkInterpretMaps(b *testing.B) {
+	benchInterpret(b, `
+letters = ['a', 'b', 'c', 'd', 'e', 'f', 'g', 'h']
+var rounds = 0
+while rounds < 20 {
+	m = {}
+	for i, x in letters {
+		for j, y in letters {
+			m[x + y] = i * j
+		}
+	}
+	for k, v in m {
+		m[k] = v + 1
+	}
+	rounds += 1
+}
+`)
+}
@@ -0,0 +1,206 @@
+package lang
+
+// iterator is the protocol by which a for loop and the builtins taking an
+// iterable traverse it, one element at a time
+type iterator interface {
+	// next returns the index (or key) and the value of the next element, or
+	// false once there are no more elements
+	next(i *Interpreter, node Node) (key, value WType, ok bool)
+}
+
+// seqIterator iterates over the elements of a list or tuple, or the
+// characters of a string
+type seqIterator struct {
+	values []WType
+	k      int
+}
+
+func (it *seqIterator) next(i *Interpreter, node Node) (WType, WType, bool) {
+	if it.k >= len(it.values) {
+		return nil, nil, false
+	}
+	it.k++
+	return WNum(it.k - 1), it.values[it.k-1], true
+}
+
+// mapIterator iterates over the keys and values of a map in the sorted order
+// of the keys it had once the iteration started
+type mapIterator struct {
+	m    Wmap
+	keys []WType
+	k    int
+}
+
+func (it *mapIterator) next(i *Interpreter, node Node) (WType, WType, bool) {
+	for it.k < len(it.keys) {
+		key := it.keys[it.k]
+		it.k++
+		if value, ok := it.m.Get(key); ok {
+			return key, value, true
+		}
+	}
+	return nil, nil, false
+}
+
+// genIterator iterates over the values yielded by a generator
+type genIterator struct {
+	g *WGenerator
+	k int
+}
+
+func (it *genIterator) next(i *Interpreter, node Node) (WType, WType, bool) {
+	value, ok := i.next(it.g, node)
+	if !ok {
+		return nil, nil, false
+	}
+	it.k++
+	return WNum(it.k - 1), value, true
+}
+
+// callIterator iterates over the results of calling a function with no
+// arguments until it returns the sentinel, see builtinIter
+type callIterator struct {
+	fn, sentinel WType
+	call         *CallExpr // call of iter, where errors of the function are reported
+	k            int
+	done         bool
+}
+
+func (it *callIterator) next(i *Interpreter, node Node) (WType, WType, bool) {
+	if it.done {
+		return nil, nil, false
+	}
+	value := i.callValue(it.fn, nil, it.call)
+	if value.Equals(it.sentinel) {
+		it.done = true
+		return nil, nil, false
+	}
+	it.k++
+	return WNum(it.k - 1), value, true
+}
+
+// rangeIterator iterates over the integers from start up to but not including
+// stop, counting by step, see builtinRange
+type rangeIterator struct {
+	n, stop, step int
+	k             int
+}
+
+func (it *rangeIterator) next(i *Interpreter, node Node) (WType, WType, bool) {
+	if (it.step > 0 && it.n >= it.stop) || (it.step < 0 && it.n <= it.stop) {
+		return nil, nil, false
+	}
+	value := it.n
+	it.n += it.step
+	it.k++
+	return WNum(it.k - 1), WNum(value), true
+}
+
+// WIterator is an iterator over an iterable as returned by the builtin iter,
+// the iteration advances each time next is called with it and a for loop over
+// it continues from where it is
+type WIterator struct {
+	iterator
+}
+
+// IsZeroValue always returns false as iterators have no zero value
+func (w *WIterator) IsZeroValue() WBool { return false }
+
+// Equals returns true only if both are the same iterator
+func (w *WIterator) Equals(w2 WType) WBool {
+	v, ok := w2.(*WIterator)
+	return WBool(ok && v == w)
+}
+
+// Sm will always return false and an error for WIterator as WIterator has
+// no order relation
+func (w *WIterator) Sm(w2 WType, orEq bool) (WBool, error) {
+	operator := sm
+	if orEq {
+		operator = smE
+	}
+	return false, opError(w, w2, operator)
+}
+
+// Gr (see Sm)
+func (w *WIterator) Gr(w2 WType, orEq bool) (WBool, error) {
+	operator := gr
+	if orEq {
+		operator = grE
+	}
+	return false, opError(w, w2, operator)
+}
+
+func (w *WIterator) String() string { return "<iterator>" }
+
+// iter returns an iterator over the iterable, panicking with a type error if
+// the value is not iterable. The iterator over an iterator or a generator
+// shares its position so that either advances both
+func (i *Interpreter) iter(iterable WType, node Node) iterator {
+	switch it := iterable.(type) {
+	case WList:
+		return &seqIterator{values: it}
+	case WTuple:
+		return &seqIterator{values: it}
+	case WString:
+		chars := it.Chars()
+		values := make([]WType, len(chars))
+		for k, c := range chars {
+			values[k] = c
+		}
+		return &seqIterator{values: values}
+	case Wmap:
+		return &mapIterator{m: it, keys: it.Keys()}
+	case *WGenerator:
+		return &genIterator{g: it}
+	case *WIterator:
+		return it.iterator
+	}
+	i.typeErrorf("'%s' object is not iterable", node, TypeName(iterable))
+	// Should not reach here as typeErrorf will panic
+	return nil
+}
+
+// builtinIter returns an iterator over the elements of its argument, the keys
+// of a map, or with two arguments, an iterator over the results of calling the
+// function, with no arguments, until it returns the second argument
+func builtinIter(i *Interpreter, node *CallExpr, args []WType) WType {
+	switch len(args) {
+	case 1:
+		if m, ok := args[0].(Wmap); ok {
+			// as with a for loop with a single name, the elements of a map
+			// are its keys
+			return &WIterator{&seqIterator{values: m.Keys()}}
+		}
+		return &WIterator{i.iter(args[0], node)}
+	case 2:
+		switch args[0].(type) {
+		case *WFunc, *WBuiltin:
+			return &WIterator{&callIterator{fn: args[0], sentinel: args[1], call: node}}
+		}
+		i.typeErrorf("iter() argument must be callable, not '%s'", node, TypeName(args[0]))
+	}
+	i.typeErrorf("iter() takes 1 or 2 argument(s) but %d were given", node, len(args))
+	// Should not reach here as typeErrorf will panic
+	return nil
+}
+
+// builtinNext advances the iterator or generator, returning its next value,
+// or once there are no more, its second argument or else a value error
+func builtinNext(i *Interpreter, node *CallExpr, args []WType) WType {
+	if len(args) != 1 && len(args) != 2 {
+		i.typeErrorf("next() takes 1 or 2 argument(s) but %d were given", node, len(args))
+	}
+	switch args[0].(type) {
+	case *WIterator, *WGenerator:
+	default:
+		i.typeErrorf("'%s' object is not an iterator", node, TypeName(args[0]))
+	}
+	if _, value, ok := i.iter(args[0], node).next(i, node); ok {
+		return value
+	}
+	if len(args) == 1 {
+		i.valueErrorf("iterator is exhausted", node)
+	}
+	return args[1]
+}
@@ -0,0 +1,598 @@
+package lang
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+// The JSON form of an AST has an object for each node, holding the name of the
+// node's type under "type", its children and its positions, e.g. "1 + x" is
+//
+//	{"type": "BinExpr", "op": "+", "pos": {"line": 1, "col": 3},
+//	 "left": {"type": "BasicLit", "kind": "INTEGER", "value": "1", ...},
+//	 "right": {"type": "Ident", "name": "x", ...}}
+//
+// A missing child, such as the result of a bare return, is null.
+
+// MarshalJSON returns the JSON form of the AST rooted at node
+func MarshalJSON(node Node) ([]byte, error) {
+	return json.Marshal(new(jsonEncoder).node(node))
+}
+
+// MarshalJSONIndent is MarshalJSON with each element on a new line, indented
+// by indent for each level of nesting
+func MarshalJSONIndent(node Node, indent string) ([]byte, error) {
+	return json.MarshalIndent(new(jsonEncoder).node(node), "", indent)
+}
+
+// UnmarshalJSON returns the AST of the JSON form produced by MarshalJSON
+func UnmarshalJSON(data []byte) (node Node, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			jerr, ok := e.(jsonError)
+			if !ok {
+				panic(e)
+			}
+			node, err = nil, jerr
+		}
+	}()
+	return new(jsonDecoder).node(data), nil
+}
+
+// jsonNode is the JSON form of a node
+type jsonNode map[string]interface{}
+
+// jsonPos is the JSON form of a position
+type jsonPos struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+func toJSONPos(p token.Pos) jsonPos { return jsonPos{Line: p.Line(), Col: p.Col()} }
+
+// jsonComment is the JSON form of a comment of a File
+type jsonComment struct {
+	Text string  `json:"text"`
+	Pos  jsonPos `json:"pos"`
+	End  jsonPos `json:"end"`
+}
+
+// jsonEncoder implements NodeWalker, it converts each node to its jsonNode
+type jsonEncoder struct {
+	out jsonNode // the JSON form of the node visited last
+}
+
+// node returns the JSON form of the node, or nil for a missing node
+func (e *jsonEncoder) node(n Node) interface{} {
+	if n == nil {
+		return nil
+	}
+	n.accept(e)
+	return e.out
+}
+
+func (e *jsonEncoder) expr(x Expr) interface{} {
+	if x == nil {
+		return nil
+	}
+	return e.node(x)
+}
+
+func (e *jsonEncoder) stmt(s Stmt) interface{} {
+	if s == nil {
+		return nil
+	}
+	return e.node(s)
+}
+
+func (e *jsonEncoder) ident(id *Ident) interface{} {
+	if id == nil {
+		return nil
+	}
+	return e.node(id)
+}
+
+func (e *jsonEncoder) exprs(xs []Expr) []interface{} {
+	out := make([]interface{}, len(xs))
+	for i, x := range xs {
+		out[i] = e.expr(x)
+	}
+	return out
+}
+
+func (e *jsonEncoder) stmts(ss []Stmt) []interface{} {
+	out := make([]interface{}, len(ss))
+	for i, s := range ss {
+		out[i] = e.stmt(s)
+	}
+	return out
+}
+
+func (e *jsonEncoder) idents(ids []*Ident) []interface{} {
+	out := make([]interface{}, len(ids))
+	for i, id := range ids {
+		out[i] = e.ident(id)
+	}
+	return out
+}
+
+func (e *jsonEncoder) visitFile(n *File) WType {
+	comments := make([]jsonComment, len(n.Comments))
+	for i, c := range n.Comments {
+		comments[i] = jsonComment{Text: c.Value, Pos: toJSONPos(c.Pos), End: toJSONPos(c.End)}
+	}
+	e.out = jsonNode{"type": "File", "name": n.Name, "comments": comments, "stmts": e.stmts(n.stmts)}
+	return nil
+}
+
+func (e *jsonEncoder) visitExprStmt(n *ExprStmt) WType {
+	e.out = jsonNode{"type": "ExprStmt", "exprs": e.exprs(n.exprs)}
+	return nil
+}
+
+// assign sets the JSON form of an assignment statement of the type
+func (e *jsonEncoder) assign(typ string, left, right []Expr) {
+	e.out = jsonNode{"type": typ, "left": e.exprs(left), "right": e.exprs(right)}
+}
+
+func (e *jsonEncoder) visitAssignStmt(n *AssignStmt) WType {
+	e.assign("AssignStmt", n.left, n.right)
+	return nil
+}
+func (e *jsonEncoder) visitPlusAssignStmt(n *PlusAssignStmt) WType {
+	e.assign("PlusAssignStmt", n.left, n.right)
+	return nil
+}
+func (e *jsonEncoder) visitMinusAssignStmt(n *MinusAssignStmt) WType {
+	e.assign("MinusAssignStmt", n.left, n.right)
+	return nil
+}
+func (e *jsonEncoder) visitDivAssignStmt(n *DivAssignStmt) WType {
+	e.assign("DivAssignStmt", n.left, n.right)
+	return nil
+}
+func (e *jsonEncoder) visitMultAssignStmt(n *MultAssignStmt) WType {
+	e.assign("MultAssignStmt", n.left, n.right)
+	return nil
+}
+func (e *jsonEncoder) visitModAssignStmt(n *ModAssignStmt) WType {
+	e.assign("ModAssignStmt", n.left, n.right)
+	return nil
+}
+
+func (e *jsonEncoder) visitVarDecl(n *VarDecl) WType {
+	e.out = jsonNode{"type": "VarDecl", "pos": toJSONPos(n.VarPos),
+		"names": e.idents(n.names), "values": e.exprs(n.values)}
+	return nil
+}
+
+func (e *jsonEncoder) visitFuncDecl(n *FuncDecl) WType {
+	e.out = jsonNode{"type": "FuncDecl", "name": e.ident(n.name), "fn": e.node(n.fn)}
+	return nil
+}
+
+func (e *jsonEncoder) visitBlockStmt(n *BlockStmt) WType {
+	e.out = jsonNode{"type": "BlockStmt", "lcurly": toJSONPos(n.LCurlyPos), "rcurly": toJSONPos(n.RCurlyPos),
+		"stmts": e.stmts(n.stmts)}
+	return nil
+}
+
+func (e *jsonEncoder) visitIfStmt(n *IfStmt) WType {
+	e.out = jsonNode{"type": "IfStmt", "pos": toJSONPos(n.IfPos), "cond": e.expr(n.cond),
+		"body": e.node(n.body), "else": e.stmt(n.elseStmt)}
+	return nil
+}
+
+func (e *jsonEncoder) visitWhileStmt(n *WhileStmt) WType {
+	e.out = jsonNode{"type": "WhileStmt", "pos": toJSONPos(n.WhilePos), "cond": e.expr(n.cond),
+		"body": e.node(n.body)}
+	return nil
+}
+
+func (e *jsonEncoder) visitForStmt(n *ForStmt) WType {
+	e.out = jsonNode{"type": "ForStmt", "pos": toJSONPos(n.ForPos), "key": e.ident(n.key),
+		"value": e.ident(n.value), "iter": e.expr(n.iter), "body": e.node(n.body)}
+	return nil
+}
+
+func (e *jsonEncoder) visitBranchStmt(n *BranchStmt) WType {
+	e.out = jsonNode{"type": "BranchStmt", "tok": n.Type.String(), "pos": toJSONPos(n.Token.Pos),
+		"end": toJSONPos(n.Token.End)}
+	return nil
+}
+
+func (e *jsonEncoder) visitReturnStmt(n *ReturnStmt) WType {
+	e.out = jsonNode{"type": "ReturnStmt", "pos": toJSONPos(n.Token.Pos), "end": toJSONPos(n.Token.End),
+		"result": e.expr(n.result)}
+	return nil
+}
+
+func (e *jsonEncoder) visitYieldStmt(n *YieldStmt) WType {
+	e.out = jsonNode{"type": "YieldStmt", "pos": toJSONPos(n.Token.Pos), "end": toJSONPos(n.Token.End),
+		"value": e.expr(n.value)}
+	return nil
+}
+
+func (e *jsonEncoder) visitBinExpr(n *BinExpr) WType {
+	e.out = jsonNode{"type": "BinExpr", "op": n.op.Type.String(), "pos": toJSONPos(n.opPos),
+		"left": e.expr(n.left), "right": e.expr(n.right)}
+	return nil
+}
+
+func (e *jsonEncoder) visitUnExpr(n *UnExpr) WType {
+	e.out = jsonNode{"type": "UnExpr", "op": n.op.Type.String(), "pos": toJSONPos(n.opPos),
+		"operand": e.expr(n.operand)}
+	return nil
+}
+
+func (e *jsonEncoder) visitGrpExpr(n *GrpExpr) WType {
+	e.out = jsonNode{"type": "GrpExpr", "lround": toJSONPos(n.LRoundPos), "rround": toJSONPos(n.RRoundPos),
+		"x": e.expr(n.x)}
+	return nil
+}
+
+func (e *jsonEncoder) visitCallExpr(n *CallExpr) WType {
+	e.out = jsonNode{"type": "CallExpr", "lround": toJSONPos(n.LRoundPos), "rround": toJSONPos(n.RRoundPos),
+		"fn": e.expr(n.fn), "args": e.exprs(n.args)}
+	return nil
+}
+
+func (e *jsonEncoder) visitIndexExpr(n *IndexExpr) WType {
+	e.out = jsonNode{"type": "IndexExpr", "lsquare": toJSONPos(n.LSqPos), "rsquare": toJSONPos(n.RSqPos),
+		"x": e.expr(n.x), "index": e.expr(n.index)}
+	return nil
+}
+
+func (e *jsonEncoder) visitSliceExpr(n *SliceExpr) WType {
+	e.out = jsonNode{"type": "SliceExpr", "lsquare": toJSONPos(n.LSqPos), "rsquare": toJSONPos(n.RSqPos),
+		"x": e.expr(n.x), "lo": e.expr(n.lo), "hi": e.expr(n.hi)}
+	return nil
+}
+
+func (e *jsonEncoder) visitBasicLit(n *BasicLit) WType {
+	e.out = jsonNode{"type": "BasicLit", "kind": n.Type.String(), "value": n.Text,
+		"pos": toJSONPos(n.Token.Pos), "end": toJSONPos(n.Token.End)}
+	return nil
+}
+
+func (e *jsonEncoder) visitList(n *List) WType {
+	e.out = jsonNode{"type": "List", "lsquare": toJSONPos(n.LSqPos), "rsquare": toJSONPos(n.RSqPos),
+		"elements": e.exprs(n.elements)}
+	return nil
+}
+
+func (e *jsonEncoder) visitTuple(n *Tuple) WType {
+	e.out = jsonNode{"type": "Tuple", "lround": toJSONPos(n.LRoundPos), "rround": toJSONPos(n.RRoundPos),
+		"elements": e.exprs(n.elements)}
+	return nil
+}
+
+func (e *jsonEncoder) visitMap(n *Map) WType {
+	e.out = jsonNode{"type": "Map", "lcurly": toJSONPos(n.LCurlyPos), "rcurly": toJSONPos(n.RCurlyPos),
+		"keys": e.exprs(n.keys), "values": e.exprs(n.values)}
+	return nil
+}
+
+func (e *jsonEncoder) visitComprehension(n *Comprehension) WType {
+	e.out = jsonNode{"type": "Comprehension", "lpos": toJSONPos(n.LPos), "rpos": toJSONPos(n.RPos),
+		"key": e.expr(n.key), "value": e.expr(n.value), "forKey": e.ident(n.forKey),
+		"forValue": e.ident(n.forValue), "iter": e.expr(n.iter), "cond": e.expr(n.cond)}
+	return nil
+}
+
+func (e *jsonEncoder) visitFuncLit(n *FuncLit) WType {
+	e.out = jsonNode{"type": "FuncLit", "pos": toJSONPos(n.FuncPos), "params": e.idents(n.params),
+		"body": e.node(n.body)}
+	return nil
+}
+
+func (e *jsonEncoder) visitID(n *Ident) WType {
+	e.out = jsonNode{"type": "Ident", "name": n.Name, "pos": toJSONPos(n.Token.Pos), "end": toJSONPos(n.Token.End)}
+	return nil
+}
+
+// jsonError is the panic value of the jsonDecoder, UnmarshalJSON returns it
+type jsonError struct{ msg string }
+
+func (e jsonError) Error() string { return "invalid AST JSON: " + e.msg }
+
+// jsonDecoder builds the nodes from their JSON form, panicking with a jsonError
+// if the JSON is not a valid AST
+type jsonDecoder struct{}
+
+// jsonObject is a decoded JSON object whose fields are yet to be decoded
+type jsonObject map[string]json.RawMessage
+
+func (d *jsonDecoder) errorf(format string, args ...interface{}) {
+	panic(jsonError{fmt.Sprintf(format, args...)})
+}
+
+// unmarshal decodes the raw JSON into v
+func (d *jsonDecoder) unmarshal(raw json.RawMessage, v interface{}) {
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.errorf("%s", err)
+	}
+}
+
+// isNull reports whether the raw JSON is missing or null
+func isNull(raw json.RawMessage) bool { return len(raw) == 0 || string(raw) == "null" }
+
+// field returns the raw JSON of the field, which must be present
+func (d *jsonDecoder) field(obj jsonObject, key string) json.RawMessage {
+	raw := obj[key]
+	if isNull(raw) {
+		d.errorf("missing %s", key)
+	}
+	return raw
+}
+
+func (d *jsonDecoder) str(obj jsonObject, key string) string {
+	var s string
+	d.unmarshal(d.field(obj, key), &s)
+	return s
+}
+
+func (d *jsonDecoder) pos(obj jsonObject, key string) token.Pos {
+	var p jsonPos
+	d.unmarshal(d.field(obj, key), &p)
+	return token.NewPos(p.Line, p.Col)
+}
+
+// tokenType decodes the token type named in the field, which must be one of
+// the types given
+func (d *jsonDecoder) tokenType(obj jsonObject, key string, valid ...token.Type) token.Type {
+	name := d.str(obj, key)
+	if t, ok := token.LookupType(name); ok {
+		for _, v := range valid {
+			if t == v {
+				return t
+			}
+		}
+	}
+	d.errorf("unexpected %s %q", key, name)
+	return 0
+}
+
+// tkn returns the token of the type at the position given in the field "pos",
+// ending at the position in the field "end" if the node has one
+func (d *jsonDecoder) tkn(obj jsonObject, typ token.Type, value string) token.Token {
+	t := token.Token{Type: typ, Value: value, Pos: d.pos(obj, "pos")}
+	if !isNull(obj["end"]) {
+		t.End = d.pos(obj, "end")
+	}
+	return t
+}
+
+// posTkn returns a token at the position in the field, for the constructors
+// that only keep the positions of their tokens
+func (d *jsonDecoder) posTkn(obj jsonObject, key string) token.Token {
+	return token.Token{Pos: d.pos(obj, key)}
+}
+
+// node decodes a node of any type, returning nil for null
+func (d *jsonDecoder) node(raw json.RawMessage) Node {
+	if isNull(raw) {
+		return nil
+	}
+	var obj jsonObject
+	d.unmarshal(raw, &obj)
+	typ := d.str(obj, "type")
+	switch typ {
+	case "File":
+		var comments []jsonComment
+		if !isNull(obj["comments"]) {
+			d.unmarshal(obj["comments"], &comments)
+		}
+		var tkns []token.Token
+		for _, c := range comments {
+			tkns = append(tkns, token.Token{Type: token.COMMENT, Value: c.Text,
+				Pos: token.NewPos(c.Pos.Line, c.Pos.Col), End: token.NewPos(c.End.Line, c.End.Col)})
+		}
+		stmts := d.stmts(obj, "stmts")
+		return &File{Name: d.str(obj, "name"), Comments: tkns, Symbols: fileSymbols(stmts), stmts: stmts}
+	case "ExprStmt":
+		return newExprStmt(d.nonEmptyExprs(obj, "exprs"))
+	case "AssignStmt", "PlusAssignStmt", "MinusAssignStmt", "DivAssignStmt", "MultAssignStmt", "ModAssignStmt":
+		return newAssignStmt(d.nonEmptyExprs(obj, "left"), d.nonEmptyExprs(obj, "right"), assignOps[typ])
+	case "VarDecl":
+		names := d.idents(obj, "names")
+		if len(names) == 0 {
+			d.errorf("VarDecl has no names")
+		}
+		return newVarDecl(names, d.exprs(obj, "values"), d.posTkn(obj, "pos"))
+	case "FuncDecl":
+		return newFuncDecl(d.ident(obj, "name"), d.funcLit(obj, "fn"))
+	case "BlockStmt":
+		return newBlockStmt(d.stmts(obj, "stmts"), d.posTkn(obj, "lcurly"), d.posTkn(obj, "rcurly"))
+	case "IfStmt":
+		return newIfStmt(d.expr(obj, "cond"), d.block(obj, "body"), d.optStmt(obj, "else"), d.posTkn(obj, "pos"))
+	case "WhileStmt":
+		return newWhileStmt(d.expr(obj, "cond"), d.block(obj, "body"), d.posTkn(obj, "pos"))
+	case "ForStmt":
+		var value *Ident
+		if !isNull(obj["value"]) {
+			value = d.ident(obj, "value")
+		}
+		return newForStmt(d.ident(obj, "key"), value, d.expr(obj, "iter"), d.block(obj, "body"), d.posTkn(obj, "pos"))
+	case "BranchStmt":
+		t := d.tokenType(obj, "tok", token.BREAK, token.CONT)
+		return newBranchStmt(d.tkn(obj, t, t.String()))
+	case "ReturnStmt":
+		return newReturnStmt(d.optExpr(obj, "result"), d.tkn(obj, token.RETURN, token.RETURN.String()))
+	case "YieldStmt":
+		return newYieldStmt(d.expr(obj, "value"), d.tkn(obj, token.YIELD, token.YIELD.String()))
+	case "BinExpr":
+		op := d.tokenType(obj, "op", binaryOps...)
+		return newBinExpr(d.expr(obj, "left"), d.expr(obj, "right"), d.tkn(obj, op, op.String()))
+	case "UnExpr":
+		op := d.tokenType(obj, "op", token.LOGICALNOT, token.PLUS, token.MINUS)
+		return newUnExpr(d.expr(obj, "operand"), d.tkn(obj, op, op.String()))
+	case "GrpExpr":
+		return newGrpExpr(d.expr(obj, "x"), d.posTkn(obj, "lround"), d.posTkn(obj, "rround"))
+	case "CallExpr":
+		return newCallExpr(d.expr(obj, "fn"), d.exprs(obj, "args"), d.posTkn(obj, "lround"), d.posTkn(obj, "rround"))
+	case "IndexExpr":
+		return newIndexExpr(d.expr(obj, "x"), d.expr(obj, "index"), d.posTkn(obj, "lsquare"), d.posTkn(obj, "rsquare"))
+	case "SliceExpr":
+		return newSliceExpr(d.expr(obj, "x"), d.optExpr(obj, "lo"), d.optExpr(obj, "hi"),
+			d.posTkn(obj, "lsquare"), d.posTkn(obj, "rsquare"))
+	case "BasicLit":
+		kind := d.tokenType(obj, "kind", token.STR, token.CHAR, token.INT, token.FLOAT,
+			token.FALSE, token.TRUE, token.NULL)
+		return newBasicLit(d.tkn(obj, kind, d.str(obj, "value")))
+	case "List":
+		return newList(d.exprs(obj, "elements"), d.posTkn(obj, "lsquare"), d.posTkn(obj, "rsquare"))
+	case "Tuple":
+		return newTuple(d.exprs(obj, "elements"), d.posTkn(obj, "lround"), d.posTkn(obj, "rround"))
+	case "Map":
+		keys, values := d.exprs(obj, "keys"), d.exprs(obj, "values")
+		if len(keys) != len(values) {
+			d.errorf("Map has %d keys but %d values", len(keys), len(values))
+		}
+		return newMap(keys, values, d.posTkn(obj, "lcurly"), d.posTkn(obj, "rcurly"))
+	case "Comprehension":
+		var forValue *Ident
+		if !isNull(obj["forValue"]) {
+			forValue = d.ident(obj, "forValue")
+		}
+		return newComprehension(d.optExpr(obj, "key"), d.expr(obj, "value"), d.ident(obj, "forKey"), forValue,
+			d.expr(obj, "iter"), d.optExpr(obj, "cond"), d.posTkn(obj, "lpos"), d.posTkn(obj, "rpos"))
+	case "FuncLit":
+		return newFuncLit(d.idents(obj, "params"), d.block(obj, "body"), d.posTkn(obj, "pos"))
+	case "Ident":
+		return newID(d.tkn(obj, token.NAME, d.str(obj, "name")))
+	}
+	d.errorf("unknown node type %q", typ)
+	return nil
+}
+
+// assignOps maps the types of the assignment statements to their operators
+var assignOps = map[string]token.Type{
+	"AssignStmt":      token.ASSIGN,
+	"PlusAssignStmt":  token.PLUSASSIGN,
+	"MinusAssignStmt": token.MINUSASSIGN,
+	"DivAssignStmt":   token.DIVASSIGN,
+	"MultAssignStmt":  token.MULTASSIGN,
+	"ModAssignStmt":   token.MODASSIGN,
+}
+
+// binaryOps holds the operators of binary expressions
+var binaryOps = func() []token.Type {
+	ops := make([]token.Type, 0, len(binaryPrecs))
+	for op := range binaryPrecs {
+		ops = append(ops, op)
+	}
+	return ops
+}()
+
+// expr decodes the expression in the field, which must be present
+func (d *jsonDecoder) expr(obj jsonObject, key string) Expr {
+	x := d.optExpr(obj, key)
+	if x == nil {
+		d.errorf("missing %s", key)
+	}
+	return x
+}
+
+// optExpr decodes the expression in the field, returning nil if it is null
+func (d *jsonDecoder) optExpr(obj jsonObject, key string) Expr {
+	n := d.node(obj[key])
+	if n == nil {
+		return nil
+	}
+	x, ok := n.(Expr)
+	if !ok {
+		d.errorf("%s is not an expression", key)
+	}
+	return x
+}
+
+// optStmt decodes the statement in the field, returning nil if it is null
+func (d *jsonDecoder) optStmt(obj jsonObject, key string) Stmt {
+	n := d.node(obj[key])
+	if n == nil {
+		return nil
+	}
+	s, ok := n.(Stmt)
+	if !ok {
+		d.errorf("%s is not a statement", key)
+	}
+	return s
+}
+
+func (d *jsonDecoder) ident(obj jsonObject, key string) *Ident {
+	id, ok := d.expr(obj, key).(*Ident)
+	if !ok {
+		d.errorf("%s is not an Ident", key)
+	}
+	return id
+}
+
+func (d *jsonDecoder) block(obj jsonObject, key string) *BlockStmt {
+	b, ok := d.optStmt(obj, key).(*BlockStmt)
+	if !ok {
+		d.errorf("%s is not a BlockStmt", key)
+	}
+	return b
+}
+
+func (d *jsonDecoder) funcLit(obj jsonObject, key string) *FuncLit {
+	fn, ok := d.expr(obj, key).(*FuncLit)
+	if !ok {
+		d.errorf("%s is not a FuncLit", key)
+	}
+	return fn
+}
+
+// list decodes the elements of the JSON array in the field
+func (d *jsonDecoder) list(obj jsonObject, key string) []jsonObject {
+	var raws []json.RawMessage
+	if !isNull(obj[key]) {
+		d.unmarshal(obj[key], &raws)
+	}
+	objs := make([]jsonObject, len(raws))
+	for i, raw := range raws {
+		objs[i] = jsonObject{key: raw}
+	}
+	return objs
+}
+
+func (d *jsonDecoder) exprs(obj jsonObject, key string) []Expr {
+	var xs []Expr
+	for _, el := range d.list(obj, key) {
+		xs = append(xs, d.expr(el, key))
+	}
+	return xs
+}
+
+// nonEmptyExprs decodes the expressions in the field, of which there must be
+// at least one
+func (d *jsonDecoder) nonEmptyExprs(obj jsonObject, key string) []Expr {
+	xs := d.exprs(obj, key)
+	if len(xs) == 0 {
+		d.errorf("missing %s", key)
+	}
+	return xs
+}
+
+func (d *jsonDecoder) stmts(obj jsonObject, key string) []Stmt {
+	var ss []Stmt
+	for _, el := range d.list(obj, key) {
+		s := d.optStmt(el, key)
+		if s == nil {
+			d.errorf("missing statement in %s", key)
+		}
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+func (d *jsonDecoder) idents(obj jsonObject, key string) []*Ident {
+	var ids []*Ident
+	for _, el := range d.list(obj, key) {
+		ids = append(ids, d.ident(el, key))
+	}
+	return ids
+}
@@ -0,0 +1,297 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+// Lint check IDs, these are stable and may be used to refer to a specific check
+const (
+	LintSelfComparison = "L001" // comparison of an expression with itself, e.g. x == x
+	LintUnusedVariable = "L002" // variable of a function that is never read
+	LintUnreachable    = "L003" // statement after a return, break or continue
+	LintShadowedName   = "L004" // declaration hiding the name of an enclosing scope
+)
+
+// LintIssue is a potential problem reported by a lint check
+type LintIssue struct {
+	Pos      token.Pos
+	End      token.Pos      // end position of the node the issue is about
+	CheckID  string         // ID of the check that reported the issue
+	Severity token.Severity // all checks report warnings for now
+	Message  string
+}
+
+func (li LintIssue) String() string {
+	return fmt.Sprintf("%s: %s %s", li.Pos.String(), li.CheckID, li.Message)
+}
+
+// Lint runs all lint checks over the AST rooted at node, returning the issues
+// found in the order they were found
+func Lint(node Node) []LintIssue {
+	if node == nil {
+		return nil
+	}
+	l := &linter{}
+	node.accept(l)
+	return l.issues
+}
+
+// linter implements NodeWalker, it walks the whole AST and runs the lint checks
+// on each node
+type linter struct {
+	issues []LintIssue
+	scope  *lintScope // innermost scope of the node being visited
+}
+
+// lintScope holds the names bound in the script outside of any function, in a
+// function or in the for clause of a comprehension
+type lintScope struct {
+	parent *lintScope
+	names  map[string]*lintName
+	order  []*lintName // in the order they were bound
+}
+
+// lintName is a name bound in a scope
+type lintName struct {
+	id   *Ident // where the name is first bound
+	used bool
+	// whether the name is reported when never used, only the variables of
+	// functions are as those of the script may be used once it is imported
+	local bool
+}
+
+// lookup returns the name bound in the scope or the scopes enclosing it, nil
+// if there is none
+func (s *lintScope) lookup(name string) *lintName {
+	for ; s != nil; s = s.parent {
+		if n, ok := s.names[name]; ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// enter opens a new scope in the current one
+func (l *linter) enter() { l.scope = &lintScope{parent: l.scope, names: map[string]*lintName{}} }
+
+// leave closes the current scope, reporting its unused variables
+func (l *linter) leave() {
+	for _, n := range l.scope.order {
+		if n.local && !n.used && !strings.HasPrefix(n.id.Name, "_") {
+			l.report(LintUnusedVariable, n.id, "%s is never used", n.id.Name)
+		}
+	}
+	l.scope = l.scope.parent
+}
+
+// declare binds the name in the current scope unless it is already, reporting
+// it if it shadows the same name of an enclosing scope
+func (l *linter) declare(id *Ident, local bool) {
+	if _, ok := l.scope.names[id.Name]; ok {
+		return
+	}
+	if outer := l.scope.parent.lookup(id.Name); outer != nil {
+		l.report(LintShadowedName, id, "%s shadows the name bound at %s", id.Name, outer.id.Pos())
+	}
+	n := &lintName{id: id, local: local}
+	l.scope.names[id.Name] = n
+	l.scope.order = append(l.scope.order, n)
+}
+
+// bind binds the names that the statements bind in the current scope, those
+// only assigned to rebinding the name of an enclosing scope if there is one
+func (l *linter) bind(stmts []Stmt, local bool) {
+	bindings(stmts, func(id *Ident, declared bool) {
+		if declared || l.scope.lookup(id.Name) == nil {
+			l.declare(id, local)
+		}
+	})
+}
+
+func (l *linter) report(checkID string, node Node, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{Pos: node.Pos(), End: node.End(), CheckID: checkID,
+		Severity: token.SeverityWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// walk visits each of the nodes
+func (l *linter) walk(nodes ...Expr) {
+	for _, n := range nodes {
+		n.accept(l)
+	}
+}
+
+// walkStmts visits each of the statements
+func (l *linter) walkStmts(stmts ...Stmt) {
+	for _, n := range stmts {
+		n.accept(l)
+	}
+}
+
+// walkTargets visits the targets of an assignment, the names assigned to are
+// not visited as they are not read
+func (l *linter) walkTargets(targets ...Expr) {
+	for _, n := range targets {
+		if _, ok := n.(*Ident); !ok {
+			n.accept(l)
+		}
+	}
+}
+
+func (l *linter) visitFile(n *File) WType {
+	l.enter()
+	l.bind(n.stmts, false)
+	l.walkStmts(n.stmts...)
+	l.leave()
+	return nil
+}
+
+func (l *linter) visitExprStmt(n *ExprStmt) WType { l.walk(n.exprs...); return nil }
+func (l *linter) visitAssignStmt(n *AssignStmt) WType {
+	l.walkTargets(n.left...)
+	l.walk(n.right...)
+	return nil
+}
+func (l *linter) visitPlusAssignStmt(n *PlusAssignStmt) WType {
+	l.walkTargets(n.left...)
+	l.walk(n.right...)
+	return nil
+}
+func (l *linter) visitMinusAssignStmt(n *MinusAssignStmt) WType {
+	l.walkTargets(n.left...)
+	l.walk(n.right...)
+	return nil
+}
+func (l *linter) visitDivAssignStmt(n *DivAssignStmt) WType {
+	l.walkTargets(n.left...)
+	l.walk(n.right...)
+	return nil
+}
+func (l *linter) visitMultAssignStmt(n *MultAssignStmt) WType {
+	l.walkTargets(n.left...)
+	l.walk(n.right...)
+	return nil
+}
+func (l *linter) visitModAssignStmt(n *ModAssignStmt) WType {
+	l.walkTargets(n.left...)
+	l.walk(n.right...)
+	return nil
+}
+func (l *linter) visitVarDecl(n *VarDecl) WType   { l.walk(n.values...); return nil }
+func (l *linter) visitFuncDecl(n *FuncDecl) WType { l.walk(n.fn); return nil }
+func (l *linter) visitBlockStmt(n *BlockStmt) WType {
+	for k, stmt := range n.stmts {
+		switch stmt.(type) {
+		case *ReturnStmt, *BranchStmt:
+			if k+1 < len(n.stmts) {
+				l.report(LintUnreachable, n.stmts[k+1], "unreachable code")
+			}
+		}
+		stmt.accept(l)
+	}
+	return nil
+}
+func (l *linter) visitIfStmt(n *IfStmt) WType {
+	l.walk(n.cond)
+	l.walkStmts(n.body)
+	if n.elseStmt != nil {
+		l.walkStmts(n.elseStmt)
+	}
+	return nil
+}
+func (l *linter) visitWhileStmt(n *WhileStmt) WType {
+	l.walk(n.cond)
+	l.walkStmts(n.body)
+	return nil
+}
+func (l *linter) visitForStmt(n *ForStmt) WType {
+	l.walk(n.iter)
+	l.walkStmts(n.body)
+	return nil
+}
+func (l *linter) visitBranchStmt(n *BranchStmt) WType { return nil }
+func (l *linter) visitReturnStmt(n *ReturnStmt) WType {
+	if n.result != nil {
+		l.walk(n.result)
+	}
+	return nil
+}
+
+func (l *linter) visitYieldStmt(n *YieldStmt) WType { l.walk(n.value); return nil }
+
+func (l *linter) visitBinExpr(n *BinExpr) WType {
+	switch n.op.Type {
+	case token.EQ, token.NEQ, token.SM, token.SMEQ, token.GR, token.GREQ:
+		// the s-expression forms of structurally equal expressions are the
+		// same, those that may have side effects like calls are left out as
+		// evaluating them twice may give different values
+		ap := &AstPrinter{}
+		if left := ap.Print(n.left); pure(n.left) && left == ap.Print(n.right) {
+			l.report(LintSelfComparison, n, "suspicious comparison of %s with itself", left)
+		}
+	}
+	l.walk(n.left, n.right)
+	return nil
+}
+func (l *linter) visitUnExpr(n *UnExpr) WType   { l.walk(n.operand); return nil }
+func (l *linter) visitGrpExpr(n *GrpExpr) WType { l.walk(n.x); return nil }
+func (l *linter) visitCallExpr(n *CallExpr) WType {
+	l.walk(n.fn)
+	l.walk(n.args...)
+	return nil
+}
+func (l *linter) visitIndexExpr(n *IndexExpr) WType { l.walk(n.x, n.index); return nil }
+func (l *linter) visitSliceExpr(n *SliceExpr) WType {
+	l.walk(n.x)
+	for _, bound := range []Expr{n.lo, n.hi} {
+		if bound != nil {
+			l.walk(bound)
+		}
+	}
+	return nil
+}
+
+func (l *linter) visitBasicLit(n *BasicLit) WType { return nil }
+func (l *linter) visitList(n *List) WType         { l.walk(n.elements...); return nil }
+func (l *linter) visitTuple(n *Tuple) WType       { l.walk(n.elements...); return nil }
+func (l *linter) visitMap(n *Map) WType {
+	l.walk(n.keys...)
+	l.walk(n.values...)
+	return nil
+}
+func (l *linter) visitComprehension(n *Comprehension) WType {
+	// the iterable is evaluated outside of the scope of the for clause
+	l.walk(n.iter)
+	l.enter()
+	l.declare(n.forKey, false)
+	if n.forValue != nil {
+		l.declare(n.forValue, false)
+	}
+	if n.key != nil {
+		l.walk(n.key)
+	}
+	l.walk(n.value)
+	if n.cond != nil {
+		l.walk(n.cond)
+	}
+	l.leave()
+	return nil
+}
+func (l *linter) visitFuncLit(n *FuncLit) WType {
+	l.enter()
+	for _, param := range n.params {
+		l.declare(param, false)
+	}
+	l.bind(n.body.stmts, true)
+	l.walkStmts(n.body)
+	l.leave()
+	return nil
+}
+func (l *linter) visitID(n *Ident) WType {
+	if name := l.scope.lookup(n.Name); name != nil {
+		name.used = true
+	}
+	return nil
+}
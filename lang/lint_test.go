@@ -0,0 +1,66 @@
+package lang
+
+import (
+	"testing"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+func TestLint(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected []string // the issues, in the order they were found
+	}{
+		{"y = x == x", []string{"1:5: L001 suspicious comparison of x with itself"}},
+		{"y = x != x", []string{"1:5: L001 suspicious comparison of x with itself"}},
+		{"y = a[1] <= a[1]", []string{"1:5: L001 suspicious comparison of (index a 1) with itself"}},
+		{"y = (a) == (a)", []string{"1:5: L001 suspicious comparison of a with itself"}},
+		{"if x >= x { print(y < y) }", []string{"1:4: L001 suspicious comparison of x with itself",
+			"1:19: L001 suspicious comparison of y with itself"}},
+		{"func f(a) {\n\twhile a == a { return [b for b in a if b > b] }\n}", []string{
+			"2:8: L001 suspicious comparison of a with itself", "2:41: L001 suspicious comparison of b with itself"}},
+		{"for k, v in m { x = {k: v == v} }", []string{"1:25: L001 suspicious comparison of v with itself"}},
+		{"y = x == y", nil},
+		{"y = x + x", nil},
+		{"y = a[1] == a[2]", nil},
+		{"y = 1 == 1.0", nil},
+		{"y = f(1) > f(1)", nil}, // calls may have side effects
+		{"func f(a) {\n\tb = 1\n\tc = a\n\treturn a\n}", []string{"2:2: L002 b is never used",
+			"3:2: L002 c is never used"}},
+		{"func f() {\n\tvar x\n\tfunc g() { x = 1 }\n\tg()\n}", []string{"2:6: L002 x is never used"}},
+		{"func f() {\n\tn = 0\n\tfunc g() { return n }\n\treturn g\n}", nil},
+		{"x = 1\nfunc f() { x = 2 }", nil}, // rebinds the global x
+		{"func f(_a) { _b = 1 }", nil},
+		{"func f(a) {\n\treturn a\n\tprint(a)\n}", []string{"3:2: L003 unreachable code"}},
+		{"while x {\n\tbreak\n\tx = 1\n\ty = 2\n}", []string{"3:2: L003 unreachable code"}},
+		{"for x in xs { if x { continue; print(x) } }", []string{"1:32: L003 unreachable code"}},
+		{"x = 1\nfunc f(x) { return x }", []string{"2:8: L004 x shadows the name bound at 1:1"}},
+		{"func f(a) {\n\tvar a\n\treturn [a for a in a]\n}", []string{"3:16: L004 a shadows the name bound at 1:8"}},
+		{"func f() {\n\tvar g = 1\n\treturn g\n}\nfunc g() {}", []string{"2:6: L004 g shadows the name bound at 5:6"}},
+	} {
+		f, err := ParseFile("lint", tc.input)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.input, err)
+		}
+		issues := Lint(f)
+		var got []string
+		for _, issue := range issues {
+			got = append(got, issue.String())
+			if issue.Severity != token.SeverityWarning || issue.End <= issue.Pos {
+				t.Errorf("%q: got issue %+v, expected a warning spanning its node", tc.input, issue)
+			}
+		}
+		if len(got) != len(tc.expected) {
+			t.Errorf("%q: got issues %q, expected %q", tc.input, got, tc.expected)
+			continue
+		}
+		for k := range got {
+			if got[k] != tc.expected[k] {
+				t.Errorf("%q: got issue %q, expected %q", tc.input, got[k], tc.expected[k])
+			}
+		}
+	}
+	if issues := Lint(nil); issues != nil {
+		t.Errorf("got issues %v for no AST, expected none", issues)
+	}
+}
@@ -29,6 +29,38 @@ type (
 	}
 )
 
+// File is the root of the AST of a script, holding its statements
+type File struct {
+	Name     string        // name of the input
+	Comments []token.Token // comments of the input, in order
+	Symbols  *SymbolTable  // names bound at the top level of the script, outside of functions
+	Scope
+	stmts []Stmt
+}
+
+func (n *File) accept(nw NodeWalker) WType { return nw.visitFile(n) }
+
+// Pos returns the position of the first statement, or the zero Pos if the file
+// has no statements
+func (n *File) Pos() token.Pos {
+	if len(n.stmts) == 0 {
+		return 0
+	}
+	return n.stmts[0].Pos()
+}
+
+// End returns the end position of the last statement, or the zero Pos if the
+// file has no statements
+func (n *File) End() token.Pos {
+	if len(n.stmts) == 0 {
+		return 0
+	}
+	return n.stmts[len(n.stmts)-1].End()
+}
+
+// Stmts returns the statements of the file
+func (n *File) Stmts() []Stmt { return n.stmts }
+
 // Statements
 type (
 	// ExprStmt is an expression statement, it can have a comma separated
@@ -73,6 +105,71 @@ type (
 		left  []Expr
 		right []Expr
 	}
+	// VarDecl declares variables in the current scope, initialised to the
+	// values if given, null otherwise
+	VarDecl struct {
+		VarPos token.Pos // the position of the "var" keyword
+		Scope
+		names  []*Ident
+		values []Expr
+	}
+	// FuncDecl binds a function to its name in the current scope
+	FuncDecl struct {
+		Scope
+		name *Ident
+		fn   *FuncLit
+	}
+	// BlockStmt is a list of statements enclosed in curly brackets
+	BlockStmt struct {
+		LCurlyPos token.Pos // the position of the opening curly bracket "{"
+		RCurlyPos token.Pos // the position of the closing curly bracket "}"
+		Scope
+		stmts []Stmt
+	}
+	// IfStmt runs its body if the condition holds, the else branch is either
+	// an *IfStmt for "elif", a *BlockStmt for "else", or nil
+	IfStmt struct {
+		IfPos token.Pos // the position of the "if" or "elif" keyword
+		Scope
+		cond     Expr
+		body     *BlockStmt
+		elseStmt Stmt
+	}
+	// WhileStmt runs its body as long as the condition holds
+	WhileStmt struct {
+		WhilePos token.Pos // the position of the "while" keyword
+		Scope
+		cond Expr
+		body *BlockStmt
+	}
+	// ForStmt runs its body for each element of an iterable, the key is bound
+	// to the element, or to its index (or key) if a value is given
+	ForStmt struct {
+		ForPos token.Pos // the position of the "for" keyword
+		Scope
+		key   *Ident
+		value *Ident // nil if not given
+		iter  Expr
+		body  *BlockStmt
+	}
+	// BranchStmt is a "break" or "continue" statement
+	BranchStmt struct {
+		token.Token // token.BREAK or token.CONT
+		Scope
+	}
+	// ReturnStmt returns from a function with the result if given, null
+	// otherwise
+	ReturnStmt struct {
+		token.Token // the "return" keyword
+		Scope
+		result Expr // nil if not given
+	}
+	// YieldStmt suspends a generator, producing the value as its next element
+	YieldStmt struct {
+		token.Token // the "yield" keyword
+		Scope
+		value Expr
+	}
 )
 
 func (n *ExprStmt) accept(nw NodeWalker) WType        { return nw.visitExprStmt(n) }
@@ -82,6 +179,15 @@ func (n *MinusAssignStmt) accept(nw NodeWalker) WType { return nw.visitMinusAssi
 func (n *DivAssignStmt) accept(nw NodeWalker) WType   { return nw.visitDivAssignStmt(n) }
 func (n *MultAssignStmt) accept(nw NodeWalker) WType  { return nw.visitMultAssignStmt(n) }
 func (n *ModAssignStmt) accept(nw NodeWalker) WType   { return nw.visitModAssignStmt(n) }
+func (n *VarDecl) accept(nw NodeWalker) WType         { return nw.visitVarDecl(n) }
+func (n *FuncDecl) accept(nw NodeWalker) WType        { return nw.visitFuncDecl(n) }
+func (n *BlockStmt) accept(nw NodeWalker) WType       { return nw.visitBlockStmt(n) }
+func (n *IfStmt) accept(nw NodeWalker) WType          { return nw.visitIfStmt(n) }
+func (n *WhileStmt) accept(nw NodeWalker) WType       { return nw.visitWhileStmt(n) }
+func (n *ForStmt) accept(nw NodeWalker) WType         { return nw.visitForStmt(n) }
+func (n *BranchStmt) accept(nw NodeWalker) WType      { return nw.visitBranchStmt(n) }
+func (n *ReturnStmt) accept(nw NodeWalker) WType      { return nw.visitReturnStmt(n) }
+func (n *YieldStmt) accept(nw NodeWalker) WType       { return nw.visitYieldStmt(n) }
 
 func (n *ExprStmt) stmt()        {}
 func (n *AssignStmt) stmt()      {}
@@ -90,28 +196,116 @@ func (n *MinusAssignStmt) stmt() {}
 func (n *DivAssignStmt) stmt()   {}
 func (n *MultAssignStmt) stmt()  {}
 func (n *ModAssignStmt) stmt()   {}
+func (n *VarDecl) stmt()         {}
+func (n *FuncDecl) stmt()        {}
+func (n *BlockStmt) stmt()       {}
+func (n *IfStmt) stmt()          {}
+func (n *WhileStmt) stmt()       {}
+func (n *ForStmt) stmt()         {}
+func (n *BranchStmt) stmt()      {}
+func (n *ReturnStmt) stmt()      {}
+func (n *YieldStmt) stmt()       {}
+
+func (n *ExprStmt) Pos() token.Pos        { return n.exprs[0].Pos() }
+func (n *AssignStmt) Pos() token.Pos      { return n.left[0].Pos() }
+func (n *PlusAssignStmt) Pos() token.Pos  { return n.left[0].Pos() }
+func (n *MinusAssignStmt) Pos() token.Pos { return n.left[0].Pos() }
+func (n *DivAssignStmt) Pos() token.Pos   { return n.left[0].Pos() }
+func (n *MultAssignStmt) Pos() token.Pos  { return n.left[0].Pos() }
+func (n *ModAssignStmt) Pos() token.Pos   { return n.left[0].Pos() }
+func (n *VarDecl) Pos() token.Pos         { return n.VarPos }
+func (n *FuncDecl) Pos() token.Pos        { return n.fn.FuncPos }
+func (n *BlockStmt) Pos() token.Pos       { return n.LCurlyPos }
+func (n *IfStmt) Pos() token.Pos          { return n.IfPos }
+func (n *WhileStmt) Pos() token.Pos       { return n.WhilePos }
+func (n *ForStmt) Pos() token.Pos         { return n.ForPos }
+func (n *BranchStmt) Pos() token.Pos      { return n.Token.Pos }
+func (n *ReturnStmt) Pos() token.Pos      { return n.Token.Pos }
+func (n *YieldStmt) Pos() token.Pos       { return n.Token.Pos }
+
+func (n *ExprStmt) End() token.Pos        { return n.exprs[len(n.exprs)-1].End() }
+func (n *AssignStmt) End() token.Pos      { return n.right[len(n.right)-1].End() }
+func (n *PlusAssignStmt) End() token.Pos  { return n.right[len(n.right)-1].End() }
+func (n *MinusAssignStmt) End() token.Pos { return n.right[len(n.right)-1].End() }
+func (n *DivAssignStmt) End() token.Pos   { return n.right[len(n.right)-1].End() }
+func (n *MultAssignStmt) End() token.Pos  { return n.right[len(n.right)-1].End() }
+func (n *ModAssignStmt) End() token.Pos   { return n.right[len(n.right)-1].End() }
+func (n *FuncDecl) End() token.Pos        { return n.fn.End() }
+func (n *BlockStmt) End() token.Pos       { return token.AddOffset(n.RCurlyPos, 1) }
+func (n *WhileStmt) End() token.Pos       { return n.body.End() }
+func (n *ForStmt) End() token.Pos         { return n.body.End() }
+func (n *BranchStmt) End() token.Pos      { return n.Token.End }
+func (n *YieldStmt) End() token.Pos       { return n.value.End() }
+
+func (n *VarDecl) End() token.Pos {
+	if len(n.values) > 0 {
+		return n.values[len(n.values)-1].End()
+	}
+	return n.names[len(n.names)-1].End()
+}
+
+// Name returns the name that the function is bound to
+func (n *FuncDecl) Name() *Ident { return n.name }
+
+// Params returns the parameters of the function
+func (n *FuncDecl) Params() []*Ident { return n.fn.params }
+
+func newExprStmt(exprs []Expr) *ExprStmt { return &ExprStmt{exprs: exprs} }
+
+// newAssignStmt returns the assignment statement of the assignment operator
+func newAssignStmt(left, right []Expr, op token.Type) Stmt {
+	switch op {
+	case token.PLUSASSIGN:
+		return &PlusAssignStmt{left: left, right: right}
+	case token.MINUSASSIGN:
+		return &MinusAssignStmt{left: left, right: right}
+	case token.DIVASSIGN:
+		return &DivAssignStmt{left: left, right: right}
+	case token.MULTASSIGN:
+		return &MultAssignStmt{left: left, right: right}
+	case token.MODASSIGN:
+		return &ModAssignStmt{left: left, right: right}
+	}
+	return &AssignStmt{left: left, right: right}
+}
+
+func newVarDecl(names []*Ident, values []Expr, varTkn token.Token) *VarDecl {
+	return &VarDecl{names: names, values: values, VarPos: varTkn.Pos}
+}
+func newFuncDecl(name *Ident, fn *FuncLit) *FuncDecl { return &FuncDecl{name: name, fn: fn} }
+func newBlockStmt(stmts []Stmt, leftCurly, rightCurly token.Token) *BlockStmt {
+	return &BlockStmt{stmts: stmts, LCurlyPos: leftCurly.Pos, RCurlyPos: rightCurly.Pos}
+}
+func newIfStmt(cond Expr, body *BlockStmt, elseStmt Stmt, ifTkn token.Token) *IfStmt {
+	return &IfStmt{cond: cond, body: body, elseStmt: elseStmt, IfPos: ifTkn.Pos}
+}
+func newWhileStmt(cond Expr, body *BlockStmt, whileTkn token.Token) *WhileStmt {
+	return &WhileStmt{cond: cond, body: body, WhilePos: whileTkn.Pos}
+}
+func newForStmt(key, value *Ident, iter Expr, body *BlockStmt, forTkn token.Token) *ForStmt {
+	return &ForStmt{key: key, value: value, iter: iter, body: body, ForPos: forTkn.Pos}
+}
+func newBranchStmt(tkn token.Token) *BranchStmt { return &BranchStmt{Token: tkn} }
+func newReturnStmt(result Expr, tkn token.Token) *ReturnStmt {
+	return &ReturnStmt{result: result, Token: tkn}
+}
+func newYieldStmt(value Expr, tkn token.Token) *YieldStmt {
+	return &YieldStmt{value: value, Token: tkn}
+}
+
+func (n *IfStmt) End() token.Pos {
+	if n.elseStmt != nil {
+		return n.elseStmt.End()
+	}
+	return n.body.End()
+}
 
-// func newExprStmt(expressions []Expr, tkn token.Token) *ExprStmt {
-// 	return &ExprStmt{exprs: expressions, Token: tkn}
-// }
-// func newAssignStmt(left, right []Expr, tkn token.Token) *AssignStmt {
-// 	return &AssignStmt{left: left, right: right, Token: tkn}
-// }
-// func newPlusAssignStmt(left, right []Expr, tkn token.Token) *PlusAssignStmt {
-// 	return &PlusAssignStmt{left: left, right: right, Token: tkn}
-// }
-// func newMinusAssignStmt(left, right []Expr, tkn token.Token) *MinusAssignStmt {
-// 	return &MinusAssignStmt{left: left, right: right, Token: tkn}
-// }
-// func newDivAssignStmt(left, right []Expr, tkn token.Token) *DivAssignStmt {
-// 	return &DivAssignStmt{left: left, right: right, Token: tkn}
-// }
-// func newMultAssignStmt(left, right []Expr, tkn token.Token) *MultAssignStmt {
-// 	return &MultAssignStmt{left: left, right: right, Token: tkn}
-// }
-// func newModAssignStmt(left, right []Expr, tkn token.Token) *ModAssignStmt {
-// 	return &ModAssignStmt{left: left, right: right, Token: tkn}
-// }
+func (n *ReturnStmt) End() token.Pos {
+	if n.result != nil {
+		return n.result.End()
+	}
+	return n.Token.End
+}
 
 // Expressions
 // An expression is represented by a tree consisting of one or more of
@@ -132,19 +326,30 @@ type (
 		Scope
 		operand Expr
 	}
+	// GrpExpr is an expression enclosed in round brackets
+	GrpExpr struct {
+		LRoundPos token.Pos // the position of the opening round bracket "("
+		RRoundPos token.Pos // the position of the closing round bracket ")"
+		Scope
+		x Expr
+	}
 )
 
 func (n *BinExpr) accept(nw NodeWalker) WType { return nw.visitBinExpr(n) }
 func (n *UnExpr) accept(nw NodeWalker) WType  { return nw.visitUnExpr(n) }
+func (n *GrpExpr) accept(nw NodeWalker) WType { return nw.visitGrpExpr(n) }
 
 func (n *BinExpr) expr() {}
 func (n *UnExpr) expr()  {}
+func (n *GrpExpr) expr() {}
 
 func (n *BinExpr) Pos() token.Pos { return n.left.Pos() }
 func (n *UnExpr) Pos() token.Pos  { return n.opPos }
+func (n *GrpExpr) Pos() token.Pos { return n.LRoundPos }
 
 func (n *BinExpr) End() token.Pos { return n.right.End() }
 func (n *UnExpr) End() token.Pos  { return n.operand.End() }
+func (n *GrpExpr) End() token.Pos { return token.AddOffset(n.RRoundPos, 1) }
 
 func newBinExpr(left, right Expr, op token.Token) *BinExpr {
 	return &BinExpr{op: op, opPos: op.Pos, left: left, right: right}
@@ -152,16 +357,70 @@ func newBinExpr(left, right Expr, op token.Token) *BinExpr {
 func newUnExpr(operand Expr, op token.Token) *UnExpr {
 	return &UnExpr{op: op, opPos: op.Pos, operand: operand}
 }
+func newGrpExpr(x Expr, leftRound, rightRound token.Token) *GrpExpr {
+	return &GrpExpr{x: x, LRoundPos: leftRound.Pos, RRoundPos: rightRound.Pos}
+}
+
+// Atom expressions
+type (
+	// CallExpr calls a function with the arguments
+	CallExpr struct {
+		LRoundPos token.Pos // the position of the opening round bracket "("
+		RRoundPos token.Pos // the position of the closing round bracket ")"
+		Scope
+		fn   Expr
+		args []Expr
+	}
+	// IndexExpr indexes a list, string or map
+	IndexExpr struct {
+		LSqPos token.Pos // the position of the opening square bracket "["
+		RSqPos token.Pos // the position of the closing square bracket "]"
+		Scope
+		x     Expr
+		index Expr
+	}
+	// SliceExpr slices a list or string from lo up to but excluding hi
+	SliceExpr struct {
+		LSqPos token.Pos // the position of the opening square bracket "["
+		RSqPos token.Pos // the position of the closing square bracket "]"
+		Scope
+		x  Expr
+		lo Expr // nil if omitted, from the start
+		hi Expr // nil if omitted, up to the end
+	}
+)
+
+func (n *CallExpr) accept(nw NodeWalker) WType  { return nw.visitCallExpr(n) }
+func (n *IndexExpr) accept(nw NodeWalker) WType { return nw.visitIndexExpr(n) }
+func (n *SliceExpr) accept(nw NodeWalker) WType { return nw.visitSliceExpr(n) }
+
+func (n *CallExpr) expr()  {}
+func (n *IndexExpr) expr() {}
+func (n *SliceExpr) expr() {}
+
+func (n *CallExpr) Pos() token.Pos  { return n.fn.Pos() }
+func (n *IndexExpr) Pos() token.Pos { return n.x.Pos() }
+func (n *SliceExpr) Pos() token.Pos { return n.x.Pos() }
+
+func (n *CallExpr) End() token.Pos  { return token.AddOffset(n.RRoundPos, 1) }
+func (n *IndexExpr) End() token.Pos { return token.AddOffset(n.RSqPos, 1) }
+func (n *SliceExpr) End() token.Pos { return token.AddOffset(n.RSqPos, 1) }
 
-// // Atom expressions
-// type funcCall struct {
-// }
+func newCallExpr(fn Expr, args []Expr, leftRound, rightRound token.Token) *CallExpr {
+	return &CallExpr{fn: fn, args: args, LRoundPos: leftRound.Pos, RRoundPos: rightRound.Pos}
+}
+func newIndexExpr(x, index Expr, leftSquare, rightSquare token.Token) *IndexExpr {
+	return &IndexExpr{x: x, index: index, LSqPos: leftSquare.Pos, RSqPos: rightSquare.Pos}
+}
+func newSliceExpr(x, lo, hi Expr, leftSquare, rightSquare token.Token) *SliceExpr {
+	return &SliceExpr{x: x, lo: lo, hi: hi, LSqPos: leftSquare.Pos, RSqPos: rightSquare.Pos}
+}
 
 // Literals
 type (
 	// BasicLit node represents a literal of basic type
 	BasicLit struct {
-		token.Token // token.INT, token.FLOAT, token.STR, token.BOOL, token.NULL
+		token.Token // token.INT, token.FLOAT, token.STR, token.CHAR, token.BOOL, token.NULL
 		Scope
 		Text string
 	}
@@ -173,6 +432,45 @@ type (
 		Scope
 		elements []Expr
 	}
+	// Tuple holds the elements of a tuple literal, the round brackets are
+	// omitted for the results of a return statement
+	Tuple struct {
+		LRoundPos token.Pos // the position of the opening round bracket "(", zero if omitted
+		RRoundPos token.Pos // the position of the closing round bracket ")", zero if omitted
+		Scope
+		elements []Expr
+	}
+	// Map holds the keys and values of a map literal, in the order written
+	Map struct {
+		LCurlyPos token.Pos // the position of the opening curly bracket "{"
+		RCurlyPos token.Pos // the position of the closing curly bracket "}"
+		Scope
+		keys   []Expr
+		values []Expr
+	}
+	// Comprehension builds a list of its value, or a map of its key and value,
+	// for each element of the iterable for which the condition holds, the
+	// names of its for clause are bound as in a for loop but in a scope of
+	// their own, enclosed by the scope the comprehension is in
+	Comprehension struct {
+		LPos token.Pos // the position of the opening bracket, "[" or "{"
+		RPos token.Pos // the position of the closing bracket, "]" or "}"
+		Scope
+		key      Expr // nil for a list comprehension
+		value    Expr
+		forKey   *Ident
+		forValue *Ident // nil if not given
+		iter     Expr
+		cond     Expr // nil if not given
+	}
+	// FuncLit is a function literal, the parameters are bound to the arguments
+	// of a call in a new scope enclosed by the scope the function is defined in
+	FuncLit struct {
+		FuncPos token.Pos // the position of the "func" keyword
+		Scope
+		params []*Ident
+		body   *BlockStmt
+	}
 	// Ident node represents Identifier/Name nodes
 	Ident struct {
 		token.Token
@@ -181,21 +479,47 @@ type (
 	}
 )
 
-func (n *BasicLit) accept(nw NodeWalker) WType { return nw.visitBasicLit(n) }
-func (n *List) accept(nw NodeWalker) WType     { return nw.visitList(n) }
-func (n *Ident) accept(nw NodeWalker) WType    { return nw.visitID(n) }
+func (n *BasicLit) accept(nw NodeWalker) WType      { return nw.visitBasicLit(n) }
+func (n *List) accept(nw NodeWalker) WType          { return nw.visitList(n) }
+func (n *Tuple) accept(nw NodeWalker) WType         { return nw.visitTuple(n) }
+func (n *Map) accept(nw NodeWalker) WType           { return nw.visitMap(n) }
+func (n *Comprehension) accept(nw NodeWalker) WType { return nw.visitComprehension(n) }
+func (n *FuncLit) accept(nw NodeWalker) WType       { return nw.visitFuncLit(n) }
+func (n *Ident) accept(nw NodeWalker) WType         { return nw.visitID(n) }
 
 func (n *BasicLit) Pos() token.Pos { return n.Token.Pos }
 func (n *List) Pos() token.Pos     { return n.LSqPos }
-func (n *Ident) Pos() token.Pos    { return n.Token.Pos }
+func (n *Tuple) Pos() token.Pos {
+	if n.LRoundPos == 0 && len(n.elements) > 0 {
+		return n.elements[0].Pos()
+	}
+	return n.LRoundPos
+}
+func (n *Map) Pos() token.Pos           { return n.LCurlyPos }
+func (n *Comprehension) Pos() token.Pos { return n.LPos }
+func (n *FuncLit) Pos() token.Pos       { return n.FuncPos }
+func (n *Ident) Pos() token.Pos         { return n.Token.Pos }
 
-func (n *BasicLit) End() token.Pos { return token.AddOffset(n.Token.Pos, len(n.Text)) }
-func (n *List) End() token.Pos     { return n.RSqPos }
-func (n *Ident) End() token.Pos    { return token.AddOffset(n.Token.Pos, len(n.Name)) }
+func (n *BasicLit) End() token.Pos { return n.Token.End }
+func (n *List) End() token.Pos     { return token.AddOffset(n.RSqPos, 1) }
+func (n *Tuple) End() token.Pos {
+	if n.RRoundPos == 0 && len(n.elements) > 0 {
+		return n.elements[len(n.elements)-1].End()
+	}
+	return token.AddOffset(n.RRoundPos, 1)
+}
+func (n *Map) End() token.Pos           { return token.AddOffset(n.RCurlyPos, 1) }
+func (n *Comprehension) End() token.Pos { return token.AddOffset(n.RPos, 1) }
+func (n *FuncLit) End() token.Pos       { return n.body.End() }
+func (n *Ident) End() token.Pos         { return n.Token.End }
 
-func (n *BasicLit) expr() {}
-func (n *List) expr()     {}
-func (n *Ident) expr()    {}
+func (n *BasicLit) expr()      {}
+func (n *List) expr()          {}
+func (n *Tuple) expr()         {}
+func (n *Map) expr()           {}
+func (n *Comprehension) expr() {}
+func (n *FuncLit) expr()       {}
+func (n *Ident) expr()         {}
 
 func newBasicLit(tkn token.Token) *BasicLit {
 	return &BasicLit{Token: tkn, Text: tkn.Value}
@@ -205,4 +529,22 @@ func newList(elems []Expr, leftSquare, rightSquare token.Token) *List {
 	return &List{elements: elems, LSqPos: leftSquare.Pos, RSqPos: rightSquare.Pos}
 }
 
+func newTuple(elems []Expr, leftRound, rightRound token.Token) *Tuple {
+	return &Tuple{elements: elems, LRoundPos: leftRound.Pos, RRoundPos: rightRound.Pos}
+}
+
+func newMap(keys, values []Expr, leftCurly, rightCurly token.Token) *Map {
+	return &Map{keys: keys, values: values, LCurlyPos: leftCurly.Pos, RCurlyPos: rightCurly.Pos}
+}
+
+func newComprehension(key, value Expr, forKey, forValue *Ident, iter, cond Expr,
+	left, right token.Token) *Comprehension {
+	return &Comprehension{key: key, value: value, forKey: forKey, forValue: forValue, iter: iter, cond: cond,
+		LPos: left.Pos, RPos: right.Pos}
+}
+
+func newFuncLit(params []*Ident, body *BlockStmt, funcTkn token.Token) *FuncLit {
+	return &FuncLit{params: params, body: body, FuncPos: funcTkn.Pos}
+}
+
 func newID(tkn token.Token) *Ident { return &Ident{Token: tkn, Name: tkn.Value} }
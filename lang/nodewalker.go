@@ -1,7 +1,10 @@
 package lang
 
+import "reflect"
+
 // NodeWalker is the interface to implement for all walkers/visitors to the AST
 type NodeWalker interface {
+	visitFile(*File) WType
 
 	// Statements
 	visitExprStmt(*ExprStmt) WType
@@ -11,6 +14,15 @@ type NodeWalker interface {
 	visitDivAssignStmt(*DivAssignStmt) WType
 	visitMultAssignStmt(*MultAssignStmt) WType
 	visitModAssignStmt(*ModAssignStmt) WType
+	visitVarDecl(*VarDecl) WType
+	visitFuncDecl(*FuncDecl) WType
+	visitBlockStmt(*BlockStmt) WType
+	visitIfStmt(*IfStmt) WType
+	visitWhileStmt(*WhileStmt) WType
+	visitForStmt(*ForStmt) WType
+	visitBranchStmt(*BranchStmt) WType
+	visitReturnStmt(*ReturnStmt) WType
+	visitYieldStmt(*YieldStmt) WType
 
 	// Expressions
 
@@ -32,10 +44,17 @@ type NodeWalker interface {
 	// Unary Expressions
 
 	visitUnExpr(*UnExpr) WType
+	visitGrpExpr(*GrpExpr) WType
 	// visitPlus(*PlusExpr) WType
 	// visitMinus(*MinusExpr) WType
 	// visitNot(*NotExpr) WType
 
+	// Atom Expressions
+
+	visitCallExpr(*CallExpr) WType
+	visitIndexExpr(*IndexExpr) WType
+	visitSliceExpr(*SliceExpr) WType
+
 	// visit literals
 	// visitNum(*Num) WType
 	// visitStr(*Str) WType
@@ -44,5 +63,134 @@ type NodeWalker interface {
 
 	visitBasicLit(*BasicLit) WType
 	visitList(*List) WType
+	visitTuple(*Tuple) WType
+	visitMap(*Map) WType
+	visitComprehension(*Comprehension) WType
+	visitFuncLit(*FuncLit) WType
 	visitID(*Ident) WType
 }
+
+// Inspect traverses the AST rooted at node in depth-first order, calling f for
+// each node, the children of a node are only traversed if f returns true
+func Inspect(node Node, f func(Node) bool) {
+	if node != nil && f(node) {
+		node.accept(inspector(f))
+	}
+}
+
+// inspector implements NodeWalker, it calls itself on the children of each
+// node before traversing them
+type inspector func(Node) bool
+
+// walk inspects each of the nodes
+func (f inspector) walk(nodes ...Expr) {
+	for _, n := range nodes {
+		f.inspect(n)
+	}
+}
+
+// walkStmts inspects each of the statements
+func (f inspector) walkStmts(stmts ...Stmt) {
+	for _, n := range stmts {
+		f.inspect(n)
+	}
+}
+
+// inspect inspects the node unless it is nil, the node may be a nil pointer
+// of an optional child, e.g. a missing else branch
+func (f inspector) inspect(n Node) {
+	if n != nil && !reflect.ValueOf(n).IsNil() && f(n) {
+		n.accept(f)
+	}
+}
+
+// assign inspects the targets and values of an assignment statement
+func (f inspector) assign(left, right []Expr) WType {
+	f.walk(left...)
+	f.walk(right...)
+	return nil
+}
+
+func (f inspector) visitFile(n *File) WType { f.walkStmts(n.stmts...); return nil }
+
+func (f inspector) visitExprStmt(n *ExprStmt) WType             { f.walk(n.exprs...); return nil }
+func (f inspector) visitAssignStmt(n *AssignStmt) WType         { return f.assign(n.left, n.right) }
+func (f inspector) visitPlusAssignStmt(n *PlusAssignStmt) WType { return f.assign(n.left, n.right) }
+func (f inspector) visitMinusAssignStmt(n *MinusAssignStmt) WType {
+	return f.assign(n.left, n.right)
+}
+func (f inspector) visitDivAssignStmt(n *DivAssignStmt) WType   { return f.assign(n.left, n.right) }
+func (f inspector) visitMultAssignStmt(n *MultAssignStmt) WType { return f.assign(n.left, n.right) }
+func (f inspector) visitModAssignStmt(n *ModAssignStmt) WType   { return f.assign(n.left, n.right) }
+func (f inspector) visitVarDecl(n *VarDecl) WType {
+	for _, name := range n.names {
+		f.inspect(name)
+	}
+	f.walk(n.values...)
+	return nil
+}
+func (f inspector) visitFuncDecl(n *FuncDecl) WType { f.walk(n.name, n.fn); return nil }
+func (f inspector) visitBlockStmt(n *BlockStmt) WType {
+	f.walkStmts(n.stmts...)
+	return nil
+}
+func (f inspector) visitIfStmt(n *IfStmt) WType {
+	f.walk(n.cond)
+	f.walkStmts(n.body, n.elseStmt)
+	return nil
+}
+func (f inspector) visitWhileStmt(n *WhileStmt) WType {
+	f.walk(n.cond)
+	f.walkStmts(n.body)
+	return nil
+}
+func (f inspector) visitForStmt(n *ForStmt) WType {
+	f.inspect(n.key)
+	f.inspect(n.value)
+	f.walk(n.iter)
+	f.walkStmts(n.body)
+	return nil
+}
+func (f inspector) visitBranchStmt(n *BranchStmt) WType { return nil }
+func (f inspector) visitReturnStmt(n *ReturnStmt) WType { f.inspect(n.result); return nil }
+func (f inspector) visitYieldStmt(n *YieldStmt) WType   { f.walk(n.value); return nil }
+
+func (f inspector) visitBinExpr(n *BinExpr) WType { f.walk(n.left, n.right); return nil }
+func (f inspector) visitUnExpr(n *UnExpr) WType   { f.walk(n.operand); return nil }
+func (f inspector) visitGrpExpr(n *GrpExpr) WType { f.walk(n.x); return nil }
+func (f inspector) visitCallExpr(n *CallExpr) WType {
+	f.walk(n.fn)
+	f.walk(n.args...)
+	return nil
+}
+func (f inspector) visitIndexExpr(n *IndexExpr) WType { f.walk(n.x, n.index); return nil }
+func (f inspector) visitSliceExpr(n *SliceExpr) WType {
+	f.walk(n.x)
+	f.inspect(n.lo)
+	f.inspect(n.hi)
+	return nil
+}
+func (f inspector) visitBasicLit(n *BasicLit) WType { return nil }
+func (f inspector) visitList(n *List) WType         { f.walk(n.elements...); return nil }
+func (f inspector) visitTuple(n *Tuple) WType       { f.walk(n.elements...); return nil }
+func (f inspector) visitMap(n *Map) WType {
+	for k := range n.keys {
+		f.walk(n.keys[k], n.values[k])
+	}
+	return nil
+}
+func (f inspector) visitComprehension(n *Comprehension) WType {
+	f.walk(n.key, n.value)
+	f.inspect(n.forKey)
+	f.inspect(n.forValue)
+	f.walk(n.iter, n.cond)
+	return nil
+}
+func (f inspector) visitFuncLit(n *FuncLit) WType {
+	for _, param := range n.params {
+		f.inspect(param)
+	}
+	f.walkStmts(n.body)
+	return nil
+}
+func (f inspector) visitID(n *Ident) WType { return nil }
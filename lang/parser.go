@@ -2,7 +2,7 @@ package lang
 
 import (
 	"fmt"
-	"runtime"
+	"strings"
 
 	"github.com/lohvht/went/lang/token"
 )
@@ -16,8 +16,15 @@ type Parser struct {
 	// currentScope *Scope
 	input        string // input text to be parsed
 	tokeniser    *token.Lexer
-	tokens       token.List  // list of token lookaheads
-	currentToken token.Token // the local that we are currently looking at (Not a lookahead)
+	tokens       token.List    // list of token lookaheads
+	currentToken token.Token   // the local that we are currently looking at (Not a lookahead)
+	comments     []token.Token // comments scanned so far, in order
+	errors       SyntaxErrorList
+	notes        []token.Note // notes to attach to the next error reported
+	opts         ParseOptions
+	loopDepth    int // number of loops enclosing the statement being parsed, within its function
+	funcDepth    int // number of functions enclosing the statement being parsed
+	depth        int // nesting depth of the blocks and expressions being parsed
 }
 
 // next consumes and returns the next token
@@ -26,11 +33,22 @@ func (p *Parser) next() token.Token {
 	if !p.tokens.Empty() {
 		p.currentToken = p.tokens.Shift()
 	} else {
-		p.currentToken = p.tokeniser.Next()
+		p.currentToken = p.scan()
 	}
 	return p.currentToken
 }
 
+// scan returns the next token from the token.Lexer, collecting the comments
+// that precede it
+func (p *Parser) scan() token.Token {
+	tkn := p.tokeniser.Next()
+	for tkn.Type == token.COMMENT {
+		p.comments = append(p.comments, tkn)
+		tkn = p.tokeniser.Next()
+	}
+	return tkn
+}
+
 // backup backs up a series of tokens to the bottom of the tokenList
 // you should backup in the same order to preserve the proper token order from
 // the token.Lexer (i.e. if given 3 tokens in this order: tkn1, tkn2, tkn3, you should
@@ -43,21 +61,100 @@ func (p *Parser) peek() token.Token {
 	if !p.tokens.Empty() {
 		return p.tokens.PeekBottom()
 	}
-	p.tokens.Push(p.tokeniser.Next())
+	p.tokens.Push(p.scan())
 	return p.tokens.PeekBottom()
 }
 
 // Parsing
 
-// errorf formats the error and terminates processing.
-func (p *Parser) errorf(format string, args ...interface{}) {
-	p.Root = nil
-	format = fmt.Sprintf("%s: SyntaxError - %s", p.currentToken.Pos.String(), format)
-	panic(fmt.Errorf(format, args...))
+// DefaultMaxErrors is the number of syntax errors after which Parse gives up
+const DefaultMaxErrors = 20
+
+// DefaultMaxDepth is the nesting depth of blocks and expressions after which
+// Parse gives up, deeper inputs would risk overflowing the stack of the parser
+// and of the tools walking the AST
+const DefaultMaxDepth = 1000
+
+// Codes of the syntax errors, these are stable and may be used to refer to a
+// specific error, e.g. in the documentation. An error found by the lexer keeps
+// the code of its token.ErrorKind
+const (
+	SyntaxInternalError     = "W2001" // a bug in the parser, reported instead of crashing
+	SyntaxTooDeep           = "W2002" // blocks or expressions nested beyond ParseOptions.MaxDepth
+	SyntaxUnexpectedToken   = "W2003" // a token that cannot appear at this point
+	SyntaxUnusedExpr        = "W2004" // a top level expression whose value is not used
+	SyntaxBranchOutsideLoop = "W2005" // break or continue outside of a loop
+	SyntaxReturnOutsideFunc = "W2006" // return outside of a function
+	SyntaxDuplicateParam    = "W2007" // a parameter declared twice by a function
+	SyntaxAssignMismatch    = "W2008" // a different number of targets and values
+	SyntaxInvalidTarget     = "W2009" // an assignment to something other than a name or an index
+	SyntaxMultipleAugAssign = "W2010" // a compound assignment of multiple values
+	SyntaxMultipleExprs     = "W2011" // an expression statement of multiple expressions
+	SyntaxTooManyErrors     = "W2012" // parsing stopped after ParseOptions.MaxErrors errors
+	SyntaxYieldOutsideFunc  = "W2013" // yield outside of a function
+)
+
+// SyntaxError is a syntax error found by Parse when the input is not valid went
+type SyntaxError struct {
+	Name  string    // name of the input
+	Pos   token.Pos // position of the token at which the error was found
+	End   token.Pos // end of the token or the node at which the error was found
+	Code  string    // the stable code of the error, e.g. SyntaxUnexpectedToken
+	Msg   string
+	Line  string       // the line of the input at Pos, without its line ending
+	Notes []token.Note // secondary messages about other positions of the input
 }
 
-// error terminates the processing.
-func (p *Parser) error(err error) { p.errorf("%s", err) }
+// Error returns the error followed by the line of the input with a caret
+// under the column of the error, if the line is not blank, then by its notes
+func (e *SyntaxError) Error() string {
+	msgs := []string{fmt.Sprintf("%s:%s: SyntaxError - %s", e.Name, e.Pos.String(), e.Msg)}
+	if snippet := token.Snippet(e.Line, e.Pos.Col()); snippet != "" {
+		msgs = append(msgs, snippet)
+	}
+	for _, n := range e.Notes {
+		msgs = append(msgs, n.String(e.Name))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// SyntaxErrorList is the error returned by Parse, holding the syntax errors in
+// the order they were found
+type SyntaxErrorList []*SyntaxError
+
+// Error returns the errors one per line
+func (el SyntaxErrorList) Error() string {
+	msgs := make([]string, len(el))
+	for i, e := range el {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// bailout is the panic value used to abandon the statement being parsed once
+// an error is reported
+type bailout struct{}
+
+// report records the error with the code at the span from pos to end without
+// terminating processing, the pending notes are attached to it
+func (p *Parser) report(pos, end token.Pos, code, format string, args ...interface{}) {
+	p.errors = append(p.errors, &SyntaxError{Name: p.Name, Pos: pos, End: end, Code: code, Msg: fmt.Sprintf(format, args...),
+		Line: token.SourceLine(p.input, pos.Line()), Notes: p.notes})
+	p.notes = nil
+}
+
+// note adds a note at the position to the next error reported
+func (p *Parser) note(pos token.Pos, format string, args ...interface{}) {
+	p.notes = append(p.notes, token.Note{Pos: pos, Msg: fmt.Sprintf(format, args...),
+		Line: token.SourceLine(p.input, pos.Line())})
+}
+
+// errorf records the error with the code and abandons the statement being
+// parsed.
+func (p *Parser) errorf(code, format string, args ...interface{}) {
+	p.report(p.currentToken.Pos, p.currentToken.End, code, format, args...)
+	panic(bailout{})
+}
 
 // expect consumes the next token and guarantees it has the required type.
 func (p *Parser) expect(context string, expected token.Type) token.Token {
@@ -68,6 +165,17 @@ func (p *Parser) expect(context string, expected token.Type) token.Token {
 	return tkn
 }
 
+// expectClose consumes the next token and guarantees it is the closing bracket
+// of the left bracket, the error notes where the left bracket is.
+func (p *Parser) expectClose(context string, expected token.Type, left token.Token) token.Token {
+	tkn := p.next()
+	if tkn.Type != expected {
+		p.note(left.Pos, "%s opened here", left.Value)
+		p.unexpected(context, tkn)
+	}
+	return tkn
+}
+
 // expectRange consumes the next token and guarantees it has one of the required types.
 func (p *Parser) expectRange(context string, expectedTypes ...token.Type) (tkn token.Token) {
 	tkn = p.next()
@@ -80,237 +188,593 @@ func (p *Parser) expectRange(context string, expectedTypes ...token.Type) (tkn t
 	return
 }
 
-// unexpected complains about the token and terminates processing
+// unexpected complains about the token and terminates processing, an error
+// token is reported with the error found by the lexer
 func (p *Parser) unexpected(context string, tkn token.Token) {
-	p.errorf("unexpected %s in %s", tkn, context)
+	if tkn.Type == token.ERROR {
+		code := token.UnknownError.Code()
+		if err := p.tokeniser.Err(); err != nil {
+			code = err.Code
+			// the notes of the lexer may repeat the notes of expectClose
+		notes:
+			for _, n := range err.Notes {
+				for _, pn := range p.notes {
+					if pn.Pos == n.Pos {
+						continue notes
+					}
+				}
+				p.note(n.Pos, "%s", n.Msg)
+			}
+		}
+		p.errorf(code, "%s", tkn.Value)
+	}
+	p.errorf(SyntaxUnexpectedToken, "unexpected %s in %s", tkn, context)
+}
+
+// nest enters a nested block or expression, of the kind given, reporting an
+// error if the nesting is too deep
+func (p *Parser) nest(kind string) {
+	p.depth++
+	if p.opts.MaxDepth > 0 && p.depth > p.opts.MaxDepth {
+		p.errorf(SyntaxTooDeep, "%s too deeply nested, exceeds the maximum depth of %d", kind, p.opts.MaxDepth)
+	}
+}
+
+// unnest leaves a nested block or expression
+func (p *Parser) unnest() { p.depth-- }
+
+// sync skips the tokens up to the end of the statement in which an error was
+// found, so that parsing may resume at the next statement
+func (p *Parser) sync() {
+	for tkn := p.currentToken; tkn.Type != token.SEMICOLON; tkn = p.next() {
+		switch p.peek().Type {
+		case token.EOF, token.ERROR:
+			return
+		}
+	}
 }
 
 // recover is the handler that turns panics into returns from the top level
-// of Parse
+// of Parse, a panic other than a bailout is a bug in the parser, it is reported
+// as a syntax error rather than crashing the client
 func (p *Parser) recover(errp *error) {
 	e := recover()
 	if e != nil {
-		if _, ok := e.(runtime.Error); ok {
-			panic(e)
+		if _, ok := e.(bailout); !ok {
+			p.report(p.currentToken.Pos, p.currentToken.End, SyntaxInternalError, "internal parser error: %v", e)
 		}
-		if p != nil {
-			p.tokeniser.Drain()
-			p.stopParse()
-		}
-		*errp = e.(error)
 	}
+	p.tokeniser.Drain()
+	p.stopParse()
+	if len(p.errors) > 0 {
+		p.Root = nil
+		*errp = p.errors
+	}
+}
+
+// ParseOptions holds the optional settings of a parse, the zero value sets no
+// limits and enables none of the settings
+type ParseOptions struct {
+	MaxErrors         int  // number of errors after which parsing stops with a "too many errors" error, no limit if not positive
+	MaxDepth          int  // nesting depth of blocks and expressions after which parsing stops, no limit if not positive
+	KeepComments      bool // collect the comments of the input in File.Comments
+	AllowTopLevelExpr bool // allow expression statements other than calls at the top level, e.g. for the result of a script
+	DisableASI        bool // require explicit semicolons instead of inserting them at the end of lines
+	Strict            bool // report the legacy forms that are otherwise accepted, e.g. octals with a leading zero
 }
 
-// initParser initialises the parser, using the token.Lexer
-func initParser(tokeniser *token.Lexer) *Parser {
+// DefaultParseOptions returns the options used by Parse
+func DefaultParseOptions() ParseOptions {
+	return ParseOptions{MaxErrors: DefaultMaxErrors, MaxDepth: DefaultMaxDepth,
+		KeepComments: true, AllowTopLevelExpr: true}
+}
+
+// mode returns the mode of the token.Lexer for the options
+func (o ParseOptions) mode() token.Mode {
+	var mode token.Mode
+	if o.KeepComments {
+		mode |= token.ScanComments
+	}
+	if o.DisableASI {
+		mode |= token.NoSemicolons
+	}
+	if o.Strict {
+		mode |= token.NoLegacyOctals
+	}
+	return mode
+}
+
+// initParser initialises the parser for the input, using the token.Lexer
+func initParser(name, input string, opts ParseOptions) *Parser {
+	tokeniser := token.TokeniseMode(name, input, opts.mode())
 	p := &Parser{Name: tokeniser.Name, Root: nil, tokeniser: tokeniser,
-		input: tokeniser.Input}
+		input: tokeniser.Input, opts: opts}
 	return p
 }
 
 func (p *Parser) stopParse() { p.tokeniser = nil }
 
-// Parse parses the input string to construct an AST
+// Parse parses the input string to construct an AST with the
+// DefaultParseOptions
 func Parse(name, input string) (parser *Parser, err error) {
-	p := initParser(token.Tokenise(name, input))
+	return ParseWithOptions(name, input, DefaultParseOptions())
+}
+
+// ParseWithOptions parses the input string to construct an AST with the
+// options given. It never panics, the error returned is always a
+// SyntaxErrorList
+func ParseWithOptions(name, input string, opts ParseOptions) (parser *Parser, err error) {
+	p := initParser(name, input, opts)
 	defer p.recover(&err)
 	p.parse()
-	p.stopParse()
 	return p, nil
 }
 
-func (p *Parser) parse() {
-	p.Root = p.orEval()
+// ParseFile parses the statements of the input into a File with the
+// DefaultParseOptions
+func ParseFile(name, input string) (*File, error) {
+	p, err := Parse(name, input)
+	if err != nil {
+		return nil, err
+	}
+	return p.Root.(*File), nil
+}
+
+// ParseExpr parses the input as a single expression, the name "<expr>" is used
+// for error reporting
+func ParseExpr(input string) (Expr, error) {
+	p := initParser(exprName, input, DefaultParseOptions())
+	err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return p.Root.(Expr), nil
+}
+
+// exprName is the name of the input of ParseExpr
+const exprName = "<expr>"
+
+// parseExpr parses a single expression at the root, optionally ended by a
+// semicolon
+// exprInput: expression [";"] EOF;
+func (p *Parser) parseExpr() (err error) {
+	defer p.recover(&err)
+	expr := p.expression()
 	if p.peek().Type == token.SEMICOLON {
-		p.next() // just consume the semicolon for now
+		p.next()
+	}
+	p.expect("expression, expected the end of the input", token.EOF)
+	p.Root = expr
+	return nil
+}
+
+// parse parses the statements of the input into a File at the root, recovering
+// from the errors at the end of each statement so that every statement is
+// checked
+// file: stmt* EOF;
+func (p *Parser) parse() {
+	var stmts []Stmt
+	// an error at the end of the input may have consumed the EOF token
+	for p.currentToken.Type != token.EOF && p.peek().Type != token.EOF {
+		n := p.stmtRecover()
+		if n == nil {
+			continue
+		}
+		if s, ok := n.(*ExprStmt); ok && !p.opts.AllowTopLevelExpr {
+			if _, ok := s.exprs[0].(*CallExpr); !ok {
+				p.report(s.Pos(), s.End(), SyntaxUnusedExpr, "expression evaluated but not used")
+			}
+		}
+		stmts = append(stmts, n)
 	}
-	p.expect("End of File", token.EOF)
+	p.Root = &File{Name: p.Name, Comments: p.comments, Symbols: fileSymbols(stmts), stmts: stmts}
+}
+
+// stmtRecover parses a statement, returning nil if an error was found in it or
+// if it is an empty statement
+func (p *Parser) stmtRecover() (n Stmt) {
+	depth, loopDepth, funcDepth := p.depth, p.loopDepth, p.funcDepth
+	defer func() {
+		if e := recover(); e != nil {
+			// the lexer stops at the first error, there is nothing to resume
+			if _, ok := e.(bailout); !ok || p.currentToken.Type == token.ERROR {
+				panic(e)
+			}
+			if p.opts.MaxErrors > 0 && len(p.errors) >= p.opts.MaxErrors {
+				last := p.errors[len(p.errors)-1]
+				p.report(last.Pos, last.End, SyntaxTooManyErrors,
+					"too many errors, stopped after %d", p.opts.MaxErrors)
+				panic(e)
+			}
+			p.depth, p.loopDepth, p.funcDepth = depth, loopDepth, funcDepth
+			p.sync()
+			n = nil
+		}
+	}()
+	return p.stmt()
 }
 
 // Grammar rules
 
-// func (p *Parser) input() Node {
-// 	for p.peek().Type != tokenEOF {
-
-// 	}
-// }
-
-// func (p *Parser) stmt() Node {
-// 	for
-// }
-
-// // exprStmt: exprList (augAssign exprList | ('=' exprList)*);
-// // augAssign: "+=" | "-=" | "/=" | "*=" | "%=";
-// func (p *Parser) exprStmt() Stmt {
-// 	// get the first token, this used to give the line position and position
-// 	// of the start of the statement
-// 	firstTkn := p.peek()
-// 	exprs := p.exprList()
-// 	switch tkntyp := p.peek().Type; tkntyp {
-// 	case tokenPlusAssign, tokenMinusAssign, tokenDivAssign, tokenMultAssign,
-// 		tokenModAssign, tokenAssign:
-// 		return p.assignStmt(exprs, tkntyp)
-// 	default:
-// 		// // TODO: Do not accept if its not an assignment statement and it has more
-// 		// // than 1 expression to evaluate
-// 		// if len(exprs) > 1 {
-// 		// 	err := fmt.Errorf("cannot have more than 1 expression")
-// 		// }
-// 		return newExprStmt(exprs, firstTkn)
-// 	}
-// }
-
-// func (p *Parser) assignStmt(lhs []Expr, typ token.Type) Stmt {
-// 	// Possible errors:
-// 	// mismatched LHS/RHS number of values
-// 	// LHS is not addressable (i.e. not a NAME/SLICE)
-// 	for _, lhExpr := range lhs {
-// 		switch expr := lhExpr.(type) {
-// 		case ID:
-// 		default:
-// 			// Error, not an addressable
-// 			p.errorf()
-// 		}
-// 	}
-
-// 	rhs := p.exprList()
-
-// }
-
-// orEval: andEval ("||" orEval)*;
-func (p *Parser) orEval() Expr {
-	node := p.andEval()
-	for p.peek().Type == token.LOGICALOR {
-		tkn := p.next()
-		node = newBinExpr(node, p.andEval(), tkn)
-	}
-	return node
-}
-
-// andEval: notEval ("&&" notEval)*;
-func (p *Parser) andEval() Expr {
-	node := p.notEval()
-	for p.peek().Type == token.LOGICALAND {
-		tkn := p.next()
-		node = newBinExpr(node, p.andEval(), tkn)
-	}
-	return node
-}
-
-// notEval: "!" notEval | comparison;
-func (p *Parser) notEval() Expr {
-	switch p.peek().Type {
-	case token.LOGICALNOT:
-		tkn := p.next()
-		return newUnExpr(p.notEval(), tkn)
+// stmt: (compoundStmt | simpleStmt) (";" | EOF) | ";";
+// compoundStmt: funcDecl | ifStmt | whileStmt | forStmt | block;
+// simpleStmt: varDecl | returnStmt | "break" | "continue" | exprStmt;
+func (p *Parser) stmt() Stmt {
+	var n Stmt
+	switch tkn := p.peek(); tkn.Type {
+	case token.SEMICOLON: // empty statement
+		p.next()
+		return nil
+	case token.FUNC:
+		n = p.funcDecl()
+	case token.IF:
+		n = p.ifStmt()
+	case token.WHILE:
+		n = p.whileStmt()
+	case token.FOR:
+		n = p.forStmt()
+	case token.LCURLY:
+		n = p.block()
+	case token.VAR:
+		n = p.varDecl()
+	case token.RETURN:
+		n = p.returnStmt()
+	case token.YIELD:
+		n = p.yieldStmt()
+	case token.BREAK, token.CONT:
+		p.next()
+		if p.loopDepth == 0 {
+			p.errorf(SyntaxBranchOutsideLoop, "%s is not in a loop", tkn.Value)
+		}
+		n = newBranchStmt(tkn)
 	default:
-		return p.comparison()
+		n = p.exprStmt()
+	}
+	if p.peek().Type != token.EOF {
+		p.expect("end of statement"+keywordHint(n), token.SEMICOLON)
 	}
+	return n
 }
 
-// comparison: smExpr (compOp smExpr)*;
-// compOp: compOp: "==" | "!=" | "<" | ">" | "<=" | ">=" | ["!"] "in";
-func (p *Parser) comparison() Expr {
-	node := p.smExpr()
-Loop:
-	for {
-		switch p.peek().Type {
-		case token.EQ, token.NEQ,
-			token.SM, token.SMEQ,
-			token.GR, token.GREQ, token.IN:
-			tkn := p.next()
-			node = newBinExpr(node, p.smExpr(), tkn)
-		default:
-			break Loop
+// stmtKeywords are the keywords that start a statement or a clause of one
+var stmtKeywords = []string{"func", "if", "elif", "else", "while", "for", "var", "return", "break", "continue"}
+
+// keywordHint suggests the keyword that a statement made of a single name is
+// likely a misspelling of, e.g. "whille x {" is parsed as the name "whille"
+// followed by an unexpected "x"
+func keywordHint(n Stmt) string {
+	s, ok := n.(*ExprStmt)
+	if !ok || len(s.exprs) != 1 {
+		return ""
+	}
+	id, ok := s.exprs[0].(*Ident)
+	if !ok {
+		return ""
+	}
+	if kw := suggest(id.Name, stmtKeywords); kw != "" {
+		return fmt.Sprintf(", did you mean %q?", kw)
+	}
+	return ""
+}
+
+// block: "{" stmt* "}";
+func (p *Parser) block() *BlockStmt {
+	leftCurly := p.expect("block, expected '{'", token.LCURLY)
+	p.nest("block")
+	defer p.unnest()
+	var stmts []Stmt
+	for t := p.peek().Type; t != token.RCURLY && t != token.EOF; t = p.peek().Type {
+		if n := p.stmtRecover(); n != nil {
+			stmts = append(stmts, n)
 		}
 	}
-	return node
+	rightCurly := p.expectClose("closing curly brackets, expected '}'", token.RCURLY, leftCurly)
+	return newBlockStmt(stmts, leftCurly, rightCurly)
 }
 
-// smExpr: term (("+" | "-") term)*;
-func (p *Parser) smExpr() Expr {
-	node := p.term()
-Loop:
-	for {
-		switch p.peek().Type {
-		case token.PLUS, token.MINUS:
-			tkn := p.next()
-			node = newBinExpr(node, p.term(), tkn)
-		default:
-			break Loop
+// funcDecl: "func" NAME funcBody;
+func (p *Parser) funcDecl() Stmt {
+	funcTkn := p.next()
+	name := newID(p.expect("function declaration, expected a name", token.NAME))
+	return newFuncDecl(name, p.funcBody(funcTkn))
+}
+
+// funcBody: "(" [NAME ("," NAME)* [","]] ")" block;
+func (p *Parser) funcBody(funcTkn token.Token) *FuncLit {
+	leftRound := p.expect("function parameters, expected '('", token.LROUND)
+	var params []*Ident
+	seen := map[string]bool{}
+	for p.peek().Type != token.RROUND {
+		param := newID(p.expect("function parameters, expected a name", token.NAME))
+		if seen[param.Name] {
+			p.report(param.Pos(), param.End(), SyntaxDuplicateParam, "duplicate parameter %s", param.Name)
+		}
+		seen[param.Name] = true
+		params = append(params, param)
+		if p.peek().Type != token.COMMA {
+			break
 		}
+		p.next() // consume the comma token, it may be a trailing comma
 	}
-	return node
+	p.expectClose("function parameters, expected ')'", token.RROUND, leftRound)
+	// loops do not extend into the function body
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	p.funcDepth++
+	body := p.block()
+	p.funcDepth--
+	p.loopDepth = loopDepth
+	return newFuncLit(params, body, funcTkn)
 }
 
-// term: factor (("*" | "/" | "%") factor)*;
-func (p *Parser) term() Expr {
-	node := p.factor()
-Loop:
-	for {
-		switch p.peek().Type {
-		case token.MULT, token.DIV, token.MOD:
-			tkn := p.next()
-			node = newBinExpr(node, p.factor(), tkn)
-		default:
-			break Loop
+// ifStmt: "if" expression block ("elif" expression block)* ["else" block];
+func (p *Parser) ifStmt() Stmt {
+	ifTkn := p.next() // "if" or "elif"
+	cond := p.expression()
+	body := p.block()
+	var elseStmt Stmt
+	switch p.peek().Type {
+	case token.ELIF:
+		elseStmt = p.ifStmt()
+	case token.ELSE:
+		p.next()
+		elseStmt = p.block()
+	}
+	return newIfStmt(cond, body, elseStmt, ifTkn)
+}
+
+// whileStmt: "while" expression block;
+func (p *Parser) whileStmt() Stmt {
+	whileTkn := p.next()
+	cond := p.expression()
+	return newWhileStmt(cond, p.loopBody(), whileTkn)
+}
+
+// forStmt: "for" NAME ["," NAME] "in" expression block;
+func (p *Parser) forStmt() Stmt {
+	forTkn := p.next()
+	key := newID(p.expect("for loop, expected a name", token.NAME))
+	var value *Ident
+	if p.peek().Type == token.COMMA {
+		p.next() // consume the comma token
+		value = newID(p.expect("for loop, expected a name", token.NAME))
+	}
+	p.expect("for loop, expected 'in'", token.IN)
+	iter := p.expression()
+	return newForStmt(key, value, iter, p.loopBody(), forTkn)
+}
+
+// loopBody parses the block of a loop, in which break and continue are allowed
+func (p *Parser) loopBody() *BlockStmt {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.block()
+}
+
+// varDecl: "var" NAME ("," NAME)* ["=" exprList];
+func (p *Parser) varDecl() Stmt {
+	varTkn := p.next()
+	names := []*Ident{newID(p.expect("variable declaration, expected a name", token.NAME))}
+	for p.peek().Type == token.COMMA {
+		p.next() // consume the comma token
+		names = append(names, newID(p.expect("variable declaration, expected a name", token.NAME)))
+	}
+	var values []Expr
+	if p.peek().Type == token.ASSIGN {
+		p.next()
+		values = p.exprList()
+		if len(values) != len(names) {
+			p.errorf(SyntaxAssignMismatch, "assignment mismatch: %d variables but %d values", len(names), len(values))
 		}
 	}
-	return node
+	return newVarDecl(names, values, varTkn)
 }
 
-// factor: ("+" | "-") factor | atom;
-func (p *Parser) factor() Expr {
+// returnStmt: "return" [exprList];
+func (p *Parser) returnStmt() Stmt {
+	returnTkn := p.next()
+	if p.funcDepth == 0 {
+		p.errorf(SyntaxReturnOutsideFunc, "return is not in a function")
+	}
+	var result Expr
 	switch p.peek().Type {
-	case token.PLUS, token.MINUS:
-		tkn := p.next()
-		return newUnExpr(p.factor(), tkn)
+	case token.SEMICOLON, token.EOF:
 	default:
-		return p.atom()
+		results := p.exprList()
+		result = results[0]
+		if len(results) > 1 {
+			// multiple results are returned as a tuple
+			result = newTuple(results, token.Token{}, token.Token{})
+		}
+	}
+	return newReturnStmt(result, returnTkn)
+}
+
+// yieldStmt: "yield" expression;
+func (p *Parser) yieldStmt() Stmt {
+	yieldTkn := p.next()
+	if p.funcDepth == 0 {
+		p.errorf(SyntaxYieldOutsideFunc, "yield is not in a function")
+	}
+	return newYieldStmt(p.expression(), yieldTkn)
+}
+
+// exprStmt: exprList [(augAssign | "=") exprList];
+// augAssign: "+=" | "-=" | "/=" | "*=" | "%=";
+func (p *Parser) exprStmt() Stmt {
+	left := p.exprList()
+	switch op := p.peek(); op.Type {
+	case token.PLUSASSIGN, token.MINUSASSIGN, token.DIVASSIGN, token.MULTASSIGN,
+		token.MODASSIGN, token.ASSIGN:
+		p.next()
+		right := p.exprList()
+		for _, target := range left {
+			switch target.(type) {
+			case *Ident, *IndexExpr:
+			default:
+				p.errorf(SyntaxInvalidTarget, "cannot assign to %s", new(AstPrinter).Print(target))
+			}
+		}
+		if op.Type != token.ASSIGN && (len(left) > 1 || len(right) > 1) {
+			p.errorf(SyntaxMultipleAugAssign, "%s does not support multiple values", op.Value)
+		}
+		if len(left) != len(right) && !(op.Type == token.ASSIGN && len(right) == 1) {
+			p.errorf(SyntaxAssignMismatch, "assignment mismatch: %d variables but %d values", len(left), len(right))
+		}
+		return newAssignStmt(left, right, op.Type)
+	}
+	if len(left) > 1 {
+		p.errorf(SyntaxMultipleExprs, "expected 1 expression, found %d", len(left))
 	}
+	return newExprStmt(left)
+}
+
+// Operator precedences, from the loosest to the tightest binding
+const (
+	lowestPrec  = iota
+	orPrec      // ||
+	andPrec     // &&
+	notPrec     // prefix !
+	comparePrec // == != < <= > >= in
+	sumPrec     // + -
+	productPrec // * / %
+	unaryPrec   // prefix + -
+)
+
+// binaryPrecs holds the precedences of the binary operators, which are all
+// left associative
+var binaryPrecs = map[token.Type]int{
+	token.LOGICALOR:  orPrec,
+	token.LOGICALAND: andPrec,
+	token.EQ:         comparePrec,
+	token.NEQ:        comparePrec,
+	token.SM:         comparePrec,
+	token.SMEQ:       comparePrec,
+	token.GR:         comparePrec,
+	token.GREQ:       comparePrec,
+	token.IN:         comparePrec,
+	token.PLUS:       sumPrec,
+	token.MINUS:      sumPrec,
+	token.MULT:       productPrec,
+	token.DIV:        productPrec,
+	token.MOD:        productPrec,
+}
+
+// unaryPrecs holds the precedences of the prefix operators, the operand of a
+// prefix operator holds the binary operators that bind tighter than it, e.g.
+// "!a == b" is "!(a == b)" while "-a * b" is "(-a) * b"
+var unaryPrecs = map[token.Type]int{
+	token.LOGICALNOT: notPrec,
+	token.PLUS:       unaryPrec,
+	token.MINUS:      unaryPrec,
+}
+
+// expression: unary (binOp unary)*;
+// binOp: "||" | "&&" | "==" | "!=" | "<" | ">" | "<=" | ">=" | "in" | "+" | "-" | "*" | "/" | "%";
+func (p *Parser) expression() Expr { return p.binary(lowestPrec) }
+
+// binary parses an expression whose binary operators bind tighter than prec
+func (p *Parser) binary(prec int) Expr {
+	p.nest("expression")
+	defer p.unnest()
+	n := p.unary()
+	for {
+		tkn := p.peek()
+		opPrec, ok := binaryPrecs[tkn.Type]
+		if !ok || opPrec <= prec {
+			return n
+		}
+		p.next()
+		n = newBinExpr(n, p.binary(opPrec), tkn)
+	}
+}
+
+// unary: ("!" | "+" | "-") unary | atomExpr;
+func (p *Parser) unary() Expr {
+	tkn := p.peek()
+	prec, ok := unaryPrecs[tkn.Type]
+	if !ok {
+		return p.atomExpr()
+	}
+	p.next()
+	return newUnExpr(p.binary(prec), tkn)
 }
 
-// TODO: Implement me!
 // atomExpr: atom trailer*;
-// trailer: "(" [argList] ")" | "[" slice "]" | "." NAME;
-// slice: orEval | [orEval] ":" [orEval] [":" [orEval]];
-// argList: arg ("," arg)* [","];
-// arg: orEval | NAME "=" orEval;
+// trailer: "(" [argList] ")" | "[" subscript "]";
+// subscript: expression | [expression] ":" [expression];
+// argList: expression ("," expression)* [","];
+// TODO: "." NAME trailers once there are values with attributes
 func (p *Parser) atomExpr() Expr {
 	n := p.atom()
-TrailerLoop:
 	for {
 		switch p.peek().Type {
-		case token.DOT:
 		case token.LROUND:
+			n = p.call(n)
 		case token.LSQUARE:
-
+			n = p.subscript(n)
 		default:
-			break TrailerLoop
+			return n
 		}
 	}
-	return n
 }
 
-// atom: identifier | literal | enclosure;
+// call parses the arguments of a call to fn
+func (p *Parser) call(fn Expr) Expr {
+	leftRound := p.next()
+	var args []Expr
+	for p.peek().Type != token.RROUND {
+		args = append(args, p.expression())
+		if p.peek().Type != token.COMMA {
+			break
+		}
+		p.next() // consume the comma token, it may be a trailing comma
+	}
+	rightRound := p.expectClose("call arguments, expected ')'", token.RROUND, leftRound)
+	return newCallExpr(fn, args, leftRound, rightRound)
+}
+
+// subscript parses the index or the slice bounds of x
+func (p *Parser) subscript(x Expr) Expr {
+	leftSquare := p.next()
+	var lo, hi Expr
+	if p.peek().Type != token.COLON {
+		lo = p.expression()
+		if p.peek().Type != token.COLON {
+			rightSquare := p.expectClose("index, expected ']'", token.RSQUARE, leftSquare)
+			return newIndexExpr(x, lo, leftSquare, rightSquare)
+		}
+	}
+	p.next() // consume the colon token
+	if p.peek().Type != token.RSQUARE {
+		hi = p.expression()
+	}
+	rightSquare := p.expectClose("slice, expected ']'", token.RSQUARE, leftSquare)
+	return newSliceExpr(x, lo, hi, leftSquare, rightSquare)
+}
+
+// atom: identifier | literal | enclosure | funcLit;
+// funcLit: "func" funcBody;
 func (p *Parser) atom() Expr {
 	switch p.peek().Type {
 	case token.NAME: // identifier
 		return newID(p.next())
-	case token.STR, token.INT, token.FLOAT, token.FALSE, token.TRUE, token.NULL:
+	case token.STR, token.CHAR, token.INT, token.FLOAT, token.FALSE, token.TRUE, token.NULL:
 		return p.literal()
 	case token.LROUND, token.LSQUARE, token.LCURLY:
 		return p.enclosure()
+	case token.FUNC:
+		return p.funcBody(p.next())
 	default:
 		p.unexpected("atom", p.next())
 		return nil
 	}
 }
 
-// literal: string | integer | float | "true" | "false" | "null";
+// literal: string | char | integer | float | "true" | "false" | "null";
 func (p *Parser) literal() Expr {
 	switch p.peek().Type {
-	case token.STR, token.INT, token.FLOAT, token.FALSE, token.TRUE, token.NULL:
+	case token.STR, token.CHAR, token.INT, token.FLOAT, token.FALSE, token.TRUE, token.NULL:
 		n := newBasicLit(p.next())
 		return n
 	}
@@ -318,39 +782,112 @@ func (p *Parser) literal() Expr {
 	return nil
 }
 
-// enclosure: parenthesis_form | arr_display | map_display;
+// enclosure: parenthesis_form | tuple_display | arr_display | map_display;
 // parenthesis_form: "(" expression ")";
+// tuple_display: "(" [expression "," [expression_list]] ")";
 // arr_display: "[" [expression_list] "]";
-// map_display: "{" key_datum_list "}";
+// map_display: "{" [key_datum_list] [";"] "}";
 // key_datum_list: key_datum ("," key_datum)* [","];
 // key_datum: expression ":" expression;
+// A semicolon is inserted by the lexer before the closing curly bracket of a
+// map display, it is skipped.
 func (p *Parser) enclosure() Expr {
 	switch p.peek().Type {
-	case token.LROUND: // parenthesis_form
-		p.next() // consume left bracket
-		n := p.orEval()
-		p.expect("closing brackets, expected ')'", token.RROUND)
-		return n
+	case token.LROUND: // parenthesis_form or tuple_display
+		leftRound := p.next()
+		if p.peek().Type == token.RROUND {
+			return newTuple(nil, leftRound, p.next())
+		}
+		x := p.expression()
+		if p.peek().Type != token.COMMA {
+			rightRound := p.expectClose("closing brackets, expected ')'", token.RROUND, leftRound)
+			return newGrpExpr(x, leftRound, rightRound)
+		}
+		// a comma makes a tuple of the expression, it may be a trailing comma
+		elements := []Expr{x}
+		for p.peek().Type == token.COMMA {
+			p.next()
+			if p.peek().Type == token.RROUND {
+				break
+			}
+			elements = append(elements, p.expression())
+		}
+		rightRound := p.expectClose("tuple, expected ')'", token.RROUND, leftRound)
+		return newTuple(elements, leftRound, rightRound)
 	case token.LSQUARE: // arr_display
 		leftSquare := p.next()
-		elements := p.exprList()
-		rightSquare := p.expect("closing square brackets, expected ']'", token.RSQUARE)
+		var elements []Expr
+		if p.peek().Type != token.RSQUARE {
+			x := p.expression()
+			if p.peek().Type == token.FOR {
+				forKey, forValue, iter, cond := p.compFor()
+				rightSquare := p.expectClose("list comprehension, expected ']'", token.RSQUARE, leftSquare)
+				return newComprehension(nil, x, forKey, forValue, iter, cond, leftSquare, rightSquare)
+			}
+			elements = p.exprListFrom(x)
+		}
+		rightSquare := p.expectClose("closing square brackets, expected ']'", token.RSQUARE, leftSquare)
 		return newList(elements, leftSquare, rightSquare)
-		// case token.LCURLY:
-
+	case token.LCURLY: // map_display
+		leftCurly := p.next()
+		var keys, values []Expr
+		for t := p.peek().Type; t != token.RCURLY && t != token.SEMICOLON; t = p.peek().Type {
+			keys = append(keys, p.expression())
+			p.expect("map display, expected ':'", token.COLON)
+			values = append(values, p.expression())
+			if len(keys) == 1 && p.peek().Type == token.FOR {
+				forKey, forValue, iter, cond := p.compFor()
+				if p.peek().Type == token.SEMICOLON {
+					p.next()
+				}
+				rightCurly := p.expectClose("map comprehension, expected '}'", token.RCURLY, leftCurly)
+				return newComprehension(keys[0], values[0], forKey, forValue, iter, cond, leftCurly, rightCurly)
+			}
+			if p.peek().Type != token.COMMA {
+				break
+			}
+			p.next() // consume the comma token
+		}
+		if p.peek().Type == token.SEMICOLON {
+			p.next()
+		}
+		rightCurly := p.expectClose("closing curly brackets, expected '}'", token.RCURLY, leftCurly)
+		return newMap(keys, values, leftCurly, rightCurly)
 	}
 	p.unexpected("enclosure", p.next())
 	return nil
 }
 
-// exprList: orEval ("," orEval)* [","];
-func (p *Parser) exprList() []Expr {
-	elements := []Expr{p.orEval()}
+// compFor: "for" NAME ["," NAME] "in" expression ["if" expression];
+// the for clause of a list or map comprehension
+func (p *Parser) compFor() (forKey, forValue *Ident, iter, cond Expr) {
+	p.next() // consume the for token
+	forKey = newID(p.expect("comprehension, expected a name", token.NAME))
+	if p.peek().Type == token.COMMA {
+		p.next() // consume the comma token
+		forValue = newID(p.expect("comprehension, expected a name", token.NAME))
+	}
+	p.expect("comprehension, expected 'in'", token.IN)
+	iter = p.expression()
+	if p.peek().Type == token.IF {
+		p.next() // consume the if token
+		cond = p.expression()
+	}
+	return forKey, forValue, iter, cond
+}
+
+// exprList: expression ("," expression)* [","];
+func (p *Parser) exprList() []Expr { return p.exprListFrom(p.expression()) }
+
+// exprListFrom parses the rest of an expression list of which the first
+// expression was parsed already
+func (p *Parser) exprListFrom(first Expr) []Expr {
+	elements := []Expr{first}
 	for p.peek().Type == token.COMMA {
 		p.next() // consume the comma token
 		// if the following token isn't ']' handles dangling commas as well
 		if p.peek().Type != token.RSQUARE {
-			elements = append(elements, p.orEval())
+			elements = append(elements, p.expression())
 		}
 	}
 	return elements
@@ -0,0 +1,195 @@
+package lang
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// goldenParse returns the output of parsing the input that is compared to a
+// golden file, the AST in s-expression, source and JSON form, or the syntax
+// errors
+func goldenParse(name, input string) ([]byte, error) {
+	var out bytes.Buffer
+	f, err := ParseFile(name, input)
+	if err != nil {
+		out.WriteString("-- errors --\n" + err.Error() + "\n")
+		return out.Bytes(), nil
+	}
+	out.WriteString("-- ast --\n" + new(AstPrinter).Print(f) + "\n")
+	out.WriteString("-- source --\n" + new(SourcePrinter).Print(f) + "\n")
+	b, err := MarshalJSONIndent(f, "  ")
+	if err != nil {
+		return nil, err
+	}
+	out.WriteString("-- json --\n" + string(b) + "\n")
+	return out.Bytes(), nil
+}
+
+// TestParseGolden parses each script of testdata/parse and compares the output
+// of goldenParse to the golden file next to the script, run the tests with
+// -update to rewrite the golden files
+func TestParseGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "parse", "*.went"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no scripts found in testdata/parse")
+	}
+	for _, path := range paths {
+		input, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := goldenParse(filepath.Base(path), string(input))
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		golden := strings.TrimSuffix(path, ".went") + ".golden"
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s, run the tests with -update to create it", err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("%s: output differs from %s, run the tests with -update if the change is expected\ngot:\n%s\nexpected:\n%s",
+				path, golden, got, expected)
+		}
+	}
+}
+
+// TestJSONRoundTrip checks that the JSON form of the AST of each script of
+// testdata decodes back to the same AST, positions included
+func TestJSONRoundTrip(t *testing.T) {
+	var paths []string
+	for _, dir := range []string{"parse", "spec"} {
+		matches, err := filepath.Glob(filepath.Join("testdata", dir, "*.went"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, matches...)
+	}
+	for _, path := range paths {
+		input, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := ParseFile(path, string(input))
+		if err != nil {
+			continue // the scripts of syntax errors
+		}
+		b, err := MarshalJSON(f)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		g, err := UnmarshalJSON(b)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		if !Equal(f, g, false) {
+			ap := new(AstPrinter)
+			t.Errorf("%s: JSON decodes to a different AST\ngot:\n%s\nexpected:\n%s", path, ap.Print(g), ap.Print(f))
+		}
+		if gf, ok := g.(*File); !ok || gf.Name != f.Name || len(gf.Comments) != len(f.Comments) {
+			t.Errorf("%s: JSON decodes to a different file, got %T", path, g)
+		}
+	}
+	for _, data := range []string{`{"type": "Nope"}`, `{"type": "File", "stmts": [1]}`, `[`} {
+		if _, err := UnmarshalJSON([]byte(data)); err == nil {
+			t.Errorf("%s: got no error, expected an invalid AST JSON error", data)
+		}
+	}
+}
+
+// benchScript is a script of many lines exercising the common statements and
+// expressions
+var benchScript = strings.Repeat(`// compute the totals
+var total, names = 0, []
+func add(x, y) {
+	return x + y * 2 % 3
+}
+for i, x in [1, 2.5, 0x1F, 1_000] {
+	if x >= 2 && total != 10 {
+		total += add(x, i)
+	} elif !(x in {'a': 1, 'b': 2}) {
+		continue
+	} else {
+		names[0] = names[1:i] + ['item ' + `+"`raw`"+`]
+	}
+}
+while total > 0 {
+	total -= 1
+}
+`, 200)
+
+// FuzzParse checks that Parse never fails with an internal error, and that the
+// AST of the inputs that parse upholds the invariants checked by Check and
+// prints back to source that parses to the same AST
+func FuzzParse(f *testing.F) {
+	paths, _ := filepath.Glob(filepath.Join("testdata", "parse", "*.went"))
+	for _, path := range paths {
+		if input, err := ioutil.ReadFile(path); err == nil {
+			f.Add(string(input))
+		}
+	}
+	for _, input := range []string{
+		"", "x", "f(", "[1, 2", "{'a': }", "x = = 1", "a[1:][:2]", "if x { } elif y { } else { }",
+		"func f(a) { return }", "while true { break }", "for k, v in m { continue }", "x +=", "-(-x)",
+	} {
+		f.Add(input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		p, err := Parse("fuzz", input)
+		if errs, ok := err.(SyntaxErrorList); ok {
+			for _, e := range errs {
+				if e.Code == SyntaxInternalError {
+					t.Fatalf("%q: %s", input, e)
+				}
+			}
+			return
+		} else if err != nil {
+			t.Fatalf("%q: got an error of type %T, expected lang.SyntaxErrorList", input, err)
+		}
+		if err := Check(p.Root); err != nil {
+			t.Fatalf("%q: invalid AST:\n%s", input, err)
+		}
+		checkRoundTrip(t, "fuzz", input)
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.SetBytes(int64(len(benchScript)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse("bench", benchScript); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseExpr(b *testing.B) {
+	// a list literal over many lines, as the columns of a long line are slow
+	// to compute
+	input := "[\n" + strings.Repeat("f(a[1:2], -b) * (c + d) == {'k': [e, 1.5]}['k'] || x,\n", 200) + "y]"
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseExpr(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
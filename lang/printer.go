@@ -0,0 +1,258 @@
+package lang
+
+import (
+	"bytes"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+// AstPrinter implements NodeWalker, it prints the AST in a lisp-like
+// s-expression form, mainly used for debugging the parser
+type AstPrinter struct {
+	buffer bytes.Buffer
+}
+
+// Print returns the s-expression form of the AST rooted at node
+func (ap *AstPrinter) Print(node Node) string {
+	ap.buffer.Reset()
+	if node != nil {
+		node.accept(ap)
+	}
+	return ap.buffer.String()
+}
+
+// parenthesise writes the name and the nodes enclosed in parentheses
+func (ap *AstPrinter) parenthesise(name string, nodes ...Node) {
+	ap.buffer.WriteString("(")
+	ap.buffer.WriteString(name)
+	for _, n := range nodes {
+		ap.buffer.WriteString(" ")
+		n.accept(ap)
+	}
+	ap.buffer.WriteString(")")
+}
+
+// exprs converts a list of expressions to a list of nodes
+func exprs(es []Expr) []Node {
+	nodes := make([]Node, len(es))
+	for i, e := range es {
+		nodes[i] = e
+	}
+	return nodes
+}
+
+// stmts converts a list of statements to a list of nodes
+func stmts(ss []Stmt) []Node {
+	nodes := make([]Node, len(ss))
+	for i, s := range ss {
+		nodes[i] = s
+	}
+	return nodes
+}
+
+// idents converts a list of identifiers to a list of nodes
+func idents(ids []*Ident) []Node {
+	nodes := make([]Node, len(ids))
+	for i, id := range ids {
+		nodes[i] = id
+	}
+	return nodes
+}
+
+// assign writes an assignment statement with the given operator
+func (ap *AstPrinter) assign(op string, left, right []Expr) {
+	ap.buffer.WriteString("(")
+	ap.buffer.WriteString(op)
+	ap.buffer.WriteString(" ")
+	ap.parenthesise("targets", exprs(left)...)
+	ap.buffer.WriteString(" ")
+	ap.parenthesise("values", exprs(right)...)
+	ap.buffer.WriteString(")")
+}
+
+func (ap *AstPrinter) visitFile(n *File) WType {
+	ap.parenthesise("file", stmts(n.stmts)...)
+	return nil
+}
+
+func (ap *AstPrinter) visitExprStmt(n *ExprStmt) WType {
+	ap.parenthesise("expr", exprs(n.exprs)...)
+	return nil
+}
+func (ap *AstPrinter) visitAssignStmt(n *AssignStmt) WType {
+	ap.assign("=", n.left, n.right)
+	return nil
+}
+func (ap *AstPrinter) visitPlusAssignStmt(n *PlusAssignStmt) WType {
+	ap.assign("+=", n.left, n.right)
+	return nil
+}
+func (ap *AstPrinter) visitMinusAssignStmt(n *MinusAssignStmt) WType {
+	ap.assign("-=", n.left, n.right)
+	return nil
+}
+func (ap *AstPrinter) visitDivAssignStmt(n *DivAssignStmt) WType {
+	ap.assign("/=", n.left, n.right)
+	return nil
+}
+func (ap *AstPrinter) visitMultAssignStmt(n *MultAssignStmt) WType {
+	ap.assign("*=", n.left, n.right)
+	return nil
+}
+func (ap *AstPrinter) visitModAssignStmt(n *ModAssignStmt) WType {
+	ap.assign("%=", n.left, n.right)
+	return nil
+}
+func (ap *AstPrinter) visitVarDecl(n *VarDecl) WType {
+	ap.buffer.WriteString("(var ")
+	ap.parenthesise("names", idents(n.names)...)
+	ap.buffer.WriteString(" ")
+	ap.parenthesise("values", exprs(n.values)...)
+	ap.buffer.WriteString(")")
+	return nil
+}
+func (ap *AstPrinter) visitFuncDecl(n *FuncDecl) WType {
+	ap.parenthesise("funcdecl", n.name, n.fn)
+	return nil
+}
+func (ap *AstPrinter) visitBlockStmt(n *BlockStmt) WType {
+	ap.parenthesise("block", stmts(n.stmts)...)
+	return nil
+}
+func (ap *AstPrinter) visitIfStmt(n *IfStmt) WType {
+	if n.elseStmt != nil {
+		ap.parenthesise("if", n.cond, n.body, n.elseStmt)
+	} else {
+		ap.parenthesise("if", n.cond, n.body)
+	}
+	return nil
+}
+func (ap *AstPrinter) visitWhileStmt(n *WhileStmt) WType {
+	ap.parenthesise("while", n.cond, n.body)
+	return nil
+}
+func (ap *AstPrinter) visitForStmt(n *ForStmt) WType {
+	if n.value != nil {
+		ap.parenthesise("for", n.key, n.value, n.iter, n.body)
+	} else {
+		ap.parenthesise("for", n.key, n.iter, n.body)
+	}
+	return nil
+}
+func (ap *AstPrinter) visitBranchStmt(n *BranchStmt) WType {
+	ap.parenthesise(n.Value)
+	return nil
+}
+func (ap *AstPrinter) visitReturnStmt(n *ReturnStmt) WType {
+	if n.result != nil {
+		ap.parenthesise("return", n.result)
+	} else {
+		ap.parenthesise("return")
+	}
+	return nil
+}
+func (ap *AstPrinter) visitYieldStmt(n *YieldStmt) WType {
+	ap.parenthesise("yield", n.value)
+	return nil
+}
+
+func (ap *AstPrinter) visitBinExpr(n *BinExpr) WType {
+	ap.parenthesise(n.op.Value, n.left, n.right)
+	return nil
+}
+func (ap *AstPrinter) visitUnExpr(n *UnExpr) WType {
+	ap.parenthesise(n.op.Value, n.operand)
+	return nil
+}
+
+// visitGrpExpr prints the enclosed expression, its grouping is already shown
+// by the brackets of the s-expression
+func (ap *AstPrinter) visitGrpExpr(n *GrpExpr) WType {
+	n.x.accept(ap)
+	return nil
+}
+
+func (ap *AstPrinter) visitCallExpr(n *CallExpr) WType {
+	ap.parenthesise("call", append([]Node{n.fn}, exprs(n.args)...)...)
+	return nil
+}
+func (ap *AstPrinter) visitIndexExpr(n *IndexExpr) WType {
+	ap.parenthesise("index", n.x, n.index)
+	return nil
+}
+func (ap *AstPrinter) visitSliceExpr(n *SliceExpr) WType {
+	ap.buffer.WriteString("(slice ")
+	n.x.accept(ap)
+	for _, bound := range []Expr{n.lo, n.hi} {
+		ap.buffer.WriteString(" ")
+		if bound == nil {
+			ap.buffer.WriteString("_")
+		} else {
+			bound.accept(ap)
+		}
+	}
+	ap.buffer.WriteString(")")
+	return nil
+}
+
+func (ap *AstPrinter) visitBasicLit(n *BasicLit) WType {
+	switch n.Type {
+	case token.STR:
+		ap.buffer.WriteString("'" + n.Text + "'")
+	case token.CHAR:
+		ap.buffer.WriteString("c'" + n.Text + "'")
+	default:
+		ap.buffer.WriteString(n.Text)
+	}
+	return nil
+}
+func (ap *AstPrinter) visitList(n *List) WType {
+	ap.parenthesise("list", exprs(n.elements)...)
+	return nil
+}
+func (ap *AstPrinter) visitTuple(n *Tuple) WType {
+	ap.parenthesise("tuple", exprs(n.elements)...)
+	return nil
+}
+func (ap *AstPrinter) visitMap(n *Map) WType {
+	ap.buffer.WriteString("(map")
+	for k := range n.keys {
+		ap.buffer.WriteString(" ")
+		ap.parenthesise(":", n.keys[k], n.values[k])
+	}
+	ap.buffer.WriteString(")")
+	return nil
+}
+func (ap *AstPrinter) visitComprehension(n *Comprehension) WType {
+	if n.key != nil {
+		ap.buffer.WriteString("(mapcomp ")
+		ap.parenthesise(":", n.key, n.value)
+	} else {
+		ap.buffer.WriteString("(listcomp ")
+		n.value.accept(ap)
+	}
+	ap.buffer.WriteString(" ")
+	if n.forValue != nil {
+		ap.parenthesise("for", n.forKey, n.forValue, n.iter)
+	} else {
+		ap.parenthesise("for", n.forKey, n.iter)
+	}
+	if n.cond != nil {
+		ap.buffer.WriteString(" ")
+		ap.parenthesise("if", n.cond)
+	}
+	ap.buffer.WriteString(")")
+	return nil
+}
+func (ap *AstPrinter) visitFuncLit(n *FuncLit) WType {
+	ap.buffer.WriteString("(func ")
+	ap.parenthesise("params", idents(n.params)...)
+	ap.buffer.WriteString(" ")
+	n.body.accept(ap)
+	ap.buffer.WriteString(")")
+	return nil
+}
+func (ap *AstPrinter) visitID(n *Ident) WType {
+	ap.buffer.WriteString(n.Name)
+	return nil
+}
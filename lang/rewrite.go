@@ -0,0 +1,467 @@
+package lang
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Rewrite traverses the AST rooted at node in depth-first order, replacing each
+// node by the node returned by f, and returns the rewritten root. The children
+// of a node are rewritten before f is called on it, a node whose children were
+// replaced is copied with the new children so that the original AST is never
+// modified, the nodes left unchanged are shared by both ASTs.
+//
+// f returns its argument to keep a node. Returning nil removes a statement
+// from a file or a block, or an optional child such as an else branch, a
+// required child cannot be removed. Rewrite panics if a node is replaced by a
+// node that cannot take its place, e.g. an expression by a statement.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if isNilNode(node) {
+		return node
+	}
+	return (&rewriter{f: f}).rewrite(node)
+}
+
+// isNilNode reports whether the node is nil, or a nil pointer of an optional
+// child
+func isNilNode(n Node) bool { return n == nil || reflect.ValueOf(n).IsNil() }
+
+// rewriter implements NodeWalker, each visit sets node to the visited node,
+// or to a copy of it holding the rewritten children
+type rewriter struct {
+	f    func(Node) Node
+	node Node
+}
+
+// rewrite rewrites the children of the node and returns the node returned by
+// f, or nil if the node is nil
+func (r *rewriter) rewrite(n Node) Node {
+	if isNilNode(n) {
+		return nil
+	}
+	n.accept(r)
+	if res := r.f(r.node); !isNilNode(res) {
+		return res
+	}
+	return nil
+}
+
+// replace panics as a node cannot be replaced by the node res
+func replace(n, res Node) {
+	if res == nil {
+		panic(fmt.Sprintf("lang.Rewrite: cannot remove the required %T", n))
+	}
+	panic(fmt.Sprintf("lang.Rewrite: cannot replace %T with %T", n, res))
+}
+
+// expr rewrites a required expression
+func (r *rewriter) expr(x Expr) Expr {
+	res := r.rewrite(x)
+	if e, ok := res.(Expr); ok {
+		return e
+	}
+	replace(x, res)
+	return nil
+}
+
+// optExpr rewrites an optional expression, which may be nil
+func (r *rewriter) optExpr(x Expr) Expr {
+	if isNilNode(x) {
+		return nil
+	}
+	res := r.rewrite(x)
+	if res == nil {
+		return nil
+	}
+	if e, ok := res.(Expr); ok {
+		return e
+	}
+	replace(x, res)
+	return nil
+}
+
+// ident rewrites an identifier, which may only be replaced by an identifier
+func (r *rewriter) ident(id *Ident) *Ident {
+	res := r.rewrite(id)
+	if i, ok := res.(*Ident); ok {
+		return i
+	}
+	replace(id, res)
+	return nil
+}
+
+// optIdent rewrites an optional identifier, which may be nil
+func (r *rewriter) optIdent(id *Ident) *Ident {
+	if id == nil {
+		return nil
+	}
+	res := r.rewrite(id)
+	if res == nil {
+		return nil
+	}
+	if i, ok := res.(*Ident); ok {
+		return i
+	}
+	replace(id, res)
+	return nil
+}
+
+// block rewrites a block, which may only be replaced by a block
+func (r *rewriter) block(b *BlockStmt) *BlockStmt {
+	res := r.rewrite(b)
+	if bs, ok := res.(*BlockStmt); ok {
+		return bs
+	}
+	replace(b, res)
+	return nil
+}
+
+// exprs rewrites the expressions, returning the original slice if none of them
+// were replaced
+func (r *rewriter) exprs(xs []Expr) ([]Expr, bool) {
+	var res []Expr
+	for i, x := range xs {
+		e := r.expr(x)
+		if e != x && res == nil {
+			res = append(make([]Expr, 0, len(xs)), xs[:i]...)
+		}
+		if res != nil {
+			res = append(res, e)
+		}
+	}
+	if res == nil {
+		return xs, false
+	}
+	return res, true
+}
+
+// idents rewrites the identifiers, returning the original slice if none of
+// them were replaced
+func (r *rewriter) idents(ids []*Ident) ([]*Ident, bool) {
+	var res []*Ident
+	for i, id := range ids {
+		e := r.ident(id)
+		if e != id && res == nil {
+			res = append(make([]*Ident, 0, len(ids)), ids[:i]...)
+		}
+		if res != nil {
+			res = append(res, e)
+		}
+	}
+	if res == nil {
+		return ids, false
+	}
+	return res, true
+}
+
+// stmts rewrites the statements, dropping the removed ones, returning the
+// original slice if none of them were replaced or removed
+func (r *rewriter) stmts(ss []Stmt) ([]Stmt, bool) {
+	var res []Stmt
+	for i, s := range ss {
+		n := r.rewrite(s)
+		st, ok := n.(Stmt)
+		if n != nil && !ok {
+			replace(s, n)
+		}
+		if n != Node(s) && res == nil {
+			res = append(make([]Stmt, 0, len(ss)), ss[:i]...)
+		}
+		if res != nil && st != nil {
+			res = append(res, st)
+		}
+	}
+	if res == nil {
+		return ss, false
+	}
+	return res, true
+}
+
+// assign rewrites the targets and values of an assignment statement
+func (r *rewriter) assign(left, right []Expr) ([]Expr, []Expr, bool) {
+	l, lok := r.exprs(left)
+	rt, rok := r.exprs(right)
+	return l, rt, lok || rok
+}
+
+func (r *rewriter) visitFile(n *File) WType {
+	if stmts, ok := r.stmts(n.stmts); ok {
+		c := *n
+		c.stmts, c.Symbols = stmts, fileSymbols(stmts)
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+
+func (r *rewriter) visitExprStmt(n *ExprStmt) WType {
+	if exprs, ok := r.exprs(n.exprs); ok {
+		c := *n
+		c.exprs = exprs
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitAssignStmt(n *AssignStmt) WType {
+	if left, right, ok := r.assign(n.left, n.right); ok {
+		c := *n
+		c.left, c.right = left, right
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitPlusAssignStmt(n *PlusAssignStmt) WType {
+	if left, right, ok := r.assign(n.left, n.right); ok {
+		c := *n
+		c.left, c.right = left, right
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitMinusAssignStmt(n *MinusAssignStmt) WType {
+	if left, right, ok := r.assign(n.left, n.right); ok {
+		c := *n
+		c.left, c.right = left, right
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitDivAssignStmt(n *DivAssignStmt) WType {
+	if left, right, ok := r.assign(n.left, n.right); ok {
+		c := *n
+		c.left, c.right = left, right
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitMultAssignStmt(n *MultAssignStmt) WType {
+	if left, right, ok := r.assign(n.left, n.right); ok {
+		c := *n
+		c.left, c.right = left, right
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitModAssignStmt(n *ModAssignStmt) WType {
+	if left, right, ok := r.assign(n.left, n.right); ok {
+		c := *n
+		c.left, c.right = left, right
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitVarDecl(n *VarDecl) WType {
+	names, nok := r.idents(n.names)
+	values, vok := r.exprs(n.values)
+	if nok || vok {
+		c := *n
+		c.names, c.values = names, values
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitFuncDecl(n *FuncDecl) WType {
+	name := r.ident(n.name)
+	fn := r.rewrite(n.fn)
+	lit, ok := fn.(*FuncLit)
+	if !ok {
+		replace(n.fn, fn)
+	}
+	if name != n.name || lit != n.fn {
+		c := *n
+		c.name, c.fn = name, lit
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitBlockStmt(n *BlockStmt) WType {
+	if stmts, ok := r.stmts(n.stmts); ok {
+		c := *n
+		c.stmts = stmts
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitIfStmt(n *IfStmt) WType {
+	cond, body := r.expr(n.cond), r.block(n.body)
+	var elseStmt Stmt
+	if res := r.rewrite(n.elseStmt); res != nil {
+		switch res.(type) {
+		case *IfStmt, *BlockStmt:
+			elseStmt = res.(Stmt)
+		default:
+			replace(n.elseStmt, res)
+		}
+	}
+	if cond != n.cond || body != n.body || elseStmt != n.elseStmt {
+		c := *n
+		c.cond, c.body, c.elseStmt = cond, body, elseStmt
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitWhileStmt(n *WhileStmt) WType {
+	cond, body := r.expr(n.cond), r.block(n.body)
+	if cond != n.cond || body != n.body {
+		c := *n
+		c.cond, c.body = cond, body
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitForStmt(n *ForStmt) WType {
+	key, value := r.ident(n.key), r.optIdent(n.value)
+	iter, body := r.expr(n.iter), r.block(n.body)
+	if key != n.key || value != n.value || iter != n.iter || body != n.body {
+		c := *n
+		c.key, c.value, c.iter, c.body = key, value, iter, body
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitBranchStmt(n *BranchStmt) WType { r.node = n; return nil }
+func (r *rewriter) visitReturnStmt(n *ReturnStmt) WType {
+	if result := r.optExpr(n.result); result != n.result {
+		c := *n
+		c.result = result
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitYieldStmt(n *YieldStmt) WType {
+	if value := r.expr(n.value); value != n.value {
+		c := *n
+		c.value = value
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+
+func (r *rewriter) visitBinExpr(n *BinExpr) WType {
+	left, right := r.expr(n.left), r.expr(n.right)
+	if left != n.left || right != n.right {
+		c := *n
+		c.left, c.right = left, right
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitUnExpr(n *UnExpr) WType {
+	if operand := r.expr(n.operand); operand != n.operand {
+		c := *n
+		c.operand = operand
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitGrpExpr(n *GrpExpr) WType {
+	if x := r.expr(n.x); x != n.x {
+		c := *n
+		c.x = x
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitCallExpr(n *CallExpr) WType {
+	fn := r.expr(n.fn)
+	args, ok := r.exprs(n.args)
+	if fn != n.fn || ok {
+		c := *n
+		c.fn, c.args = fn, args
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitIndexExpr(n *IndexExpr) WType {
+	x, index := r.expr(n.x), r.expr(n.index)
+	if x != n.x || index != n.index {
+		c := *n
+		c.x, c.index = x, index
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitSliceExpr(n *SliceExpr) WType {
+	x, lo, hi := r.expr(n.x), r.optExpr(n.lo), r.optExpr(n.hi)
+	if x != n.x || lo != n.lo || hi != n.hi {
+		c := *n
+		c.x, c.lo, c.hi = x, lo, hi
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitBasicLit(n *BasicLit) WType { r.node = n; return nil }
+func (r *rewriter) visitList(n *List) WType {
+	if elements, ok := r.exprs(n.elements); ok {
+		c := *n
+		c.elements = elements
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitTuple(n *Tuple) WType {
+	if elements, ok := r.exprs(n.elements); ok {
+		c := *n
+		c.elements = elements
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitMap(n *Map) WType {
+	keys, kok := r.exprs(n.keys)
+	values, vok := r.exprs(n.values)
+	if kok || vok {
+		c := *n
+		c.keys, c.values = keys, values
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitComprehension(n *Comprehension) WType {
+	key, value := r.optExpr(n.key), r.expr(n.value)
+	forKey, forValue := r.ident(n.forKey), r.optIdent(n.forValue)
+	iter, cond := r.expr(n.iter), r.optExpr(n.cond)
+	if key != n.key || value != n.value || forKey != n.forKey || forValue != n.forValue ||
+		iter != n.iter || cond != n.cond {
+		c := *n
+		c.key, c.value, c.forKey, c.forValue, c.iter, c.cond = key, value, forKey, forValue, iter, cond
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitFuncLit(n *FuncLit) WType {
+	params, ok := r.idents(n.params)
+	if body := r.block(n.body); ok || body != n.body {
+		c := *n
+		c.params, c.body = params, body
+		n = &c
+	}
+	r.node = n
+	return nil
+}
+func (r *rewriter) visitID(n *Ident) WType { r.node = n; return nil }
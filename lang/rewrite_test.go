@@ -0,0 +1,126 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+func TestRewrite(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+		f           func(Node) Node
+		expected    string // source of the rewritten AST
+	}{
+		{"identity", "x = 1 + 2\nprint(x)", func(n Node) Node { return n },
+			"x = 1 + 2\nprint(x)"},
+		{"rename", "x = x + 1\nfunc f(x) { return x }", func(n Node) Node {
+			if id, ok := n.(*Ident); ok && id.Name == "x" {
+				return newID(token.Token{Type: token.NAME, Value: "y", Pos: id.Pos(), End: id.End()})
+			}
+			return n
+		}, "y = y + 1\nfunc f(y) {\n\treturn y\n}"},
+		{"fold constants", "x = 1 + 2 * 3", func(n Node) Node {
+			if b, ok := n.(*BinExpr); ok {
+				l, lok := b.left.(*BasicLit)
+				r, rok := b.right.(*BasicLit)
+				if lok && rok && l.Type == token.INT && r.Type == token.INT {
+					var a, c int
+					fmt.Sscan(l.Value, &a)
+					fmt.Sscan(r.Value, &c)
+					v := a + c
+					if b.op.Type == token.MULT {
+						v = a * c
+					}
+					return newBasicLit(token.Token{Type: token.INT, Value: fmt.Sprint(v), Pos: b.Pos(), End: b.End()})
+				}
+			}
+			return n
+		}, "x = 7"},
+		{"remove statements", "x = 1\nprint(x)\nif x { print(x) }", func(n Node) Node {
+			if s, ok := n.(*ExprStmt); ok {
+				if call, ok := s.exprs[0].(*CallExpr); ok && call.fn.(*Ident).Name == "print" {
+					return nil
+				}
+			}
+			return n
+		}, "x = 1\n\nif x {}"},
+		{"remove an else branch", "if x { y } else { z }", func(n Node) Node {
+			if s, ok := n.(*IfStmt); ok && s.elseStmt != nil {
+				return newIfStmt(s.cond, s.body, nil, token.Token{Type: token.IF, Pos: s.Pos()})
+			}
+			return n
+		}, "if x {\n\ty\n}"},
+	} {
+		f, err := ParseFile(tc.name, tc.input)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		before := new(SourcePrinter).Print(f)
+		res := Rewrite(f, tc.f)
+		if got := strings.TrimSpace(new(SourcePrinter).Print(res)); got != tc.expected {
+			t.Errorf("%s: got\n%s\nexpected\n%s", tc.name, got, tc.expected)
+		}
+		if err := Check(res); err != nil {
+			t.Errorf("%s: invalid AST:\n%s", tc.name, err)
+		}
+		if after := new(SourcePrinter).Print(f); after != before {
+			t.Errorf("%s: the original AST was modified, got\n%s\nexpected\n%s", tc.name, after, before)
+		}
+	}
+}
+
+func TestRewriteKeepsUnchangedNodes(t *testing.T) {
+	f, err := ParseFile("keep", "x = 1\ny = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := Rewrite(f, func(n Node) Node { return n }); res != Node(f) {
+		t.Errorf("got a copy of the root, expected the root itself as no node was replaced")
+	}
+	res := Rewrite(f, func(n Node) Node {
+		if id, ok := n.(*Ident); ok && id.Name == "y" {
+			return newID(token.Token{Type: token.NAME, Value: "z", Pos: id.Pos(), End: id.End()})
+		}
+		return n
+	}).(*File)
+	if res == f || res.stmts[0] != f.stmts[0] || res.stmts[1] == f.stmts[1] {
+		t.Errorf("got statements %v of %v, expected only the second statement to be copied", res.stmts, f.stmts)
+	}
+}
+
+func TestRewriteInvalidReplacement(t *testing.T) {
+	for _, tc := range []struct {
+		name, input string
+		f           func(Node) Node
+		expected    string // expected to be contained in the panic
+	}{
+		{"expression by a statement", "x = 1 + 2", func(n Node) Node {
+			if _, ok := n.(*BasicLit); ok {
+				return newBranchStmt(token.Token{Type: token.BREAK})
+			}
+			return n
+		}, "cannot replace *lang.BasicLit with *lang.BranchStmt"},
+		{"required child", "x = -y", func(n Node) Node {
+			if id, ok := n.(*Ident); ok && id.Name == "y" {
+				return nil
+			}
+			return n
+		}, "cannot remove the required *lang.Ident"},
+	} {
+		f, err := ParseFile(tc.name, tc.input)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		func() {
+			defer func() {
+				if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), tc.expected) {
+					t.Errorf("%s: got panic %v, expected %q", tc.name, e, tc.expected)
+				}
+			}()
+			Rewrite(f, tc.f)
+		}()
+	}
+}
@@ -1,5 +1,11 @@
 package lang
 
+import (
+	"sort"
+
+	"github.com/lohvht/went/lang/token"
+)
+
 // Symbol represents the program entities that we would want to track via the
 // symbol table
 type Symbol interface {
@@ -28,6 +34,9 @@ type TypeSymbol struct{ baseSymbol }
 // VarSymbol is symbol that represents a variable (using an identifier)
 type VarSymbol struct{ baseSymbol }
 
+// NewVarSymbol returns the symbol of a variable of the given name
+func NewVarSymbol(name string) VarSymbol { return VarSymbol{baseSymbol{name: name}} }
+
 // Built-in Types Symbols
 var (
 	intType   = TypeSymbol{baseSymbol{name: "int"}}
@@ -81,6 +90,17 @@ func (bs baseScope) Resolve(name string) (Symbol, bool) {
 	return nil, ok
 }
 
+// Names returns the sorted names of all symbols defined in this scope, not
+// including those of its parent scopes
+func (bs baseScope) Names() []string {
+	names := make([]string, 0, len(bs.symbols))
+	for name := range bs.symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GlobalScope is the global context that should be accessible by other scopes
 type GlobalScope struct{ baseScope }
 
@@ -107,9 +127,107 @@ func NewSymbolTable() *SymbolTable {
 	return st
 }
 
+// Globals returns the global scope of the symbol table
+func (symbtab *SymbolTable) Globals() *GlobalScope { return symbtab.globals }
+
 // initTypeSystem initialises the built-in types that went supports
 func (symbtab *SymbolTable) initTypeSystem() {
 	for _, v := range DefaultTypeMap {
 		symbtab.globals.Define(v)
 	}
 }
+
+// fileSymbols returns the symbol table of a script, defining the names that
+// its statements bind in the global scope, i.e. outside of any function
+func fileSymbols(stmts []Stmt) *SymbolTable {
+	st := NewSymbolTable()
+	bindings(stmts, func(id *Ident, _ bool) { st.globals.Define(VarSymbol{baseSymbol{name: id.Name}}) })
+	return st
+}
+
+// bindings calls bind with each name bound by the statements in their scope,
+// in the order of the source, not counting those bound in the functions
+// defined by the statements. declared is whether the name is declared by a var
+// or func statement, which always binds it in the scope, rather than assigned
+// to, which rebinds the name of an enclosing scope if there is one
+func bindings(stmts []Stmt, bind func(id *Ident, declared bool)) {
+	define := func(id *Ident) {
+		if id != nil {
+			bind(id, false)
+		}
+	}
+	for _, stmt := range stmts {
+		Inspect(stmt, func(n Node) bool {
+			switch n := n.(type) {
+			case *FuncLit:
+				return false
+			case *FuncDecl:
+				bind(n.name, true)
+			case *VarDecl:
+				for _, name := range n.names {
+					bind(name, true)
+				}
+			case *AssignStmt:
+				for _, target := range n.left {
+					if id, ok := target.(*Ident); ok {
+						define(id)
+					}
+				}
+			case *ForStmt:
+				define(n.key)
+				define(n.value)
+			}
+			return true
+		})
+	}
+}
+
+// IdentAt returns the identifier of the AST rooted at root that spans the
+// position, nil if there is none
+func IdentAt(root Node, pos token.Pos) *Ident {
+	var found *Ident
+	Inspect(root, func(n Node) bool {
+		if found != nil || n.Pos() > pos || n.End() < pos {
+			return false
+		}
+		if id, ok := n.(*Ident); ok {
+			found = id
+		}
+		return true
+	})
+	return found
+}
+
+// Definition returns the identifier where the name of id is bound in the file,
+// looking in the innermost function enclosing id first, at its parameters and
+// then at the names its body binds, then in the functions enclosing it and
+// lastly outside of any function. Of the names bound in a scope the first one
+// in the source is returned, nil if the name is not bound.
+func Definition(f *File, id *Ident) *Ident {
+	var enclosing []*FuncLit // outermost first
+	Inspect(f, func(n Node) bool {
+		if n.Pos() > id.Pos() || n.End() < id.End() {
+			return false
+		}
+		if lit, ok := n.(*FuncLit); ok {
+			enclosing = append(enclosing, lit)
+		}
+		return true
+	})
+	var def *Ident
+	find := func(name *Ident) {
+		if def == nil && name.Name == id.Name {
+			def = name
+		}
+	}
+	for k := len(enclosing) - 1; k >= 0 && def == nil; k-- {
+		for _, param := range enclosing[k].params {
+			find(param)
+		}
+		bindings(enclosing[k].body.stmts, func(name *Ident, _ bool) { find(name) })
+	}
+	if def == nil {
+		bindings(f.stmts, func(name *Ident, _ bool) { find(name) })
+	}
+	return def
+}
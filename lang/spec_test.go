@@ -0,0 +1,107 @@
+package lang
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The scripts of testdata/spec are the behavioural spec of the language, each
+// is run through the whole pipeline and its results are compared to the
+// expectations written in comments of the form:
+//
+//	// Result: the value of the last statement, e.g. 7 or 'abc'
+//	// Output: a line written to stdout, one comment per line
+//	// Error: line:col: code kind - msg, one comment per error
+//
+// A script without a Result comment must not have a result, and one without
+// an Error comment must run without errors.
+const (
+	specResult = "// Result: "
+	specOutput = "// Output: "
+	specError  = "// Error: "
+)
+
+// spec holds the expectations of a script
+type spec struct {
+	result string // empty if the script has no result
+	output []string
+	errors []string
+}
+
+// parseSpec returns the expectations written in the comments of the input
+func parseSpec(input string) spec {
+	var s spec
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, specResult):
+			s.result = strings.TrimPrefix(line, specResult)
+		case strings.HasPrefix(line, specOutput):
+			s.output = append(s.output, strings.TrimPrefix(line, specOutput))
+		case strings.HasPrefix(line, specError):
+			s.errors = append(s.errors, strings.TrimPrefix(line, specError))
+		}
+	}
+	return s
+}
+
+// runSpec parses and interprets the input, returning what it actually did in
+// the form of its expectations
+func runSpec(name, input string) spec {
+	var s spec
+	diags := NewDiagnostics(input)
+	var out bytes.Buffer
+	p, err := Parse(name, input)
+	if err == nil {
+		var i *Interpreter
+		i, err = InterpretContext(context.Background(), p.Root,
+			Config{Stdout: &out, Stderr: &out, Stdin: strings.NewReader("")})
+		if err == nil && i.Result != nil {
+			s.result = fmt.Sprint(i.Result)
+		}
+	}
+	diags.Add(err)
+	for _, d := range diags.List() {
+		msg := d.Msg
+		if d.Kind != "" {
+			msg = d.Kind + " - " + msg
+		}
+		s.errors = append(s.errors, fmt.Sprintf("%s: %s %s", d.Pos, d.Code, msg))
+	}
+	if output := strings.TrimSuffix(out.String(), "\n"); output != "" {
+		s.output = strings.Split(output, "\n")
+	}
+	return s
+}
+
+func TestSpec(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "spec", "*.went"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no scripts found in testdata/spec")
+	}
+	for _, path := range paths {
+		input, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := parseSpec(string(input))
+		got := runSpec(filepath.Base(path), string(input))
+		if got.result != expected.result {
+			t.Errorf("%s: got result %q, expected %q", path, got.result, expected.result)
+		}
+		if g, e := strings.Join(got.output, "\n"), strings.Join(expected.output, "\n"); g != e {
+			t.Errorf("%s: got output\n%s\nexpected\n%s", path, g, e)
+		}
+		if g, e := strings.Join(got.errors, "\n"), strings.Join(expected.errors, "\n"); g != e {
+			t.Errorf("%s: got errors\n%s\nexpected\n%s", path, g, e)
+		}
+	}
+}
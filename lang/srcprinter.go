@@ -0,0 +1,486 @@
+package lang
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lohvht/went/lang/token"
+)
+
+// SourcePrinter implements NodeWalker, it prints the AST as went source code
+// that parses back to the same AST. Statements are indented with tabs, and the
+// brackets needed to keep the precedence of the operators are added
+type SourcePrinter struct {
+	buffer   bytes.Buffer
+	indent   int           // number of tabs that the lines are indented by
+	comments []token.Token // comments of the File left to print
+}
+
+// Print returns the source code of the AST rooted at node, the comments of a
+// File are printed before the statement that follows them, or at the end of
+// the line of the statement that they follow on the same line
+func (sp *SourcePrinter) Print(node Node) string {
+	sp.buffer.Reset()
+	sp.indent, sp.comments = 0, nil
+	if node != nil {
+		node.accept(sp)
+	}
+	return sp.buffer.String()
+}
+
+// newline ends the line, indenting the next one
+func (sp *SourcePrinter) newline() {
+	sp.buffer.WriteString("\n")
+	sp.buffer.WriteString(strings.Repeat("\t", sp.indent))
+}
+
+// commentsBefore prints the comments that start before pos, each on its own
+// line
+func (sp *SourcePrinter) commentsBefore(pos token.Pos) {
+	for len(sp.comments) > 0 && before(sp.comments[0].Pos, pos) {
+		sp.buffer.WriteString(sp.comments[0].Value)
+		sp.newline()
+		sp.comments = sp.comments[1:]
+	}
+}
+
+// commentsOnLine prints the comments that start on the line at the end of the
+// line
+func (sp *SourcePrinter) commentsOnLine(line int) {
+	for len(sp.comments) > 0 && sp.comments[0].Pos.Line() == line {
+		sp.buffer.WriteString(" ")
+		sp.buffer.WriteString(sp.comments[0].Value)
+		sp.comments = sp.comments[1:]
+	}
+}
+
+// before reports whether the position p comes before q
+func before(p, q token.Pos) bool {
+	return p.Line() < q.Line() || p.Line() == q.Line() && p.Col() < q.Col()
+}
+
+// stmts prints the statements one per line, keeping a single blank line where
+// the statements were separated by blank lines
+func (sp *SourcePrinter) stmts(ss []Stmt) {
+	for i, s := range ss {
+		if i > 0 {
+			sp.newline()
+			if s.Pos().Line() > ss[i-1].End().Line()+1 {
+				sp.newline()
+			}
+		}
+		sp.commentsBefore(s.Pos())
+		sp.stmt(s)
+		sp.commentsOnLine(s.End().Line())
+	}
+}
+
+// stmt prints the statement, an expression statement starting with a map or
+// a function literal is enclosed in brackets so that it is not parsed as a
+// block or a function declaration
+func (sp *SourcePrinter) stmt(s Stmt) {
+	if es, ok := s.(*ExprStmt); ok && startsAmbiguously(es.exprs[0]) {
+		sp.buffer.WriteString("(")
+		sp.exprs(es.exprs)
+		sp.buffer.WriteString(")")
+		return
+	}
+	s.accept(sp)
+}
+
+// startsAmbiguously reports whether the expression starts with a map or a
+// function literal, which at the start of a statement would be parsed as a
+// block or a function declaration
+func startsAmbiguously(x Expr) bool {
+	for {
+		switch n := x.(type) {
+		case *Map, *FuncLit:
+			return true
+		case *BinExpr:
+			x = n.left
+		case *CallExpr:
+			x = n.fn
+		case *IndexExpr:
+			x = n.x
+		case *SliceExpr:
+			x = n.x
+		default:
+			return false
+		}
+	}
+}
+
+// block prints the statements enclosed in curly brackets, or "{}" if there are
+// no statements nor comments within the brackets
+func (sp *SourcePrinter) block(n *BlockStmt) {
+	if len(n.stmts) == 0 && (len(sp.comments) == 0 || !before(sp.comments[0].Pos, n.RCurlyPos)) {
+		sp.buffer.WriteString("{}")
+		return
+	}
+	sp.buffer.WriteString("{")
+	sp.indent++
+	sp.newline()
+	sp.stmts(n.stmts)
+	if len(n.stmts) > 0 && len(sp.comments) > 0 && before(sp.comments[0].Pos, n.RCurlyPos) {
+		sp.newline()
+	}
+	sp.commentsBefore(n.RCurlyPos)
+	sp.indent--
+	// drop the indentation written for the line of the closing bracket
+	sp.buffer.Truncate(len(bytes.TrimRight(sp.buffer.Bytes(), "\t")))
+	if !bytes.HasSuffix(sp.buffer.Bytes(), []byte("\n")) {
+		sp.newline()
+	} else {
+		sp.buffer.WriteString(strings.Repeat("\t", sp.indent))
+	}
+	sp.buffer.WriteString("}")
+}
+
+// exprs prints the expressions separated by commas
+func (sp *SourcePrinter) exprs(xs []Expr) {
+	for i, x := range xs {
+		if i > 0 {
+			sp.buffer.WriteString(", ")
+		}
+		x.accept(sp)
+	}
+}
+
+// idents prints the identifiers separated by commas
+func (sp *SourcePrinter) idents(ids []*Ident) {
+	for i, id := range ids {
+		if i > 0 {
+			sp.buffer.WriteString(", ")
+		}
+		sp.buffer.WriteString(id.Name)
+	}
+}
+
+// atomPrec is the precedence of the expressions that are not operations, they
+// never need brackets
+const atomPrec = unaryPrec + 1
+
+// exprPrec returns the precedence of the expression, a chain of prefix
+// operators binds as loosely as the loosest of them, e.g. "-!a == b" is
+// "-(!(a == b))"
+func exprPrec(x Expr) int {
+	switch n := x.(type) {
+	case *BinExpr:
+		return binaryPrecs[n.op.Type]
+	case *UnExpr:
+		prec := unaryPrecs[n.op.Type]
+		if operand, ok := n.operand.(*UnExpr); ok {
+			if p := exprPrec(operand); p < prec {
+				prec = p
+			}
+		}
+		return prec
+	}
+	return atomPrec
+}
+
+// operand prints the expression, enclosed in round brackets if its precedence
+// is lower than prec, or not higher than prec if orEq is set
+func (sp *SourcePrinter) operand(x Expr, prec int, orEq bool) {
+	p := exprPrec(x)
+	if p < prec || orEq && p == prec {
+		sp.buffer.WriteString("(")
+		x.accept(sp)
+		sp.buffer.WriteString(")")
+		return
+	}
+	x.accept(sp)
+}
+
+// assign prints an assignment statement with the given operator
+func (sp *SourcePrinter) assign(op string, left, right []Expr) {
+	sp.exprs(left)
+	sp.buffer.WriteString(" " + op + " ")
+	sp.exprs(right)
+}
+
+func (sp *SourcePrinter) visitFile(n *File) WType {
+	sp.comments = n.Comments
+	sp.stmts(n.stmts)
+	if len(n.stmts) > 0 && len(sp.comments) > 0 {
+		sp.newline()
+	}
+	for _, c := range sp.comments {
+		sp.buffer.WriteString(c.Value)
+		sp.newline()
+	}
+	sp.comments = nil
+	if sp.buffer.Len() > 0 && !bytes.HasSuffix(sp.buffer.Bytes(), []byte("\n")) {
+		sp.buffer.WriteString("\n")
+	}
+	return nil
+}
+
+func (sp *SourcePrinter) visitExprStmt(n *ExprStmt) WType {
+	sp.exprs(n.exprs)
+	return nil
+}
+func (sp *SourcePrinter) visitAssignStmt(n *AssignStmt) WType {
+	sp.assign("=", n.left, n.right)
+	return nil
+}
+func (sp *SourcePrinter) visitPlusAssignStmt(n *PlusAssignStmt) WType {
+	sp.assign("+=", n.left, n.right)
+	return nil
+}
+func (sp *SourcePrinter) visitMinusAssignStmt(n *MinusAssignStmt) WType {
+	sp.assign("-=", n.left, n.right)
+	return nil
+}
+func (sp *SourcePrinter) visitDivAssignStmt(n *DivAssignStmt) WType {
+	sp.assign("/=", n.left, n.right)
+	return nil
+}
+func (sp *SourcePrinter) visitMultAssignStmt(n *MultAssignStmt) WType {
+	sp.assign("*=", n.left, n.right)
+	return nil
+}
+func (sp *SourcePrinter) visitModAssignStmt(n *ModAssignStmt) WType {
+	sp.assign("%=", n.left, n.right)
+	return nil
+}
+func (sp *SourcePrinter) visitVarDecl(n *VarDecl) WType {
+	sp.buffer.WriteString("var ")
+	sp.idents(n.names)
+	if len(n.values) > 0 {
+		sp.buffer.WriteString(" = ")
+		sp.exprs(n.values)
+	}
+	return nil
+}
+func (sp *SourcePrinter) visitFuncDecl(n *FuncDecl) WType {
+	sp.buffer.WriteString("func " + n.name.Name)
+	sp.funcBody(n.fn)
+	return nil
+}
+func (sp *SourcePrinter) visitBlockStmt(n *BlockStmt) WType {
+	sp.block(n)
+	return nil
+}
+func (sp *SourcePrinter) visitIfStmt(n *IfStmt) WType {
+	sp.buffer.WriteString("if ")
+	for {
+		n.cond.accept(sp)
+		sp.buffer.WriteString(" ")
+		sp.block(n.body)
+		switch elseStmt := n.elseStmt.(type) {
+		case *IfStmt:
+			sp.buffer.WriteString(" elif ")
+			n = elseStmt
+			continue
+		case *BlockStmt:
+			sp.buffer.WriteString(" else ")
+			sp.block(elseStmt)
+		}
+		return nil
+	}
+}
+func (sp *SourcePrinter) visitWhileStmt(n *WhileStmt) WType {
+	sp.buffer.WriteString("while ")
+	n.cond.accept(sp)
+	sp.buffer.WriteString(" ")
+	sp.block(n.body)
+	return nil
+}
+func (sp *SourcePrinter) visitForStmt(n *ForStmt) WType {
+	sp.buffer.WriteString("for " + n.key.Name)
+	if n.value != nil {
+		sp.buffer.WriteString(", " + n.value.Name)
+	}
+	sp.buffer.WriteString(" in ")
+	n.iter.accept(sp)
+	sp.buffer.WriteString(" ")
+	sp.block(n.body)
+	return nil
+}
+func (sp *SourcePrinter) visitBranchStmt(n *BranchStmt) WType {
+	sp.buffer.WriteString(n.Type.String())
+	return nil
+}
+func (sp *SourcePrinter) visitReturnStmt(n *ReturnStmt) WType {
+	sp.buffer.WriteString("return")
+	if n.result == nil {
+		return nil
+	}
+	sp.buffer.WriteString(" ")
+	if t, ok := n.result.(*Tuple); ok && t.LRoundPos == 0 && len(t.elements) > 1 {
+		sp.exprs(t.elements) // the results of "return a, b"
+	} else {
+		n.result.accept(sp)
+	}
+	return nil
+}
+func (sp *SourcePrinter) visitYieldStmt(n *YieldStmt) WType {
+	sp.buffer.WriteString("yield ")
+	n.value.accept(sp)
+	return nil
+}
+
+func (sp *SourcePrinter) visitBinExpr(n *BinExpr) WType {
+	// the binary operators are left associative, a right operand of the same
+	// precedence needs brackets
+	prec := binaryPrecs[n.op.Type]
+	sp.operand(n.left, prec, false)
+	sp.buffer.WriteString(" " + n.op.Type.String() + " ")
+	sp.operand(n.right, prec, true)
+	return nil
+}
+func (sp *SourcePrinter) visitUnExpr(n *UnExpr) WType {
+	sp.buffer.WriteString(n.op.Type.String())
+	if _, ok := n.operand.(*UnExpr); ok {
+		n.operand.accept(sp)
+		return nil
+	}
+	sp.operand(n.operand, unaryPrecs[n.op.Type], true)
+	return nil
+}
+func (sp *SourcePrinter) visitGrpExpr(n *GrpExpr) WType {
+	sp.buffer.WriteString("(")
+	n.x.accept(sp)
+	sp.buffer.WriteString(")")
+	return nil
+}
+
+func (sp *SourcePrinter) visitCallExpr(n *CallExpr) WType {
+	sp.operand(n.fn, atomPrec, false)
+	sp.buffer.WriteString("(")
+	sp.exprs(n.args)
+	sp.buffer.WriteString(")")
+	return nil
+}
+func (sp *SourcePrinter) visitIndexExpr(n *IndexExpr) WType {
+	sp.operand(n.x, atomPrec, false)
+	sp.buffer.WriteString("[")
+	n.index.accept(sp)
+	sp.buffer.WriteString("]")
+	return nil
+}
+func (sp *SourcePrinter) visitSliceExpr(n *SliceExpr) WType {
+	sp.operand(n.x, atomPrec, false)
+	sp.buffer.WriteString("[")
+	if n.lo != nil {
+		n.lo.accept(sp)
+	}
+	sp.buffer.WriteString(":")
+	if n.hi != nil {
+		n.hi.accept(sp)
+	}
+	sp.buffer.WriteString("]")
+	return nil
+}
+
+func (sp *SourcePrinter) visitBasicLit(n *BasicLit) WType {
+	switch n.Type {
+	case token.STR:
+		sp.buffer.WriteString(quote(n.Text))
+	case token.CHAR:
+		if r, w := utf8.DecodeRuneInString(n.Text); r == utf8.RuneError && w == 1 {
+			sp.buffer.WriteString("c'" + n.Text[:1] + "'") // an invalid byte is kept as it is
+		} else {
+			sp.buffer.WriteString("c" + strconv.QuoteRune([]rune(n.Text + "\x00")[0]))
+		}
+	default:
+		sp.buffer.WriteString(n.Text)
+	}
+	return nil
+}
+func (sp *SourcePrinter) visitList(n *List) WType {
+	sp.buffer.WriteString("[")
+	sp.exprs(n.elements)
+	sp.buffer.WriteString("]")
+	return nil
+}
+func (sp *SourcePrinter) visitTuple(n *Tuple) WType {
+	sp.buffer.WriteString("(")
+	sp.exprs(n.elements)
+	if len(n.elements) == 1 {
+		sp.buffer.WriteString(",") // a single element without a comma is a group
+	}
+	sp.buffer.WriteString(")")
+	return nil
+}
+func (sp *SourcePrinter) visitMap(n *Map) WType {
+	sp.buffer.WriteString("{")
+	for i := range n.keys {
+		if i > 0 {
+			sp.buffer.WriteString(", ")
+		}
+		n.keys[i].accept(sp)
+		sp.buffer.WriteString(": ")
+		n.values[i].accept(sp)
+	}
+	sp.buffer.WriteString("}")
+	return nil
+}
+func (sp *SourcePrinter) visitComprehension(n *Comprehension) WType {
+	if n.key != nil {
+		sp.buffer.WriteString("{")
+		n.key.accept(sp)
+		sp.buffer.WriteString(": ")
+	} else {
+		sp.buffer.WriteString("[")
+	}
+	n.value.accept(sp)
+	sp.buffer.WriteString(" for " + n.forKey.Name)
+	if n.forValue != nil {
+		sp.buffer.WriteString(", " + n.forValue.Name)
+	}
+	sp.buffer.WriteString(" in ")
+	n.iter.accept(sp)
+	if n.cond != nil {
+		sp.buffer.WriteString(" if ")
+		n.cond.accept(sp)
+	}
+	if n.key != nil {
+		sp.buffer.WriteString("}")
+	} else {
+		sp.buffer.WriteString("]")
+	}
+	return nil
+}
+func (sp *SourcePrinter) visitFuncLit(n *FuncLit) WType {
+	sp.buffer.WriteString("func")
+	sp.funcBody(n)
+	return nil
+}
+func (sp *SourcePrinter) visitID(n *Ident) WType {
+	sp.buffer.WriteString(n.Name)
+	return nil
+}
+
+// funcBody prints the parameters and the body of the function
+func (sp *SourcePrinter) funcBody(n *FuncLit) {
+	sp.buffer.WriteString("(")
+	sp.idents(n.params)
+	sp.buffer.WriteString(") ")
+	sp.block(n.body)
+}
+
+// quote returns the string as a single quoted went string literal
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for len(s) > 0 {
+		r, w := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && w == 1 {
+			// an invalid byte has no escape sequence, it is kept as it is in
+			// the input
+			b.WriteByte(s[0])
+		} else {
+			// a quoted rune escapes the single quote and the unprintable runes
+			// the same way that a went string does
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		}
+		s = s[w:]
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package lang
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// roundTripInputs are scripts whose printed source must parse back to the same
+// AST, exercising the brackets needed to keep the precedence of the operators
+var roundTripInputs = []string{
+	"x = [y * 2 for y in l if y > 0]; d = {k: v for k, v in m}",
+	"x = (1 + 2) * 3",
+	"x = 1 - (2 - 3)",
+	"x = -(-a) + !(!b)",
+	"x = (a || b) && c || !(d && e)",
+	"x = (a + b)[1:(c + 1)](d)",
+	"x = [(a + b)[0], -c[1], a + !b == c]",
+	"x = {'a': func(y) { return y % 2 }, 'b': [1.5e3, 0x1F, c'x']}",
+	"x = '''a\nb''' + `c\\d` + 'it\\'s'",
+	"if a { b } elif c { d } else { e }",
+	"while !(a in b) { a += 1; continue }",
+	"for k, v in m { if k == v { break } }",
+	"var a, b = 1, 2\nfunc f(a, b) { return }",
+	"x = [(), (a,), (a, (b + c), [d])]\nfunc f() { return a, (b, c) }\na, b = f()",
+	"func g(n) { while n > 0 { yield (n, n * 2); n -= 1 } }",
+	"// lead\nx = 1 // trail\n/* block */ y = 2",
+}
+
+// stripGroups returns the AST without its round brackets, the printer may add
+// brackets that are not needed, as the structure of the AST already holds the
+// precedence of the operators
+func stripGroups(node Node) Node {
+	return Rewrite(node, func(n Node) Node {
+		if g, ok := n.(*GrpExpr); ok {
+			return g.x
+		}
+		return n
+	})
+}
+
+// checkRoundTrip checks that printing the AST of the input as source and
+// parsing it again yields a structurally equal AST, ignoring round brackets
+func checkRoundTrip(t *testing.T, name, input string) {
+	t.Helper()
+	f, err := ParseFile(name, input)
+	if err != nil {
+		t.Errorf("%s: %s", name, err)
+		return
+	}
+	src := new(SourcePrinter).Print(f)
+	g, err := ParseFile(name, src)
+	if err != nil {
+		t.Errorf("%s: printed source does not parse: %s\n%s", name, err, src)
+		return
+	}
+	if !Equal(stripGroups(f), stripGroups(g), true) {
+		ap := new(AstPrinter)
+		t.Errorf("%s: printed source parses to a different AST\nsource:\n%s\ngot:\n%s\nexpected:\n%s",
+			name, src, ap.Print(g), ap.Print(f))
+	}
+}
+
+func TestSourceRoundTrip(t *testing.T) {
+	for _, input := range roundTripInputs {
+		checkRoundTrip(t, input, input)
+	}
+	paths, err := filepath.Glob(filepath.Join("testdata", "parse", "*.went"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		input, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseFile(path, string(input)); err == nil {
+			checkRoundTrip(t, path, string(input))
+		}
+	}
+	checkRoundTrip(t, "benchScript", benchScript)
+}
@@ -0,0 +1,245 @@
+package token
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrorKind classifies the errors found while scanning the input, so that
+// clients can react to an error without matching its message
+type ErrorKind int
+
+// Kinds of scanning errors
+const (
+	UnknownError        ErrorKind = iota
+	UnexpectedChar                // a character that cannot start or continue a token
+	UnexpectedBracket             // a right bracket that closes no left bracket
+	UnclosedBracket               // a left bracket still open at the end of the input
+	UnterminatedString            // a quoted, triple-quoted or raw string left open
+	UnterminatedChar              // a character literal left open
+	UnterminatedComment           // a multiline comment left open
+	InvalidEscape                 // an invalid escape sequence in a string or character literal
+	InvalidChar                   // a character literal not holding exactly one character
+	InvalidNumber                 // a malformed number literal
+)
+
+var errorKinds = [...]string{
+	UnknownError:        "unknown error",
+	UnexpectedChar:      "unexpected character",
+	UnexpectedBracket:   "unexpected bracket",
+	UnclosedBracket:     "unclosed bracket",
+	UnterminatedString:  "unterminated string",
+	UnterminatedChar:    "unterminated character literal",
+	UnterminatedComment: "unterminated comment",
+	InvalidEscape:       "invalid escape sequence",
+	InvalidChar:         "invalid character literal",
+	InvalidNumber:       "invalid number",
+}
+
+// errorCodes holds the codes of the kinds of scanning errors, these are stable
+// and may be used to refer to a specific error, e.g. in the documentation
+var errorCodes = [...]string{
+	UnknownError:        "W1000",
+	UnterminatedString:  "W1001",
+	UnterminatedChar:    "W1002",
+	UnterminatedComment: "W1003",
+	UnexpectedChar:      "W1004",
+	UnexpectedBracket:   "W1005",
+	UnclosedBracket:     "W1006",
+	InvalidEscape:       "W1007",
+	InvalidChar:         "W1008",
+	InvalidNumber:       "W1009",
+}
+
+// Code returns the stable code of the kind of error, e.g. "W1001" for an
+// unterminated string, or the code of UnknownError for an unknown kind
+func (k ErrorKind) Code() string {
+	if 0 <= k && int(k) < len(errorCodes) {
+		return errorCodes[k]
+	}
+	return errorCodes[UnknownError]
+}
+
+func (k ErrorKind) String() string {
+	if 0 <= k && int(k) < len(errorKinds) {
+		return errorKinds[k]
+	}
+	return "ErrorKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Severity tells whether a diagnostic stops the input from being run
+type Severity int
+
+// Severities of the diagnostics, the zero Severity is an error
+const (
+	SeverityError   Severity = iota // the input cannot be run
+	SeverityWarning                 // a potential problem that does not stop the input from being run
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "Severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Error is an error found while scanning the input
+type Error struct {
+	Name     string // name of the input
+	Pos      Pos
+	End      Pos // end of the input that caused the error, Pos if it is not known
+	Msg      string
+	Kind     ErrorKind
+	Code     string        // the stable code of the kind of the error, see ErrorKind.Code
+	Severity Severity      // the errors of the lexer are always SeverityError
+	Args     []interface{} // the arguments the message was formatted with
+	Notes    []Note        // secondary messages about other positions of the input
+}
+
+// Note is a secondary message of an error about another position of the input,
+// e.g. where the bracket left open was opened
+type Note struct {
+	Pos  Pos
+	Msg  string
+	Line string // the line of the input at Pos, filled in by PrintError if empty
+}
+
+// String returns the note in the form name:line:col: note - msg, followed by
+// the snippet of its line, name is the name of the input of the error
+func (n Note) String(name string) string {
+	msg := fmt.Sprintf("%s:%s: note - %s", name, n.Pos, n.Msg)
+	if snippet := Snippet(n.Line, n.Pos.Col()); snippet != "" {
+		return msg + "\n" + snippet
+	}
+	return msg
+}
+
+// Error returns the error in the form name:line:col: msg, or in the form
+// name:line:col: warning - msg for a warning
+func (e *Error) Error() string {
+	if e.Severity == SeverityWarning {
+		return fmt.Sprintf("%s:%s: warning - %s", e.Name, e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.Name, e.Pos, e.Msg)
+}
+
+// ErrorList is a list of scanning errors, in the order they were found
+type ErrorList []*Error
+
+// Add appends an error with the given name, position and message to the list
+func (el *ErrorList) Add(name string, pos Pos, msg string) {
+	*el = append(*el, &Error{Name: name, Pos: pos, Msg: msg, Code: UnknownError.Code()})
+}
+
+// Error returns the first error of the list, followed by the number of the
+// other errors if any. It has no side effects, use PrintError to print all the
+// errors with their snippets.
+func (el ErrorList) Error() string {
+	switch len(el) {
+	case 0:
+		return "no errors"
+	case 1:
+		return el[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", el[0], len(el)-1)
+}
+
+// Sort sorts the list by position, the errors at the same position keep the
+// order they were found in
+func (el ErrorList) Sort() {
+	sort.SliceStable(el, func(i, j int) bool { return el[i].Pos < el[j].Pos })
+}
+
+// Dedup removes the errors with the same position and message as the error
+// before them, the list should be sorted first so that all duplicates are
+// adjacent. The list is modified in place and returned.
+func (el ErrorList) Dedup() ErrorList {
+	if len(el) == 0 {
+		return el
+	}
+	out := el[:1]
+	for _, e := range el[1:] {
+		if last := out[len(out)-1]; e.Pos != last.Pos || e.Msg != last.Msg {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// HasErrors reports whether the list holds an error of SeverityError, i.e. one
+// that is not only a warning
+func (el ErrorList) HasErrors() bool {
+	for _, e := range el {
+		if e.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the list as an error, or nil if the list is empty
+func (el ErrorList) Err() error {
+	if len(el) == 0 {
+		return nil
+	}
+	return el
+}
+
+// SourceLine returns the line of the input, counting from 1, without its line
+// ending, or "" if the input has no such line
+func SourceLine(input string, line int) string {
+	if line < 1 {
+		return ""
+	}
+	for ; line > 1; line-- {
+		i := strings.IndexByte(input, '\n')
+		if i < 0 {
+			return ""
+		}
+		input = input[i+1:]
+	}
+	if i := strings.IndexByte(input, '\n'); i >= 0 {
+		input = input[:i]
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(input, "\r"), "\uFEFF")
+}
+
+// Snippet returns the line followed by a caret under the column col (as given
+// by Pos.Col) on the next line, or "" if the line is blank
+func Snippet(line string, col int) string {
+	if strings.TrimSpace(line) == "" {
+		return ""
+	}
+	return line + "\n" + strings.Repeat(" ", VisualCol(line, col, DefaultTabWidth)-1) + "^"
+}
+
+// PrintError prints the error to w, each error of an ErrorList is followed by
+// the line of the input at its position with a caret under its column, then
+// by its notes. Errors of other types are printed as they are, one per line.
+func PrintError(w io.Writer, input string, err error) {
+	switch err := err.(type) {
+	case ErrorList:
+		for _, e := range err {
+			PrintError(w, input, e)
+		}
+	case *Error:
+		fmt.Fprintln(w, err)
+		if snippet := Snippet(SourceLine(input, err.Pos.Line()), err.Pos.Col()); snippet != "" {
+			fmt.Fprintln(w, snippet)
+		}
+		for _, n := range err.Notes {
+			if n.Line == "" {
+				n.Line = SourceLine(input, n.Pos.Line())
+			}
+			fmt.Fprintln(w, n.String(err.Name))
+		}
+	default:
+		fmt.Fprintln(w, err)
+	}
+}
@@ -2,6 +2,8 @@ package token
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -11,15 +13,60 @@ import (
 // https://golang.org/src/text/template/parse/lex.go and partially taken from
 // https://golang.org/src/go/scanner/scanner.go
 
+// Mode is a set of flags that control the behaviour of the lexer
+type Mode uint
+
+// Lexer modes
+const (
+	ScanComments   Mode = 1 << iota // emit COMMENT tokens instead of discarding comments
+	NoSemicolons                    // disable automatic semicolon insertion, requiring explicit ';'
+	NoLegacyOctals                  // report octals written with a leading zero (0755) instead of 0o755 as errors
+)
+
 // Tokenise creates a new scanner for the input string
 func Tokenise(name, input string) *Lexer {
+	return tokenise(name, input, 0, 0)
+}
+
+// TokeniseMode creates a new scanner for the input string with the given mode
+func TokeniseMode(name, input string, mode Mode) *Lexer {
+	return tokenise(name, input, 0, mode)
+}
+
+// TokeniseFile creates a new scanner for the file with the given mode, the
+// positions of the tokens refer to the file within its FileSet
+func TokeniseFile(f *File, mode Mode) *Lexer {
+	return tokenise(f.Name, f.Input, f.index, mode)
+}
+
+// TokeniseAll scans the whole input with the given mode, returning the tokens
+// up to and including EOF. The scan stops at the first error, in which case
+// the tokens before the error are returned along with the error.
+func TokeniseAll(name, input string, mode Mode) ([]Token, ErrorList) {
+	l := TokeniseMode(name, input, mode)
+	defer l.Drain()
+	tkns := make([]Token, 0, len(input)/4) // a rough estimate of the number of tokens
+	var errs ErrorList
+	for {
+		tkn := l.Next()
+		switch tkn.Type {
+		case ERROR:
+			return tkns, append(errs, l.Err())
+		case EOF:
+			return append(tkns, tkn), errs
+		}
+		tkns = append(tkns, tkn)
+	}
+}
+
+func tokenise(name, input string, file uint32, mode Mode) *Lexer {
 	l := &Lexer{
-		Name:    name,
-		Input:   input,
-		tokens:  make(chan Token),
-		line:    1,
-		col:     0,
-		prevCol: 0,
+		Name:        name,
+		Input:       input,
+		tokens:      make(chan Token, tokenBufferSize),
+		mode:        mode,
+		file:        file,
+		lineOffsets: make([]int, 1, strings.Count(input, "\n")+1),
 	}
 	go l.run()
 	return l
@@ -43,43 +90,68 @@ type Lexer struct {
 	Input  string     // string being scanned
 	tokens chan Token // channel of the scanned items
 
-	// current state to track & emit info
-	line    uint32 // 1 + number of newlines seen
-	col     uint32 // 1 + current column number
-	prevCol uint32 // previous column number seen (ensure backup() is correct)
+	// Incomplete is set when the input ends before a bracket, string or comment
+	// is closed, it is only safe to read after the tokens are drained
+	Incomplete bool
+
+	mode  Mode   // flags controlling the scan
+	err   *Error // the error that terminated the scan, if any
+	notes []Note // notes to attach to the next error emitted
+
+	// byte offsets at which each line seen so far starts, used to compute the
+	// line and column of the tokens
+	lineOffsets []int
+	// the line index, byte offset and column of the last position computed,
+	// the columns of the offsets after it on the same line are counted from it
+	// rather than from the start of the line
+	colLine, colOffset, col int
+	file                    uint32 // index of the file being scanned in its FileSet, 0 if none
 
 	// Internal lexer state
-	start        int       // start position of the current token
-	pos          int       // current position
-	runeWidth    int       // runeWidth of the last rune read from input
-	prevTokTyp   Type      // previous Token type used for automatic semicolon insertion
-	bracketStack runeStack // a stack of runes used to keep track of all '(', '[' and '{'
+	start        int          // start position of the current token
+	pos          int          // current position
+	runeWidth    int          // runeWidth of the last rune read from input
+	prevTokTyp   Type         // previous Token type used for automatic semicolon insertion
+	bracketStack bracketStack // a stack of the brackets '(', '[' and '{' left open
 }
 
 const eof = -1
 
-type runeStack []rune
+// tokenBufferSize is the number of tokens the lexer may scan ahead of its
+// client, buffering the tokens saves a goroutine switch per token
+const tokenBufferSize = 64
+
+// bom is the UTF-8 byte order mark, skipped at the start of the input
+const bom = "\uFEFF"
 
-func (rs *runeStack) empty() bool {
-	return len(*rs) == 0
+// bracket is a left bracket and the byte offset in the input it was found at
+type bracket struct {
+	r      rune
+	offset int
 }
 
-// push a rune to the top of the stack
-func (rs *runeStack) push(r rune) {
-	*rs = append(*rs, r)
+type bracketStack []bracket
+
+func (bs *bracketStack) empty() bool {
+	return len(*bs) == 0
 }
 
-// pop removes a rune from the top of the stack, you should always check if
+// push a bracket found at the offset to the top of the stack
+func (bs *bracketStack) push(r rune, offset int) {
+	*bs = append(*bs, bracket{r, offset})
+}
+
+// pop removes a bracket from the top of the stack, you should always check if
 // the stack is empty prior to popping
-func (rs *runeStack) pop() (r rune) {
-	r, *rs = (*rs)[len(*rs)-1], (*rs)[:len(*rs)-1]
+func (bs *bracketStack) pop() (b bracket) {
+	b, *bs = (*bs)[len(*bs)-1], (*bs)[:len(*bs)-1]
 	return
 }
 
 // peek looks at the top of the stack you should always check if the stack is
 // empty prior to peeking
-func (rs *runeStack) peek() rune {
-	return (*rs)[len(*rs)-1]
+func (bs *bracketStack) peek() bracket {
+	return (*bs)[len(*bs)-1]
 }
 
 // next returns the next rune in the input
@@ -92,13 +164,8 @@ func (l *Lexer) next() rune {
 	r, w := utf8.DecodeRuneInString(l.Input[l.pos:])
 	l.runeWidth = w
 	l.pos += l.runeWidth
-	// handle columns and lines seen
-	if r == '\n' {
-		l.line++
-		l.col = 1
-	} else {
-		l.prevCol = l.col
-		l.col++
+	if r == '\n' && l.pos > l.lineOffsets[len(l.lineOffsets)-1] {
+		l.lineOffsets = append(l.lineOffsets, l.pos)
 	}
 	return r
 }
@@ -113,24 +180,58 @@ func (l *Lexer) peek() rune {
 // backup steps back one rune, can only be called once per call of next
 func (l *Lexer) backup() {
 	l.pos -= l.runeWidth
-	l.col = l.prevCol
-	if l.runeWidth == 1 && l.Input[l.pos] == '\n' {
-		l.line--
+}
+
+// position returns the line and column of the byte offset in the input, the
+// offset must not be past the lines seen so far
+func (l *Lexer) position(offset int) Pos {
+	// the offset is usually on the last line seen, otherwise search for the
+	// first line starting after offset, offset is on the line before
+	i := len(l.lineOffsets) - 1
+	if offset < l.lineOffsets[i] {
+		i = sort.SearchInts(l.lineOffsets, offset+1) - 1
+	}
+	if i != l.colLine || offset < l.colOffset || l.col == 0 {
+		l.colLine, l.colOffset, l.col = i, l.lineOffsets[i], 1
 	}
+	l.col += utf8.RuneCountInString(l.Input[l.colOffset:offset])
+	l.colOffset = offset
+	return newPos(l.file, uint32(i+1), uint32(l.col))
 }
 
 // emit passes a Token back to the client
 // this will also update the last seen emitted Token type
-func (l *Lexer) emit(typ Type) {
+func (l *Lexer) emit(typ Type) { l.emitValue(typ, l.Input[l.start:l.pos]) }
+
+// emitValue passes a Token with the given value back to the client, to be used
+// when the value differs from the scanned input, e.g. for quoted strings
+func (l *Lexer) emitValue(typ Type, value string) {
 	l.tokens <- Token{
-		typ,
-		l.Input[l.start:l.pos],
-		newPos(l.line, l.col),
+		Type:   typ,
+		Value:  value,
+		Pos:    l.position(l.start),
+		End:    l.position(l.pos),
+		Offset: l.start,
+		Len:    l.pos - l.start,
 	}
 	l.start = l.pos
 	l.prevTokTyp = typ
 }
 
+// emitComment passes the pending comment back to the client if comments are
+// scanned, otherwise it is discarded. Comments are transparent to automatic
+// semicolon insertion.
+func (l *Lexer) emitComment() {
+	if l.mode&ScanComments == 0 {
+		l.ignore()
+		return
+	}
+	prevTokTyp := l.prevTokTyp
+	// the '\r' of a CRLF line ending is not part of a line comment
+	l.emitValue(COMMENT, strings.TrimSuffix(l.Input[l.start:l.pos], "\r"))
+	l.prevTokTyp = prevTokTyp
+}
+
 // ignore skips over the pending input before this point
 func (l *Lexer) ignore() { l.start = l.pos }
 
@@ -150,25 +251,74 @@ func (l *Lexer) acceptRun(valid string) {
 	l.backup()
 }
 
-// errorf emits an error Token and terminates the scan by passing back a nil
-// pointer that will be the next state, terminating l.nextToken.
-func (l *Lexer) errorf(format string, args ...interface{}) stateFunc {
-	l.tokens <- Token{
-		ERROR,
-		fmt.Sprintf(format, args...),
-		newPos(l.line, l.col),
-	}
+// errorf records the error of the given kind and emits it as an error Token,
+// terminating the scan by passing back a nil pointer that will be the next
+// state, terminating l.nextToken.
+func (l *Lexer) errorf(kind ErrorKind, format string, args ...interface{}) stateFunc {
+	tkn := Token{
+		Type:   ERROR,
+		Value:  fmt.Sprintf(format, args...),
+		Pos:    l.position(l.start),
+		End:    l.position(l.pos),
+		Offset: l.start,
+		Len:    l.pos - l.start,
+	}
+	l.err = &Error{Name: l.Name, Pos: tkn.Pos, End: tkn.End, Msg: tkn.Value, Kind: kind, Code: kind.Code(), Args: args,
+		Notes: l.notes}
+	l.notes = nil
+	l.tokens <- tkn
 	return nil
 }
 
+// note adds a note at the byte offset to the next error emitted
+func (l *Lexer) note(offset int, format string, args ...interface{}) {
+	l.notes = append(l.notes, Note{Pos: l.position(offset), Msg: fmt.Sprintf(format, args...)})
+}
+
+// incompletef marks the input as incomplete before emitting an error Token
+// through errorf, to be used when the input ends unexpectedly
+func (l *Lexer) incompletef(kind ErrorKind, format string, args ...interface{}) stateFunc {
+	l.Incomplete = true
+	return l.errorf(kind, format, args...)
+}
+
+// Err returns the error that terminated the scan, or nil if there is none. It
+// is safe to read once the ERROR token has been received.
+func (l *Lexer) Err() *Error { return l.err }
+
 // run starts the state machine for the Lexer
 func (l *Lexer) run() {
+	l.skipBOM()
+	l.skipShebang()
 	for state := lexCode; state != nil; {
 		state = state(l)
 	}
 	close(l.tokens)
 }
 
+// skipBOM skips a leading UTF-8 byte order mark, the first line then starts
+// after the mark so that columns are counted from the first character
+func (l *Lexer) skipBOM() {
+	if strings.HasPrefix(l.Input, bom) {
+		l.pos = len(bom)
+		l.ignore()
+		l.lineOffsets[0] = l.pos
+	}
+}
+
+// skipShebang skips the interpreter directive ("#!/usr/bin/env went") on the first
+// line of the input so that scripts may be executable on Unix, the newline
+// ending the line is kept to be scanned as usual
+func (l *Lexer) skipShebang() {
+	if !strings.HasPrefix(l.Input[l.pos:], "#!") {
+		return
+	}
+	for r := l.peek(); !isEndOfLine(r) && r != eof; r = l.peek() {
+		l.next()
+	}
+	l.ignore()
+}
+
 // atIdentifierTerminator reports whether the input is at valid
 // termination character to appear after an identifier
 func (l *Lexer) atIdentifierTerminator() bool {
@@ -180,6 +330,10 @@ func (l *Lexer) atIdentifierTerminator() bool {
 	case
 		eof, '=', // EOF character and assignment/declaration ('='), or equality check ('==')
 		'.', ',', // DOT ('.') to denote .property, or commas
+		':',      // COLON (':') after a map key or a slice bound
+		';',      // SEMICOLON (';') ending a statement
+		'<', '>', // comparisons ('<', '<=', '>', '>=')
+		'!',      // inequality check ('!=')
 		'|', '&', // OR ('||'), or AND ('&&')
 		'(', ')', '[', ']', '{', '}', // Parenthesis, square, curly and normal
 		'+', '-', '/', '*', '%': // Math operator signs, or start of a comment ('//', '/*')
@@ -209,21 +363,17 @@ func init() {
 		';': func(l *Lexer) stateFunc { l.emit(SEMICOLON); return lexCode },
 		',': func(l *Lexer) stateFunc { l.emit(COMMA); return lexCode },
 		'|': func(l *Lexer) stateFunc {
-			r := l.Input[l.start]
-			if l.next() == '|' {
-				l.emit(LOGICALOR)
-			} else {
-				l.errorf("expected Token %#U", r)
+			if !l.accept("|") {
+				return l.errorf(UnexpectedChar, "expected Token %#U", '|')
 			}
+			l.emit(LOGICALOR)
 			return lexCode
 		},
 		'&': func(l *Lexer) stateFunc {
-			r := l.Input[l.start]
-			if l.next() == '&' {
-				l.emit(LOGICALAND)
-			} else {
-				l.errorf("expected Token %#U", r)
+			if !l.accept("&") {
+				return l.errorf(UnexpectedChar, "expected Token %#U", '&')
 			}
+			l.emit(LOGICALAND)
 			return lexCode
 		},
 		'.': lexDot,
@@ -233,9 +383,9 @@ func init() {
 		'`':  lexRawString,
 
 		// brackets
-		'(': func(l *Lexer) stateFunc { l.emit(LROUND); l.bracketStack.push('('); return lexCode },
-		'[': func(l *Lexer) stateFunc { l.emit(LSQUARE); l.bracketStack.push('['); return lexCode },
-		'{': func(l *Lexer) stateFunc { l.emit(LCURLY); l.bracketStack.push('{'); return lexCode },
+		'(': func(l *Lexer) stateFunc { l.bracketStack.push('(', l.start); l.emit(LROUND); return lexCode },
+		'[': func(l *Lexer) stateFunc { l.bracketStack.push('[', l.start); l.emit(LSQUARE); return lexCode },
+		'{': func(l *Lexer) stateFunc { l.bracketStack.push('{', l.start); l.emit(LCURLY); return lexCode },
 		')': lexRightBracket,
 		']': lexRightBracket,
 		'}': lexRightBracket,
@@ -279,15 +429,16 @@ func lexCode(l *Lexer) stateFunc {
 		l.backup()
 		return lexIdentifier
 	default:
-		return l.errorf("unrecognised character in code: %#U", r)
+		return l.errorf(UnexpectedChar, "unrecognised character in code: %#U", r)
 	}
 }
 
 // lexEOF emits the EOF Token and handles the termination of the main lexCode loop
 func lexEOF(l *Lexer) stateFunc {
 	if !l.bracketStack.empty() {
-		r := l.bracketStack.pop()
-		return l.errorf("unclosed left bracket: %#U", r)
+		b := l.bracketStack.pop()
+		l.note(b.offset, "%c opened here", b.r)
+		return l.incompletef(UnclosedBracket, "unclosed left bracket: %#U", b.r)
 	}
 	l.emit(EOF)
 	return nil
@@ -322,9 +473,13 @@ Loop:
 		}
 	}
 	switch l.prevTokTyp {
-	case NAME, STR, FALSE,
+	case NAME, STR, CHAR, FALSE,
 		TRUE, INT, FLOAT, BREAK, CONT, RETURN,
 		RROUND, RSQUARE, RCURLY:
+		if l.mode&NoSemicolons != 0 {
+			l.ignore()
+			break
+		}
 		l.emit(SEMICOLON)
 	default:
 		l.ignore() // do not count the spaces as the next() already adds
@@ -334,47 +489,151 @@ Loop:
 
 // lexQuotedString scans a quoted string, can be escaped using the '\' character
 func lexQuotedString(l *Lexer) stateFunc {
-	l.ignore() // ignore the opening quote
+	if strings.HasPrefix(l.Input[l.pos:], "''") {
+		l.next()
+		l.next()
+		return lexTripleQuotedString
+	}
 Loop:
 	for {
 		switch l.next() {
 		case '\\': // single '\' character as escape character
 			if r := l.next(); r == '\n' || r == eof {
-				return l.errorf("unterminated quoted string")
+				return l.errorf(UnterminatedString, "unterminated quoted string")
+			}
+		case eof:
+			return l.incompletef(UnterminatedString, "unterminated quoted string")
+		case '\'':
+			break Loop
+		}
+	}
+	return l.emitQuoted(l.Input[l.start+1:l.pos-1], false) // the value excludes the quotes
+}
+
+// lexTripleQuotedString scans a string delimited by three single quotes on each
+// side that may span multiple lines, the opening quotes have already been consumed
+func lexTripleQuotedString(l *Lexer) stateFunc {
+	for {
+		switch l.next() {
+		case '\\':
+			if l.next() == eof {
+				return l.incompletef(UnterminatedString, "unterminated triple-quoted string")
+			}
+		case eof:
+			return l.incompletef(UnterminatedString, "unterminated triple-quoted string")
+		case '\'':
+			if strings.HasPrefix(l.Input[l.pos:], "''") {
+				l.next()
+				l.next()
+				return l.emitQuoted(l.Input[l.start+3:l.pos-3], true) // the value excludes the quotes
+			}
+		}
+	}
+}
+
+// emitQuoted emits the quoted string with its escape sequences processed, or an
+// error if it has an invalid escape sequence
+func (l *Lexer) emitQuoted(quoted string, multiline bool) stateFunc {
+	value, err := unquote(quoted, multiline)
+	if err != nil {
+		return l.errorf(InvalidEscape, "%s in string", err)
+	}
+	l.emitValue(STR, value)
+	return lexCode
+}
+
+// lexChar scans a character literal (c'a'), the prefix and opening quote have
+// already been consumed
+func lexChar(l *Lexer) stateFunc {
+Loop:
+	for {
+		switch l.next() {
+		case '\\':
+			if r := l.next(); r == '\n' || r == eof {
+				return l.errorf(UnterminatedChar, "unterminated character literal")
 			}
+		case '\n', eof:
+			return l.errorf(UnterminatedChar, "unterminated character literal")
 		case '\'':
-			l.backup() // move back before the closing quote
 			break Loop
 		}
 	}
-	l.emit(STR)
-	l.next()
-	l.ignore() // now consume and ignore the closing quote
+	quoted := l.Input[l.start+2 : l.pos-1] // the value excludes the prefix and quotes
+	value, err := unquote(quoted, false)
+	if err != nil {
+		return l.errorf(InvalidEscape, "%s in character literal", err)
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return l.errorf(InvalidChar, "character literal must hold exactly one character: %q", l.Input[l.start:l.pos])
+	}
+	l.emitValue(CHAR, value)
 	return lexCode
 }
 
-// lexRawString scans a raw string delimited by '`' character
+// unquote processes the escape sequences of a quoted string, in a multiline
+// string a '\' at the end of a line joins the line with the next one
+func unquote(quoted string, multiline bool) (string, error) {
+	if !strings.ContainsAny(quoted, "\\\r") {
+		return quoted, nil // nothing to process, avoid copying the string
+	}
+	var value strings.Builder
+	value.Grow(len(quoted))
+	for s := quoted; len(s) > 0; {
+		if multiline && strings.HasPrefix(s, "\r\n") {
+			// CRLF line endings are normalised to '\n'
+			value.WriteByte('\n')
+			s = s[2:]
+			continue
+		}
+		if s[0] != '\\' {
+			// the bytes are copied as they are, so that invalid UTF-8 is kept
+			// as in a string without escape sequences
+			_, w := utf8.DecodeRuneInString(s)
+			value.WriteString(s[:w])
+			s = s[w:]
+			continue
+		}
+		if multiline && strings.HasPrefix(s, "\\\n") {
+			s = s[2:]
+			continue
+		}
+		if multiline && strings.HasPrefix(s, "\\\r\n") {
+			s = s[3:]
+			continue
+		}
+		r, _, tail, err := strconv.UnquoteChar(s, '\'')
+		if err != nil {
+			n := 2
+			if len(s) < n {
+				n = len(s)
+			}
+			return "", fmt.Errorf("invalid escape sequence %q", s[:n])
+		}
+		value.WriteRune(r)
+		s = tail
+	}
+	return value.String(), nil
+}
+
+// lexRawString scans a raw string delimited by '`' character, a backtick within
+// the string is written as two consecutive backticks
 func lexRawString(l *Lexer) stateFunc {
-	l.ignore() // ignore the opening quote
-	startLine := l.line
-	startCol := l.col
 Loop:
 	for {
 		switch l.next() {
 		case eof:
-			// restore line and col number to the location of the opening quote
-			// will error out, okay to overwrite l.line
-			l.line = startLine
-			l.col = startCol
-			return l.errorf("Unterminated raw string")
+			return l.incompletef(UnterminatedString, "Unterminated raw string")
 		case '`':
-			l.backup() // move back before the closing quote
-			break Loop
+			if l.peek() != '`' {
+				break Loop // closing quote
+			}
+			l.next() // doubled backtick
 		}
 	}
-	l.emit(STR)
-	l.next()
-	l.ignore() // now consume and ignore the closing quote
+	// the value excludes the quotes, carriage returns are discarded so that CRLF
+	// line endings are normalised to '\n'
+	value := strings.Replace(l.Input[l.start+1:l.pos-1], "``", "`", -1)
+	l.emitValue(STR, strings.Replace(value, "\r", "", -1))
 	return lexCode
 }
 
@@ -382,11 +641,9 @@ Loop:
 // to access property
 func lexDot(l *Lexer) stateFunc {
 	// Special lookahead for ".property" so we don't break l.backup()
-	if int(l.pos) < len(l.Input) {
-		if r := l.Input[l.pos]; r < '0' || r > '9' { // if its not a number
-			l.emit(DOT)
-			return lexCode // emit the dot '.' and go back to lexCode
-		}
+	if int(l.pos) >= len(l.Input) || l.Input[l.pos] < '0' || l.Input[l.pos] > '9' { // if its not a number
+		l.emit(DOT)
+		return lexCode // emit the dot '.' and go back to lexCode
 	}
 	return lexNumber
 }
@@ -444,10 +701,28 @@ func lexOperator(l *Lexer) stateFunc {
 	return lexCode
 }
 
-// scanSignificand scans for all numbers (of the given base) up to a non-number
-func (l *Lexer) scanSignificand(base int) {
-	for digitValue(l.peek()) < base {
-		l.next()
+// scanSignificand scans for all numbers (of the given base) up to a non-number,
+// the numbers may be separated by underscores for readability, it reports
+// whether every underscore is placed between two numbers or right after the
+// base prefix, as in 0x_ff
+func (l *Lexer) scanSignificand(base int) (ok bool) {
+	ok = true
+	for {
+		switch r := l.peek(); {
+		case r == '_':
+			afterPrefix := l.pos-l.start == 2 && strings.ContainsRune("xXbBoO", rune(l.Input[l.pos-1]))
+			if l.pos == l.start || !afterPrefix && digitValue(rune(l.Input[l.pos-1])) >= base {
+				ok = false // leading or double underscore
+			}
+			l.next()
+			if digitValue(l.peek()) >= base {
+				ok = false // trailing underscore
+			}
+		case digitValue(r) < base:
+			l.next()
+		default:
+			return ok
+		}
 	}
 }
 
@@ -456,52 +731,90 @@ func (l *Lexer) scanSignificand(base int) {
 func lexNumber(l *Lexer) stateFunc {
 	l.backup() // backup to see the '.' or numerical runes
 	emitTyp := INT
+	sepOK := true // whether all underscores separating numbers are well placed
 	// Seen decimal point --> is a float (i.e. .1234E10 for example)
 	if l.peek() == '.' {
 		goto FRACTION
 	}
-	// Leading 0 ==> hexadecimal ("0x"/"0X") or octal 0
-	if l.peek() == '0' {
+	// Leading 0 ==> hexadecimal ("0x"/"0X"), binary ("0b"/"0B") or octal ("0o"/"0O"/0)
+	if l.accept("0") {
 		if l.accept("xX") {
 			// hexadecimal int
-			l.scanSignificand(16)
+			sepOK = l.scanSignificand(16)
 			if l.pos-l.start <= 2 {
 				// Only scanned "0x" or "0X"
-				return l.errorf("illegal hexadecimal number: %q", l.Input[l.start:l.pos])
+				return l.errorf(InvalidNumber, "illegal hexadecimal number: %q", l.Input[l.start:l.pos])
+			}
+		} else if l.accept("bB") {
+			// binary int
+			sepOK = l.scanSignificand(2)
+			if digitValue(l.peek()) < 10 {
+				// error, illegal binary digit
+				l.scanSignificand(10)
+				return l.errorf(InvalidNumber, "illegal binary number: %q", l.Input[l.start:l.pos])
+			}
+			if l.pos-l.start <= 2 {
+				// Only scanned "0b" or "0B"
+				return l.errorf(InvalidNumber, "illegal binary number: %q", l.Input[l.start:l.pos])
+			}
+		} else if l.accept("oO") {
+			// octal int
+			sepOK = l.scanSignificand(8)
+			if digitValue(l.peek()) < 10 {
+				// error, illegal octal digit
+				l.scanSignificand(10)
+				return l.errorf(InvalidNumber, "illegal octal number: %q", l.Input[l.start:l.pos])
+			}
+			if l.pos-l.start <= 2 {
+				// Only scanned "0o" or "0O"
+				return l.errorf(InvalidNumber, "illegal octal number: %q", l.Input[l.start:l.pos])
 			}
 		} else {
-			l.scanSignificand(8)
+			// legacy octal int, or a lone 0
+			sepOK = l.scanSignificand(8)
 			if l.accept("89") {
 				// error, illegal octal int/float
 				l.scanSignificand(10)
-				return l.errorf("illegal octal number: %q", l.Input[l.start:l.pos])
+				return l.errorf(InvalidNumber, "illegal octal number: %q", l.Input[l.start:l.pos])
 			}
 			if r := l.peek(); r == '.' || r == 'e' || r == 'E' {
 				// NOTE: ".eEi" including imaginary number, if we wanna support it in the future
 				// Octal float
 				goto FRACTION
 			}
+			if l.pos-l.start > 1 && l.mode&NoLegacyOctals != 0 {
+				return l.errorf(InvalidNumber, "octal number with a leading zero, use the 0o prefix: %q", l.Input[l.start:l.pos])
+			}
 		}
-		l.emit(emitTyp)
-		return lexCode
+		return l.emitNumber(emitTyp, sepOK)
 	}
 	// Decimal integer/float
-	l.scanSignificand(10)
+	sepOK = l.scanSignificand(10)
 FRACTION: // handles all other floating point lexing
 	if l.accept(".") {
 		emitTyp = FLOAT
-		l.scanSignificand(10)
+		sepOK = l.scanSignificand(10) && sepOK
 	}
 	if l.accept("eE") {
 		emitTyp = FLOAT
 		l.accept("+-")
 		if digitValue(l.peek()) < 10 {
-			l.scanSignificand(10)
+			sepOK = l.scanSignificand(10) && sepOK
 		} else {
-			return l.errorf("Illegal floating-point exponent: %q", l.Input[l.start:l.pos])
+			return l.errorf(InvalidNumber, "Illegal floating-point exponent: %q", l.Input[l.start:l.pos])
 		}
 	}
-	l.emit(emitTyp)
+	return l.emitNumber(emitTyp, sepOK)
+}
+
+// emitNumber emits the scanned number with its underscores stripped, or an
+// error if the underscores are misplaced
+func (l *Lexer) emitNumber(typ Type, sepOK bool) stateFunc {
+	number := l.Input[l.start:l.pos]
+	if !sepOK {
+		return l.errorf(InvalidNumber, "'_' must separate successive digits: %q", number)
+	}
+	l.emitValue(typ, strings.Replace(number, "_", "", -1))
 	return lexCode
 }
 
@@ -515,8 +828,15 @@ Loop:
 		default:
 			l.backup()
 			word := l.Input[l.start:l.pos]
+			if word == "c" && r == '\'' {
+				l.next() // consume the opening quote
+				return lexChar
+			}
 			if !l.atIdentifierTerminator() {
-				return l.errorf("Bad character: %#U", r)
+				// the error is at the character, not at the identifier before it
+				l.start = l.pos
+				l.next()
+				return l.errorf(UnexpectedChar, "Bad character: %#U", r)
 			}
 			switch {
 			case keywordBegin+1 <= keywords[word] && keywords[word] < keywordEnd:
@@ -543,15 +863,16 @@ func lexRightBracket(l *Lexer) stateFunc {
 	l.backup()
 	r := l.next() // backup to capture r
 	if l.bracketStack.empty() {
-		return l.errorf("unexpected right bracket %#U", r)
-	} else if toCheck := l.bracketStack.pop(); toCheck != bracketMap[r] {
-		return l.errorf("unexpected right bracket %#U", r)
+		return l.errorf(UnexpectedBracket, "unexpected right bracket %#U", r)
+	} else if b := l.bracketStack.pop(); b.r != bracketMap[r] {
+		l.note(b.offset, "%c opened here", b.r)
+		return l.errorf(UnexpectedBracket, "unexpected right bracket %#U", r)
 	}
 	switch r {
 	case ')':
 		l.emit(RROUND)
 	case '}':
-		if l.prevTokTyp != SEMICOLON {
+		if l.prevTokTyp != SEMICOLON && l.mode&NoSemicolons == 0 {
 			l.backup() // backup to not accidentally emit the right curly bracket
 			l.emit(SEMICOLON)
 			l.next() // advance forward to contain the right curly bracket again
@@ -563,22 +884,21 @@ func lexRightBracket(l *Lexer) stateFunc {
 	return lexCode
 }
 
-// lexSinglelineComment scans a single line comment ('//') and discards it
+// lexSinglelineComment scans a single line comment ('//') up to the end of the
+// line, the newline is left to be scanned for automatic semicolon insertion
 func lexSinglelineComment(l *Lexer) stateFunc {
-	for {
-		if r := l.next(); isEndOfLine(r) || r == eof {
-			break
-		}
+	for r := l.peek(); !isEndOfLine(r) && r != eof; r = l.peek() {
+		l.next()
 	}
-	l.ignore()
+	l.emitComment()
 	return lexCode
 }
 
-// lexMultilineComment scans for a multiline comment block ('/*', '*/') and discards it
+// lexMultilineComment scans for a multiline comment block ('/*', '*/')
 // The left comment marker ('/*') has already been consumed
 func lexMultilineComment(l *Lexer) stateFunc {
 	if i := strings.Index(l.Input[l.pos:], "*/"); i < 0 {
-		return l.errorf("Multiline comment is not closed")
+		return l.incompletef(UnterminatedComment, "Multiline comment is not closed")
 	}
 	var left, right rune
 	right = l.next()
@@ -588,7 +908,7 @@ func lexMultilineComment(l *Lexer) stateFunc {
 			break
 		}
 	}
-	l.ignore()
+	l.emitComment()
 	return lexCode
 }
 
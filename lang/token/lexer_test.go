@@ -1,7 +1,10 @@
 package token
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 // makeToken creates a Token given a Type and a string denoting its value
@@ -101,6 +104,27 @@ var lexTests = []lexTestcase{
 		`,
 		[]Token{makeName("x"), tknAss, makeToken(FLOAT, "3.123"), tknSemi, tknEOF},
 	},
+	{"shebang",
+		"#!/usr/bin/env went\nx = 1",
+		[]Token{makeName("x"), tknAss, makeToken(INT, "1"), tknEOF},
+	},
+	{"shebang only",
+		"#!/usr/bin/env went",
+		[]Token{tknEOF},
+	},
+	{"byte order mark",
+		"\uFEFF#!/usr/bin/env went\r\nx",
+		[]Token{makeName("x"), tknEOF},
+	},
+	{"crlf line endings",
+		"x = 1\r\ny\r\n\r\nz",
+		[]Token{makeName("x"), tknAss, makeToken(INT, "1"), tknSemi,
+			makeName("y"), tknSemi, makeName("z"), tknEOF},
+	},
+	{"crlf in multiline strings",
+		"`a\r\nb` '''c\r\nd\\\r\ne'''",
+		[]Token{makeToken(STR, "a\nb"), makeToken(STR, "c\nde"), tknEOF},
+	},
 	{"division parse",
 		`x = 1.2 /* 2 *// 2
 		`,
@@ -109,9 +133,9 @@ var lexTests = []lexTestcase{
 		},
 	},
 	{"keywords",
-		"func if else elif for null false true while return break continue in var",
+		"func if else elif for null false true while return break continue in var yield",
 		[]Token{tknFuncDef, tknIf, tknElse, tknElseIf, tknFor, tknNull, tknF, tknT,
-			tknWhile, tknReturn, tknBreak, tknCont, tknIn, tknVar, tknEOF,
+			tknWhile, tknReturn, tknBreak, tknCont, tknIn, tknVar, makeToken(YIELD, "yield"), tknEOF,
 		},
 	},
 	{"arithmetic operators",
@@ -135,7 +159,109 @@ var lexTests = []lexTestcase{
 			tknLR, tknRR, tknEOF,
 		},
 	},
+	{"dot at the end of the input",
+		"x.",
+		[]Token{makeName("x"), tknDot, tknEOF},
+	},
+	{"names before a colon",
+		"{true: x[y:]}",
+		[]Token{makeToken(LCURLY, "{"), makeToken(TRUE, "true"), tknColon, makeName("x"),
+			makeToken(LSQUARE, "["), makeName("y"), tknColon, makeToken(RSQUARE, "]"),
+			tknSemi, makeToken(RCURLY, "}"), tknEOF},
+	},
+	{"names before a semicolon, comparison or not",
+		"a;true;i<n;i<=n;i>n;i>=n;a!=b",
+		[]Token{makeName("a"), tknSemi, makeToken(TRUE, "true"), tknSemi,
+			makeName("i"), tknSm, makeName("n"), tknSemi, makeName("i"), tknSmEq, makeName("n"), tknSemi,
+			makeName("i"), tknGr, makeName("n"), tknSemi, makeName("i"), tknGrEq, makeName("n"), tknSemi,
+			makeName("a"), tknNEql, makeName("b"), tknEOF},
+	},
+	{"integer literals",
+		"0 017 0x1F 0XaB 0b1010 0B1 0o755 0O1",
+		[]Token{makeToken(INT, "0"), makeToken(INT, "017"), makeToken(INT, "0x1F"),
+			makeToken(INT, "0XaB"), makeToken(INT, "0b1010"), makeToken(INT, "0B1"),
+			makeToken(INT, "0o755"), makeToken(INT, "0O1"), tknEOF},
+	},
+	{"digit separators",
+		"1_000_000 0xFF_FF 0b1_0 0_17 1_0.0_1e1_0 0x_ff 0b_1 0O_7",
+		[]Token{makeToken(INT, "1000000"), makeToken(INT, "0xFFFF"), makeToken(INT, "0b10"),
+			makeToken(INT, "017"), makeToken(FLOAT, "10.01e10"), makeToken(INT, "0xff"),
+			makeToken(INT, "0b1"), makeToken(INT, "0O7"), tknEOF},
+	},
+	{"raw strings",
+		"`a``b` `` ````",
+		[]Token{makeToken(STR, "a`b"), makeToken(STR, ""), makeToken(STR, "`"), tknEOF},
+	},
+	{"quoted strings",
+		`'a\'b\n\t\\' 'é\x41' ''`,
+		[]Token{makeToken(STR, "a'b\n\t\\"), makeToken(STR, "éA"), makeToken(STR, ""), tknEOF},
+	},
+	{"triple-quoted strings",
+		"'''it's\n  two \\\nlines\\t''' ''''''",
+		[]Token{makeToken(STR, "it's\n  two lines\t"), makeToken(STR, ""), tknEOF},
+	},
+	{"character literals",
+		`c'a' c'é' c'\n' c'\''`,
+		[]Token{makeToken(CHAR, "a"), makeToken(CHAR, "é"), makeToken(CHAR, "\n"),
+			makeToken(CHAR, "'"), tknEOF},
+	},
 	// Error Test Cases
+	{"illegal binary digit",
+		"0b102",
+		[]Token{makeError(`illegal binary number: "0b102"`)},
+	},
+	{"binary prefix only",
+		"0b",
+		[]Token{makeError(`illegal binary number: "0b"`)},
+	},
+	{"trailing digit separator",
+		"1_",
+		[]Token{makeError(`'_' must separate successive digits: "1_"`)},
+	},
+	{"double digit separator",
+		"1__0",
+		[]Token{makeError(`'_' must separate successive digits: "1__0"`)},
+	},
+	{"digit separator after prefix only",
+		"0x_",
+		[]Token{makeError(`'_' must separate successive digits: "0x_"`)},
+	},
+	{"double digit separator after prefix",
+		"0b__1",
+		[]Token{makeError(`'_' must separate successive digits: "0b__1"`)},
+	},
+	{"digit separator before fraction",
+		"1_.5",
+		[]Token{makeError(`'_' must separate successive digits: "1_.5"`)},
+	},
+	{"illegal octal digit",
+		"0o78",
+		[]Token{makeError(`illegal octal number: "0o78"`)},
+	},
+	{"octal prefix only",
+		"0o",
+		[]Token{makeError(`illegal octal number: "0o"`)},
+	},
+	{"invalid escape sequence",
+		`'\q'`,
+		[]Token{makeError(`invalid escape sequence "\\q" in string`)},
+	},
+	{"unterminated triple-quoted string",
+		"'''a\n''",
+		[]Token{makeError("unterminated triple-quoted string")},
+	},
+	{"empty character literal",
+		`c''`,
+		[]Token{makeError(`character literal must hold exactly one character: "c''"`)},
+	},
+	{"character literal with many characters",
+		`c'ab'`,
+		[]Token{makeError(`character literal must hold exactly one character: "c'ab'"`)},
+	},
+	{"hexadecimal prefix only",
+		"0x",
+		[]Token{makeError(`illegal hexadecimal number: "0x"`)},
+	},
 	{"single | error",
 		"x | y",
 		[]Token{makeName("x"), makeError(`expected Token U+007C '|'`)},
@@ -144,6 +270,26 @@ var lexTests = []lexTestcase{
 		"x & y",
 		[]Token{makeName("x"), makeError(`expected Token U+0026 '&'`)},
 	},
+	{"single | at end of input",
+		"x |",
+		[]Token{makeName("x"), makeError(`expected Token U+007C '|'`)},
+	},
+	{"unterminated quoted string at end of input",
+		"x = 'abc",
+		[]Token{makeName("x"), tknAss, makeError("unterminated quoted string")},
+	},
+	{"unterminated raw string at end of input",
+		"`abc",
+		[]Token{makeError("Unterminated raw string")},
+	},
+	{"unterminated character literal at end of input",
+		"c'",
+		[]Token{makeError("unterminated character literal")},
+	},
+	{"invalid utf-8",
+		"x\xff",
+		[]Token{makeError("Bad character: U+FFFD '\uFFFD'")},
+	},
 	{"typo right bracket )",
 		"x + ) y",
 		[]Token{makeName("x"), tknPlus, makeError(`unexpected right bracket U+0029 ')'`)},
@@ -180,6 +326,10 @@ var lexTests = []lexTestcase{
 		/`,
 		[]Token{makeError("Multiline comment is not closed")},
 	},
+	{"line comment before newline",
+		"x // comment\ny",
+		[]Token{makeName("x"), tknSemi, makeName("y"), tknEOF},
+	},
 }
 
 func TestLex(t *testing.T) {
@@ -191,11 +341,50 @@ func TestLex(t *testing.T) {
 	}
 }
 
+// FuzzLex checks that the lexer terminates on any input with a single EOF or
+// ERROR token, that the tokens lie within the input and that their positions
+// are consistent
+func FuzzLex(f *testing.F) {
+	for _, tc := range lexTests {
+		f.Add(tc.input)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		l := TokeniseMode("fuzz", input, ScanComments)
+		var prev Token
+		// every token but the semicolons and EOF consumes some input
+		for n := 0; ; n++ {
+			if n > 2*len(input)+2 {
+				t.Fatalf("%q: too many tokens", input)
+			}
+			tkn := l.Next()
+			if tkn.Offset < 0 || tkn.Len < 0 || tkn.Offset+tkn.Len > len(input) {
+				t.Fatalf("%q: %v lies outside of the input", input, tkn)
+			}
+			if tkn.End < tkn.Pos {
+				t.Fatalf("%q: %v ends at %s before it starts at %s", input, tkn, tkn.End, tkn.Pos)
+			}
+			if tkn.Pos < prev.Pos || tkn.Offset < prev.Offset {
+				t.Fatalf("%q: %v at %s starts before %v at %s", input, tkn, tkn.Pos, prev, prev.Pos)
+			}
+			prev = tkn
+			if tkn.Type == EOF || tkn.Type == ERROR {
+				break
+			}
+		}
+		if _, ok := <-l.tokens; ok {
+			t.Fatalf("%q: token emitted after EOF or ERROR", input)
+		}
+	})
+}
+
 // Helper Methods to check equality for tests and collect tokens
 
 // collect gathers the emitted items into a Token slice
-func collect(tc *lexTestcase) (tkns []Token) {
-	l := Tokenise(tc.name, tc.input)
+func collect(tc *lexTestcase) (tkns []Token) { return collectMode(tc, 0) }
+
+// collectMode gathers the items emitted in the given mode into a Token slice
+func collectMode(tc *lexTestcase, mode Mode) (tkns []Token) {
+	l := TokeniseMode(tc.name, tc.input, mode)
 	for {
 		tkn := l.Next()
 		tkns = append(tkns, tkn)
@@ -222,3 +411,347 @@ func equal(tknLst1, tknLst2 []Token, checkPos bool) bool {
 	}
 	return true
 }
+
+func TestSource(t *testing.T) {
+	input := "x += 'a\\'b' /* comment */\n`raw` 1.5e3"
+	expected := []string{"x", "+=", `'a\'b'`, "\n", "`raw`", "1.5e3", ""}
+	l := Tokenise("source", input)
+	for _, want := range expected {
+		tkn := l.Next()
+		if got := tkn.Source(input); got != want {
+			t.Errorf("%v: got source %q, expected %q", tkn, got, want)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	input := "x >= 'é'\n\n  `a\nb` y '''\n''' z"
+	expected := []struct{ pos, end string }{
+		{"1:1", "1:2"}, // x
+		{"1:3", "1:5"}, // >=
+		{"1:6", "1:9"}, // 'é'
+		{"1:9", "3:1"}, // ;
+		{"3:3", "4:3"}, // `a\nb`
+		{"4:4", "4:5"}, // y
+		{"4:6", "5:4"}, // '''\n'''
+		{"5:5", "5:6"}, // z
+	}
+	l := Tokenise("positions", input)
+	for _, want := range expected {
+		tkn := l.Next()
+		if tkn.Pos.String() != want.pos || tkn.End.String() != want.end {
+			t.Errorf("%v: got %s-%s, expected %s-%s", tkn, tkn.Pos, tkn.End, want.pos, want.end)
+		}
+	}
+}
+
+func TestTokeniseAll(t *testing.T) {
+	tkns, errs := TokeniseAll("all", "x = 1", 0)
+	expected := []Token{makeName("x"), tknAss, makeToken(INT, "1"), tknEOF}
+	if !equal(tkns, expected, false) || errs.Err() != nil {
+		t.Errorf("got %v, %v, expected %v, no errors", tkns, errs, expected)
+	}
+	tkns, errs = TokeniseAll("all", "x = 1 |", 0)
+	expected = expected[:len(expected)-1]
+	if !equal(tkns, expected, false) || len(errs) != 1 ||
+		errs.Error() != "all:1:7: expected Token U+007C '|'" {
+		t.Errorf("got %v, %v, expected %v and an error", tkns, errs, expected)
+	}
+}
+
+// lexErrors holds the code and the position of the error of each negative
+// case of lexTests
+var lexErrors = map[string]string{
+	"illegal binary digit":                           "W1009 1:1",
+	"binary prefix only":                             "W1009 1:1",
+	"trailing digit separator":                       "W1009 1:1",
+	"double digit separator":                         "W1009 1:1",
+	"digit separator after prefix only":              "W1009 1:1",
+	"double digit separator after prefix":            "W1009 1:1",
+	"digit separator before fraction":                "W1009 1:1",
+	"illegal octal digit":                            "W1009 1:1",
+	"octal prefix only":                              "W1009 1:1",
+	"invalid escape sequence":                        "W1007 1:1",
+	"unterminated triple-quoted string":              "W1001 1:1",
+	"empty character literal":                        "W1008 1:1",
+	"character literal with many characters":         "W1008 1:1",
+	"hexadecimal prefix only":                        "W1009 1:1",
+	"single | error":                                 "W1004 1:3",
+	"single & error":                                 "W1004 1:3",
+	"single | at end of input":                       "W1004 1:3",
+	"unterminated quoted string at end of input":     "W1001 1:5",
+	"unterminated raw string at end of input":        "W1001 1:1",
+	"unterminated character literal at end of input": "W1002 1:1",
+	"invalid utf-8":                                  "W1004 1:2",
+	"typo right bracket )":                           "W1005 1:5",
+	"extra right bracket )":                          "W1005 1:8",
+	"extra right brace bracket }":                    "W1005 1:29",
+	"extra right square bracket ]":                   "W1005 1:10",
+	"unclosed left bracket":                          "W1006 1:19",
+	"unclosed multiline comment":                     "W1003 1:1",
+}
+
+// TestLexErrors checks the code and the position of the error reported by the
+// lexer for every negative case of lexTests, the ERROR token must be at the
+// same position
+func TestLexErrors(t *testing.T) {
+	for _, tc := range lexTests {
+		last := tc.tokens[len(tc.tokens)-1]
+		if last.Type != ERROR {
+			continue
+		}
+		expected, ok := lexErrors[tc.name]
+		if !ok {
+			t.Errorf("%s: no expected error in lexErrors", tc.name)
+			continue
+		}
+		_, errs := TokeniseAll(tc.name, tc.input, 0)
+		if len(errs) != 1 {
+			t.Errorf("%s: got errors %v, expected 1 error", tc.name, errs)
+			continue
+		}
+		if got := errs[0].Code + " " + errs[0].Pos.String(); got != expected {
+			t.Errorf("%s: got %s, expected %s", tc.name, got, expected)
+		}
+		l := Tokenise(tc.name, tc.input)
+		tkn := l.Next()
+		for ; tkn.Type != ERROR && tkn.Type != EOF; tkn = l.Next() {
+		}
+		if tkn.Pos != errs[0].Pos {
+			t.Errorf("%s: ERROR token at %s, expected %s", tc.name, tkn.Pos, errs[0].Pos)
+		}
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		kind  ErrorKind
+	}{
+		{"x $", UnexpectedChar},
+		{"x)", UnexpectedBracket},
+		{"(x", UnclosedBracket},
+		{"'abc", UnterminatedString},
+		{"`abc", UnterminatedString},
+		{"c'a", UnterminatedChar},
+		{"/* x", UnterminatedComment},
+		{`'\q'`, InvalidEscape},
+		{"c'ab'", InvalidChar},
+		{"0x", InvalidNumber},
+	} {
+		_, errs := TokeniseAll("kinds", tc.input, 0)
+		if len(errs) != 1 || errs[0].Kind != tc.kind {
+			t.Errorf("%q: got %v, expected an error of kind %s", tc.input, errs, tc.kind)
+		}
+	}
+}
+
+func TestBracketNotes(t *testing.T) {
+	for _, tc := range []struct {
+		input, note string
+	}{
+		{"x = (1,\n[2)", "2:1: [ opened here"},
+		{"f(\n  {\n", "2:3: { opened here"},
+	} {
+		_, errs := TokeniseAll("notes", tc.input, 0)
+		if len(errs) != 1 || len(errs[0].Notes) != 1 {
+			t.Errorf("%q: got %v, expected an error with one note", tc.input, errs)
+			continue
+		}
+		if n := errs[0].Notes[0]; n.Pos.String()+": "+n.Msg != tc.note {
+			t.Errorf("%q: got note %s: %s, expected %s", tc.input, n.Pos, n.Msg, tc.note)
+		}
+	}
+}
+
+func TestErrorListSortDedup(t *testing.T) {
+	var el ErrorList
+	el.Add("sort", NewPos(2, 1), "b")
+	el.Add("sort", NewPos(1, 3), "a")
+	el.Add("sort", NewPos(2, 1), "b")
+	el.Add("sort", NewPos(2, 1), "c")
+	el.Sort()
+	el = el.Dedup()
+	var got []string
+	for _, e := range el {
+		got = append(got, e.Error())
+	}
+	if expected := "sort:1:3: a|sort:2:1: b|sort:2:1: c"; strings.Join(got, "|") != expected {
+		t.Errorf("got %q, expected %q", strings.Join(got, "|"), expected)
+	}
+}
+
+func TestBOMPositions(t *testing.T) {
+	l := Tokenise("bom", "\uFEFFx y")
+	for _, want := range []string{"1:1", "1:3"} {
+		if tkn := l.Next(); tkn.Pos.String() != want {
+			t.Errorf("%v: got %s, expected %s", tkn, tkn.Pos, want)
+		}
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	fs := NewFileSet()
+	main := fs.AddFile("main.went", "x")
+	mod := fs.AddFile("mod.went", "\n  y")
+	for _, tc := range []struct {
+		file     *File
+		expected string
+	}{
+		{main, "main.went:1:1"},
+		{mod, "mod.went:2:3"},
+	} {
+		tkn := TokeniseFile(tc.file, 0).Next()
+		if got := fs.Position(tkn.Pos).String(); got != tc.expected {
+			t.Errorf("%v: got position %s, expected %s", tkn, got, tc.expected)
+		}
+	}
+	if got := fs.Position(Tokenise("none", "z").Next().Pos).String(); got != "1:1" {
+		t.Errorf("position without a file: got %s, expected 1:1", got)
+	}
+}
+
+func TestScanComments(t *testing.T) {
+	input := "x // line\n/* block */ y"
+	expected := []Token{makeName("x"), makeToken(COMMENT, "// line"), tknSemi,
+		makeToken(COMMENT, "/* block */"), makeName("y"), tknEOF}
+	tc := lexTestcase{"comments", input, expected}
+	if tkns := collectMode(&tc, ScanComments); !equal(tkns, tc.tokens, false) {
+		t.Errorf("%s: got\n\t%+v\nexpected\n\t%v", tc.name, tkns, tc.tokens)
+	}
+}
+
+func TestCRLFComments(t *testing.T) {
+	input := "x // line\r\ny"
+	expected := []Token{makeName("x"), makeToken(COMMENT, "// line"), tknSemi,
+		makeName("y"), tknEOF}
+	tc := lexTestcase{"crlf comments", input, expected}
+	if tkns := collectMode(&tc, ScanComments); !equal(tkns, tc.tokens, false) {
+		t.Errorf("%s: got\n\t%+v\nexpected\n\t%v", tc.name, tkns, tc.tokens)
+	}
+}
+
+func TestNoSemicolons(t *testing.T) {
+	tc := lexTestcase{"no semicolons", "x\n{y}",
+		[]Token{makeName("x"), tknLC, makeName("y"), tknRC, tknEOF}}
+	if tkns := collectMode(&tc, NoSemicolons); !equal(tkns, tc.tokens, false) {
+		t.Errorf("%s: got\n\t%+v\nexpected\n\t%v", tc.name, tkns, tc.tokens)
+	}
+}
+
+func TestNoLegacyOctals(t *testing.T) {
+	tc := lexTestcase{"no legacy octals", "0 0.5 0o17 017",
+		[]Token{makeToken(INT, "0"), makeToken(FLOAT, "0.5"), makeToken(INT, "0o17"),
+			makeError(`octal number with a leading zero, use the 0o prefix: "017"`)}}
+	if tkns := collectMode(&tc, NoLegacyOctals); !equal(tkns, tc.tokens, false) {
+		t.Errorf("%s: got\n\t%+v\nexpected\n\t%v", tc.name, tkns, tc.tokens)
+	}
+}
+
+func TestVisualCol(t *testing.T) {
+	for _, tc := range []struct {
+		line          string
+		col, tabWidth int
+		expected      int
+	}{
+		{"x = 1", 5, 8, 5},
+		{"\tx", 2, 8, 9},
+		{"\tx", 2, 4, 5},
+		{"ab\tx", 4, 4, 5},
+		{"\t\tx", 3, 0, 3},
+		{"é\tx", 3, 8, 9},
+	} {
+		if got := VisualCol(tc.line, tc.col, tc.tabWidth); got != tc.expected {
+			t.Errorf("VisualCol(%q, %d, %d): got %d, expected %d", tc.line, tc.col, tc.tabWidth, got, tc.expected)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	var tl List
+	tkns := func(values ...string) []Token {
+		res := make([]Token, len(values))
+		for i, v := range values {
+			res[i] = makeName(v)
+		}
+		return res
+	}
+	// grow the list past its initial capacity with the bottom wrapped around
+	tl.Push(tkns("c", "d", "e")...)
+	tl.Unshift(tkns("a", "b")...)
+	tl.Push(tkns("f", "g", "h", "i", "j")...)
+	if tl.Len() != 10 || tl.PeekBottom().Value != "a" || tl.PeekTop().Value != "j" {
+		t.Fatalf("got length %d, bottom %q and top %q, expected 10, \"a\" and \"j\"",
+			tl.Len(), tl.PeekBottom().Value, tl.PeekTop().Value)
+	}
+	if tkn := tl.Pop(); tkn.Value != "j" {
+		t.Errorf("Pop: got %q, expected \"j\"", tkn.Value)
+	}
+	var got []string
+	for !tl.Empty() {
+		got = append(got, tl.Shift().Value)
+	}
+	if expected := "a b c d e f g h i"; strings.Join(got, " ") != expected {
+		t.Errorf("Shift: got %q, expected %q", strings.Join(got, " "), expected)
+	}
+}
+
+// benchInput is a script of many lines exercising the common tokens
+var benchInput = strings.Repeat(`// compute the totals
+total = 0
+for x in [1, 2.5, 0x1F, 1_000] {
+	if x >= 2 && total != 10 {
+		total += x * 2 % 3
+	}
+	name = 'item ' + `+"`raw`"+`
+}
+`, 1000)
+
+func BenchmarkLex(b *testing.B) {
+	b.SetBytes(int64(len(benchInput)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l := Tokenise("bench", benchInput)
+		for tkn := l.Next(); tkn.Type != EOF && tkn.Type != ERROR; tkn = l.Next() {
+		}
+	}
+}
+
+func BenchmarkTokeniseAll(b *testing.B) {
+	b.SetBytes(int64(len(benchInput)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		TokeniseAll("bench", benchInput, 0)
+	}
+}
+
+// benchLongLine is a generated script of a single long line, the columns of its
+// tokens grow with the length of the line
+var benchLongLine = "xs = [" + strings.Repeat("1, 'é', x * 2, ", 5000) + "]\n"
+
+func TestLongLinePositions(t *testing.T) {
+	tkns, errs := TokeniseAll("long", benchLongLine, 0)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	var rbrack Token
+	for _, tkn := range tkns {
+		if tkn.Type == RSQUARE {
+			rbrack = tkn
+		}
+	}
+	col := utf8.RuneCountInString(benchLongLine[:strings.LastIndex(benchLongLine, "]")]) + 1
+	if want := fmt.Sprintf("1:%d", col); rbrack.Pos.String() != want {
+		t.Errorf("got ] at %s, expected %s", rbrack.Pos, want)
+	}
+}
+
+func BenchmarkLexLongLine(b *testing.B) {
+	b.SetBytes(int64(len(benchLongLine)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l := Tokenise("bench", benchLongLine)
+		for tkn := l.Next(); tkn.Type != EOF && tkn.Type != ERROR; tkn = l.Next() {
+		}
+	}
+}
@@ -5,22 +5,47 @@ import (
 	"strconv"
 )
 
-// Pos describes a source position via its line and col location, it is represented
-// by concatenating two 32-bit integers representing line and col.
+// Pos describes a source position via its file, line and col location, it is
+// represented by concatenating the index of the file in its FileSet (16 bits),
+// the line (28 bits) and the col (20 bits). Lines and columns start at 1, columns
+// count runes. Positions that are not in a FileSet have a file index of 0.
 type Pos uint64
 
-func newPos(line uint32, col uint32) Pos {
-	return Pos(uint64(line)<<32 | uint64(col))
+const (
+	lineBits = 28
+	colBits  = 20
+	lineMask = 1<<lineBits - 1
+	colMask  = 1<<colBits - 1
+)
+
+func newPos(file, line, col uint32) Pos {
+	return Pos(uint64(file)<<(lineBits+colBits) | uint64(line&lineMask)<<colBits | uint64(col&colMask))
 }
 
 // decompose Pos into line and col
 func (p Pos) decompose() (line int, col int) {
-	line = int(p >> 32)
-	col = int(0xffffffff & p)
+	line = int(p >> colBits & lineMask)
+	col = int(p & colMask)
 	return
 }
 
-// String returns the string representation of the position line:col
+// file returns the index of the file of the position in its FileSet
+func (p Pos) file() uint32 { return uint32(p >> (lineBits + colBits)) }
+
+// Line returns the line number of the position, starting at 1
+func (p Pos) Line() int {
+	line, _ := p.decompose()
+	return line
+}
+
+// Col returns the column number of the position
+func (p Pos) Col() int {
+	_, col := p.decompose()
+	return col
+}
+
+// String returns the string representation of the position line:col, use
+// FileSet.Position to include the name of the file
 func (p Pos) String() string {
 	line, col := p.decompose()
 	return fmt.Sprintf("%d:%d", line, col)
@@ -28,6 +53,32 @@ func (p Pos) String() string {
 
 // Pos helpers
 
+// DefaultTabWidth is the tab width used to display positions unless configured
+// otherwise, e.g. for the carets of error messages
+const DefaultTabWidth = 8
+
+// VisualCol returns the column at which the rune at col (as given by Pos.Col) is
+// displayed on the line, where a tab advances to the next multiple of tabWidth.
+// Columns start at 1, a tabWidth smaller than 1 counts a tab as a single column
+func VisualCol(line string, col, tabWidth int) int {
+	visual := 1
+	for i, r := range []rune(line) {
+		if i+1 >= col {
+			break
+		}
+		if r == '\t' && tabWidth > 0 {
+			visual += tabWidth - (visual-1)%tabWidth
+		} else {
+			visual++
+		}
+	}
+	return visual
+}
+
+// NewPos returns the position at the line and col of an input that is not
+// registered in a FileSet
+func NewPos(line, col int) Pos { return newPos(0, uint32(line), uint32(col)) }
+
 // AddOffset returns a new Pos by adding an offset to the col to a given Pos
 func AddOffset(p Pos, offset int) Pos {
 	line, newCol := p.decompose()
@@ -37,7 +88,71 @@ func AddOffset(p Pos, offset int) Pos {
 		// NOTE: update if running into issues relating to debugging
 		newCol = 0
 	}
-	return newPos(uint32(line), uint32(newCol))
+	return newPos(p.file(), uint32(line), uint32(newCol))
+}
+
+// File is a source file registered in a FileSet
+type File struct {
+	Name  string // name of the file, used for error reporting
+	Input string // content of the file
+	index uint32 // index of the file in its FileSet, starting at 1
+}
+
+// Pos returns the position at the line and col of the file
+func (f *File) Pos(line, col int) Pos { return newPos(f.index, uint32(line), uint32(col)) }
+
+// FileSet holds the files of a program, such as the main script and the modules
+// that it imports, so that positions can be mapped back to their file
+type FileSet struct {
+	files []*File
+}
+
+// NewFileSet returns an empty FileSet
+func NewFileSet() *FileSet { return &FileSet{} }
+
+// AddFile registers a file in the set, returning the file whose positions refer
+// to it
+func (fs *FileSet) AddFile(name, input string) *File {
+	if len(fs.files) == 1<<(64-lineBits-colBits)-1 {
+		panic("token: too many files in FileSet")
+	}
+	f := &File{Name: name, Input: input, index: uint32(len(fs.files) + 1)}
+	fs.files = append(fs.files, f)
+	return f
+}
+
+// File returns the file that the position refers to, or nil if the position is
+// not in any file of the set
+func (fs *FileSet) File(p Pos) *File {
+	if i := int(p.file()); i > 0 && i <= len(fs.files) {
+		return fs.files[i-1]
+	}
+	return nil
+}
+
+// Position is a position resolved to its file, line and col
+type Position struct {
+	Filename string // empty if the position is not in a file
+	Line     int
+	Col      int
+}
+
+// String returns the string representation of the position file:line:col, or
+// line:col if the position is not in a file
+func (p Position) String() string {
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Col)
+}
+
+// Position resolves the position to its file, line and col
+func (fs *FileSet) Position(p Pos) Position {
+	pos := Position{Line: p.Line(), Col: p.Col()}
+	if f := fs.File(p); f != nil {
+		pos.Filename = f.Name
+	}
+	return pos
 }
 
 // Token represents a Token of the Went programming language
@@ -45,10 +160,16 @@ func AddOffset(p Pos, offset int) Pos {
 // as its position within the source input
 type Token struct {
 	Type
-	Value string // value of this item
-	Pos
+	Value  string // value of this item
+	Pos           // position of the first character of the token
+	End    Pos    // position immediately after the token
+	Offset int    // byte offset of the token in the input
+	Len    int    // length in bytes of the token in the input, including quotes
 }
 
+// Source returns the text of the token in the input that it was scanned from
+func (tok Token) Source(input string) string { return input[tok.Offset : tok.Offset+tok.Len] }
+
 // Tkn returns itself, to be used to provide a default implementation
 // for embedding in a node. Embedded in all Nodes
 func (tok Token) Tkn() Token { return tok }
@@ -76,6 +197,7 @@ type Type int
 const (
 	ERROR Type = iota // error occurred; value is the text of error
 	EOF
+	COMMENT // comment, including its markers, only emitted with ScanComments
 
 	DOT       // .
 	COLON     // :
@@ -95,6 +217,7 @@ const (
 	INT   // Integer64
 	FLOAT // float64 numbers
 	STR   // Singly quoted ('\'') strings, escaped using a single '\' char
+	CHAR  // character literals (c'a'), the value is the character
 
 	operatorStart
 	PLUS  // +
@@ -137,12 +260,14 @@ const (
 	BREAK  // break keyword
 	CONT   // continue keyword
 	VAR    // var keyword (variable declaration)
+	YIELD  // yield keyword, makes a function a generator
 	keywordEnd
 )
 
 var tokenTypes = [...]string{
 	ERROR:       "ERROR",
 	EOF:         "EOF",
+	COMMENT:     "COMMENT",
 	DOT:         "DOT",
 	COLON:       ":",
 	SEMICOLON:   ";",
@@ -157,6 +282,7 @@ var tokenTypes = [...]string{
 	INT:         "INTEGER",
 	FLOAT:       "FLOAT",
 	STR:         "STRING",
+	CHAR:        "CHAR",
 	PLUS:        "+",
 	MINUS:       "-",
 	DIV:         "/",
@@ -191,6 +317,7 @@ var tokenTypes = [...]string{
 	BREAK:       "break",
 	CONT:        "continue",
 	VAR:         "var",
+	YIELD:       "yield",
 }
 
 func (t Type) String() string {
@@ -204,6 +331,9 @@ func (t Type) String() string {
 	return s
 }
 
+// IsOperator reports whether the token type is an operator
+func (t Type) IsOperator() bool { return operatorStart < t && t < operatorEnd }
+
 var keywords map[string]Type
 
 func init() {
@@ -213,41 +343,106 @@ func init() {
 	}
 }
 
-// List is the stack of tokens the bottom of the stack is index 0, while
-// top of stack is last index of the slice
-type List []Token
+// Keywords returns all the keywords of the went language, in the order that
+// they are declared
+func Keywords() []string {
+	kws := make([]string, 0, keywordEnd-keywordBegin-1)
+	for i := keywordBegin + 1; i < keywordEnd; i++ {
+		kws = append(kws, tokenTypes[i])
+	}
+	return kws
+}
+
+// LookupType returns the token type whose String is s, e.g. PLUS for "+" or
+// INT for "INTEGER"
+func LookupType(s string) (Type, bool) {
+	for t, name := range tokenTypes {
+		if name == s {
+			return Type(t), true
+		}
+	}
+	return 0, false
+}
+
+// List is a double-ended stack of tokens, the bottom of the stack is the next
+// token to be shifted, while the top is the last token pushed. It is a ring
+// buffer so that tokens are shifted and unshifted without copying the list,
+// the zero List is empty and ready to use
+type List struct {
+	buf  []Token // the ring buffer, its length is zero or a power of two
+	head int     // index of the bottom of the stack in buf
+	n    int     // number of tokens in the stack
+}
 
 // Empty checks if a token list is empty
-func (tl *List) Empty() bool { return len(*tl) == 0 }
+func (tl *List) Empty() bool { return tl.n == 0 }
+
+// Len returns the number of tokens in the list
+func (tl *List) Len() int { return tl.n }
+
+// index returns the index in the buffer of the i-th token from the bottom
+func (tl *List) index(i int) int { return (tl.head + i) & (len(tl.buf) - 1) }
+
+// grow makes room for n more tokens, keeping the tokens in order
+func (tl *List) grow(n int) {
+	if tl.n+n <= len(tl.buf) {
+		return
+	}
+	size := 8
+	for size < tl.n+n {
+		size *= 2
+	}
+	buf := make([]Token, size)
+	for i := 0; i < tl.n; i++ {
+		buf[i] = tl.buf[tl.index(i)]
+	}
+	tl.buf, tl.head = buf, 0
+}
 
 // Push a series of tokens in sequence to the top of the stack
-func (tl *List) Push(tkns ...Token) { *tl = append(*tl, tkns...) }
+func (tl *List) Push(tkns ...Token) {
+	tl.grow(len(tkns))
+	for _, tkn := range tkns {
+		tl.buf[tl.index(tl.n)] = tkn
+		tl.n++
+	}
+}
 
 // Pop removes a Token from the top of the stack, you should always check if
 // the stack is empty prior to popping
 func (tl *List) Pop() (tkn Token) {
-	tkn, *tl = (*tl)[len(*tl)-1], (*tl)[:len(*tl)-1]
+	i := tl.index(tl.n - 1)
+	tkn, tl.buf[i] = tl.buf[i], Token{}
+	tl.n--
 	return
 }
 
 // PeekTop looks at the top of the stack without consuming the Token, you should always
 // check if the stack is empty prior to peeking
 func (tl *List) PeekTop() Token {
-	return (*tl)[len(*tl)-1]
+	return tl.buf[tl.index(tl.n-1)]
 }
 
-// Unshift pushes a series of tokens to the bottom of the stack
+// Unshift pushes a series of tokens to the bottom of the stack, the first of
+// the tokens becomes the bottom
 func (tl *List) Unshift(tkns ...Token) {
-	*tl = append(tkns, (*tl)...)
+	tl.grow(len(tkns))
+	tl.head = (tl.head - len(tkns)) & (len(tl.buf) - 1)
+	tl.n += len(tkns)
+	for i, tkn := range tkns {
+		tl.buf[tl.index(i)] = tkn
+	}
 }
 
 // Shift removes a Token from the bottom of the stack, you should always check if
 // the stack is empty prior to shifting
 func (tl *List) Shift() (tkn Token) {
-	tkn, *tl = (*tl)[0], (*tl)[1:]
+	tkn, tl.buf[tl.head] = tl.buf[tl.head], Token{}
+	tl.head = tl.index(1)
+	tl.n--
 	return
 }
 
 // PeekBottom looks at the bottom of the stack without consuming the Token
 // you should always check if the stack is empty prior to peeking
-func (tl *List) PeekBottom() Token { return (*tl)[0] }
+func (tl *List) PeekBottom() Token { return tl.buf[tl.head] }
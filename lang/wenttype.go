@@ -3,10 +3,16 @@ package lang
 import (
 	"bytes"
 	"fmt"
+	"reflect"
+	"sort"
+	"unicode/utf8"
 )
 
 // WType is an interface where all other `went` language data structures
-// should implemented, null is not within these types
+// should implemented, null is not within these types. Its String method
+// returns the debug form of the value, the form written in the source for
+// strings, numbers, booleans, lists and maps, that is used by the REPL and by
+// error messages, see Str for the form printed by a script.
 type WType interface {
 	IsZeroValue() WBool                    // returns true if the value is zero value
 	Equals(w2 WType) WBool                 // returns true if the object compared to it is equals
@@ -15,8 +21,17 @@ type WType interface {
 	String() string
 }
 
+// Str returns the value as it is printed for people to read, e.g. by print,
+// it is the same as its String form except that strings are not quoted
+func Str(w WType) string {
+	if s, ok := w.(WString); ok {
+		return string(s)
+	}
+	return w.String()
+}
+
 func opError(w1, w2 WType, compString string) error {
-	return fmt.Errorf("'%s' not supported between types '%T' and '%T'", compString, w1, w2)
+	return fmt.Errorf("'%s' not supported between types '%s' and '%s'", compString, TypeName(w1), TypeName(w2))
 }
 
 var (
@@ -184,7 +199,43 @@ func (w WString) Gr(w2 WType, orEq bool) (WBool, error) {
 	return !smRes, nil
 }
 
-func (w WString) String() string { return fmt.Sprintf("'%v'", string(w)) }
+// String returns the string quoted as a string literal, escaping the quotes
+// and the control characters, see Str for the string as it is
+func (w WString) String() string { return quote(string(w)) }
+
+// Strings are sequences of characters (Unicode code points) rather than bytes,
+// indices and lengths of strings count characters
+
+// Len returns the number of characters in the string
+func (w WString) Len() int { return utf8.RuneCountInString(string(w)) }
+
+// Chars returns each character of the string, in order
+func (w WString) Chars() []WString {
+	chars := make([]WString, 0, len(w))
+	for _, r := range string(w) {
+		chars = append(chars, WString(r))
+	}
+	return chars
+}
+
+// Index returns the character at index i, returns an error if i is out of range
+func (w WString) Index(i int) (WString, error) {
+	runes := []rune(string(w))
+	if i < 0 || i >= len(runes) {
+		return "", fmt.Errorf("string index %d out of range with length %d", i, len(runes))
+	}
+	return WString(runes[i]), nil
+}
+
+// Slice returns the characters from index lo up to but not including index hi,
+// returns an error if the indices are out of range
+func (w WString) Slice(lo, hi int) (WString, error) {
+	runes := []rune(string(w))
+	if lo < 0 || hi < lo || hi > len(runes) {
+		return "", fmt.Errorf("string slice [%d:%d] out of range with length %d", lo, hi, len(runes))
+	}
+	return WString(runes[lo:hi]), nil
+}
 
 // WBool is a boolean
 type WBool bool
@@ -241,37 +292,16 @@ type WList []WType
 // IsZeroValue returns the zero value of a went list
 func (w WList) IsZeroValue() WBool { return len(w) == 0 }
 
-// Equals checks if the type compared to is equal
-func (w WList) Equals(w2 WType) WBool {
-	v, ok := w2.(WList)
-	if !ok {
-		return false
-	} else if len(w) != len(v) {
-		return false
-	}
-	for i := 0; i < len(w); i++ {
-		if !w[i].Equals(v[i]) {
-			return false
-		}
-	}
-	return true
-}
+// Equals checks if the type compared to is equal, a list holding itself is
+// equal to another if they are equal where they do not hold themselves
+func (w WList) Equals(w2 WType) WBool { return equals(w, w2, map[[2]container]bool{}) }
 
 // Sm returns true if w is smaller than w2, false else, returns an error if the
 // 2 are of different types
 func (w WList) Sm(w2 WType, orEq bool) (WBool, error) {
 	switch v := w2.(type) {
 	case WList:
-		minLen := min(len(w), len(v))
-		for i := 0; i < minLen; i++ {
-			if !w[i].Equals(v[i]) {
-				return w[i].Sm(v[i], orEq)
-			}
-		}
-		if orEq {
-			return len(w) <= len(v), nil
-		}
-		return len(w) < len(v), nil
+		return seqSm(w, v, orEq, map[[2]container]bool{})
 	default:
 		var operator string
 		if orEq {
@@ -284,6 +314,40 @@ func (w WList) Sm(w2 WType, orEq bool) (WBool, error) {
 	}
 }
 
+// seqSm compares 2 sequences element by element, the first elements that are
+// not equal decide the order, else the shorter sequence is the smaller. seen
+// holds the pairs of lists being compared, a pair nested in itself is skipped
+// as its order is decided where it is first compared.
+func seqSm(a, b []WType, orEq bool, seen map[[2]container]bool) (WBool, error) {
+	if pair, ok := pairOf(WList(a), WList(b)); ok {
+		seen[pair] = true
+		defer delete(seen, pair)
+	}
+	for i := 0; i < min(len(a), len(b)); i++ {
+		if pair, ok := pairOf(a[i], b[i]); ok && seen[pair] {
+			continue
+		}
+		if equals(a[i], b[i], map[[2]container]bool{}) {
+			continue
+		}
+		switch x := a[i].(type) {
+		case WList:
+			if y, ok := b[i].(WList); ok {
+				return seqSm(x, y, orEq, seen)
+			}
+		case WTuple:
+			if y, ok := b[i].(WTuple); ok {
+				return seqSm(x, y, orEq, seen)
+			}
+		}
+		return a[i].Sm(b[i], orEq)
+	}
+	if orEq {
+		return len(a) <= len(b), nil
+	}
+	return len(a) < len(b), nil
+}
+
 // Gr (see Sm)
 // a >= b <==> !(a < b)
 // a > b <==> !(a <= b)
@@ -301,19 +365,52 @@ func (w WList) Gr(w2 WType, orEq bool) (WBool, error) {
 	return !smRes, nil
 }
 
-func (w WList) String() string {
-	var buffer bytes.Buffer
-	buffer.WriteString("[")
-	for i, v := range w {
-		buffer.WriteString(v.String())
-		if i != len(w)-1 {
-			buffer.WriteString(", ")
+// String returns the elements in square brackets, a list held by itself is
+// written as [...] where it is nested
+func (w WList) String() string { return debugString(w, 0, map[container]bool{}) }
+
+// WTuple is an immutable sequence, e.g. the results of a function returning
+// multiple values, it is hashable if its elements are
+type WTuple []WType
+
+// IsZeroValue returns the zero value of a went tuple
+func (w WTuple) IsZeroValue() WBool { return len(w) == 0 }
+
+// Equals checks if the type compared to is equal
+func (w WTuple) Equals(w2 WType) WBool { return equals(w, w2, map[[2]container]bool{}) }
+
+// Sm returns true if w is smaller than w2, false else, returns an error if the
+// 2 are of different types
+func (w WTuple) Sm(w2 WType, orEq bool) (WBool, error) {
+	if v, ok := w2.(WTuple); ok {
+		return seqSm(w, v, orEq, map[[2]container]bool{})
+	}
+	operator := sm
+	if orEq {
+		operator = smE
+	}
+	return false, opError(w, w2, operator)
+}
+
+// Gr (see Sm)
+// a >= b <==> !(a < b)
+// a > b <==> !(a <= b)
+func (w WTuple) Gr(w2 WType, orEq bool) (WBool, error) {
+	smRes, err := w.Sm(w2, !orEq)
+	if err != nil {
+		operator := gr
+		if orEq {
+			operator = grE
 		}
+		return false, opError(w, w2, operator)
 	}
-	buffer.WriteString("]")
-	return buffer.String()
+	return !smRes, nil
 }
 
+// String returns the elements in round brackets, a tuple of a single element
+// has a trailing comma as in its literal
+func (w WTuple) String() string { return debugString(w, 0, map[container]bool{}) }
+
 var (
 	tab        = "\t"
 	twoSpaces  = "  "
@@ -321,24 +418,116 @@ var (
 )
 
 // Wmap is a naive implementation of a went "map" data structure
-// a data structure that maps strings to other values in wentlang
-type Wmap map[string]WType
+// a data structure that maps keys to other values in wentlang. The keys are
+// hashable values, i.e. numbers, strings, booleans, null and tuples of those,
+// two keys are the same key if they are equal, e.g. 1 and 1.0 but not 1 and
+// '1'. Lists, maps and functions are not hashable as they may change or have no
+// equality. The entries are stored by the hash key of their key, see Get and
+// Set.
+type Wmap map[interface{}]mapEntry
+
+// mapEntry is a key of a map and the value it maps to
+type mapEntry struct{ key, value WType }
+
+// tupleKey is the hash key of a tuple, a tuple cannot be compared by Go so it
+// is stored as the hash key of its first element followed by the tupleKey of
+// the rest, the empty tuple being the zero tupleKey. Equal tuples then have
+// equal keys as their elements do, e.g. (0.0,) and (-0.0,).
+type tupleKey struct {
+	head, tail interface{}
+}
+
+// hashKey returns the key by which the entry of a hashable key is stored
+func hashKey(key WType) interface{} {
+	t, ok := key.(WTuple)
+	if !ok {
+		return key
+	}
+	var k tupleKey
+	for i := len(t) - 1; i >= 0; i-- {
+		k = tupleKey{hashKey(t[i]), k}
+	}
+	return k
+}
 
-// toString returns a string that is essentially a pretty-printed formatted Wmap
-func (w Wmap) toString(tabLevel int) string {
+// Hashable reports whether the value may be a key of a map
+func Hashable(w WType) bool {
+	switch v := w.(type) {
+	case WNum, WString, WBool, WNull:
+		return true
+	case WTuple:
+		for _, el := range v {
+			if !Hashable(el) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Get returns the value of the key, and whether the map has the key
+func (w Wmap) Get(key WType) (WType, bool) {
+	e, ok := w[hashKey(key)]
+	return e.value, ok
+}
+
+// Set maps the key to the value, the key must be hashable
+func (w Wmap) Set(key, value WType) { w[hashKey(key)] = mapEntry{key, value} }
+
+// keyRank orders the types of the keys of a map, keys of different types are
+// ordered by type and keys of the same type by value
+func keyRank(w WType) int {
+	switch w.(type) {
+	case WNull:
+		return 0
+	case WBool:
+		return 1
+	case WNum:
+		return 2
+	case WString:
+		return 3
+	}
+	return 4
+}
+
+// Keys returns the keys of the map in sorted order, null first, then false and
+// true, the numbers, the strings and the tuples
+func (w Wmap) Keys() []WType {
+	keys := make([]WType, 0, len(w))
+	for _, e := range w {
+		keys = append(keys, e.key)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		ra, rb := keyRank(keys[a]), keyRank(keys[b])
+		if ra != rb {
+			return ra < rb
+		}
+		if v, ok := keys[a].(WBool); ok {
+			return !bool(v) && bool(keys[b].(WBool))
+		}
+		less, err := keys[a].Sm(keys[b], false)
+		if err != nil {
+			// tuples whose elements have no order relation
+			return keys[a].String() < keys[b].String()
+		}
+		return bool(less)
+	})
+	return keys
+}
+
+// toString returns a string that is essentially a pretty-printed formatted
+// Wmap, seen holds the lists and maps being written, see debugString
+func (w Wmap) toString(tabLevel int, seen map[container]bool) string {
 	var buffer bytes.Buffer
 	buffer.WriteString("{\n")
-	for k, v := range w {
+	for _, k := range w.Keys() {
 		// adds a new tab in addition to the number of tabLevels while inside the body
 		for i := 0; i < tabLevel+1; i++ {
 			buffer.WriteString(twoSpaces)
 		}
-		switch vTyped := v.(type) {
-		case Wmap:
-			buffer.WriteString(fmt.Sprintf("%s: %v,\n", k, vTyped.toString(tabLevel+1)))
-		default:
-			buffer.WriteString(fmt.Sprintf("%s: %v,\n", k, vTyped))
-		}
+		v, _ := w.Get(k)
+		buffer.WriteString(fmt.Sprintf("%s: %s,\n", k, debugString(v, tabLevel+1, seen)))
 	}
 	for i := 0; i < tabLevel; i++ {
 		buffer.WriteString(twoSpaces)
@@ -350,21 +539,9 @@ func (w Wmap) toString(tabLevel int) string {
 // IsZeroValue returns the zero value of a went map
 func (w Wmap) IsZeroValue() WBool { return len(w) == 0 }
 
-// Equals checks if the type compared to is equal
-func (w Wmap) Equals(w2 WType) WBool {
-	map2, ok := w2.(Wmap)
-	if !ok {
-		return false
-	} else if len(w) != len(map2) {
-		return false
-	}
-	for k1, v1 := range w {
-		if !v1.Equals(map2[k1]) {
-			return false
-		}
-	}
-	return true
-}
+// Equals checks if the type compared to is equal, a map holding itself is
+// equal to another if they are equal where they do not hold themselves
+func (w Wmap) Equals(w2 WType) WBool { return equals(w, w2, map[[2]container]bool{}) }
 
 // Sm will always return false and an error for Wmap as Wmap has
 // no order relation
@@ -399,10 +576,174 @@ func (w Wmap) Gr(w2 WType, orEq bool) (WBool, error) {
 	return !smRes, nil
 }
 
-func (w Wmap) String() string { return w.toString(0) }
+func (w Wmap) String() string { return debugString(w, 0, map[container]bool{}) }
+
+// WFunc is a function, it holds the frame it was defined in so that its body
+// may refer to the names of the enclosing functions
+type WFunc struct {
+	name      string
+	lit       *FuncLit
+	closure   *frame
+	generator bool // whether the body has a yield, a call then returns a WGenerator
+}
+
+// IsZeroValue always returns false as functions have no zero value
+func (w *WFunc) IsZeroValue() WBool { return false }
+
+// Equals returns true only if both are the same function
+func (w *WFunc) Equals(w2 WType) WBool {
+	v, ok := w2.(*WFunc)
+	return WBool(ok && v == w)
+}
+
+// Sm will always return false and an error for WFunc as WFunc has
+// no order relation
+func (w *WFunc) Sm(w2 WType, orEq bool) (WBool, error) {
+	operator := sm
+	if orEq {
+		operator = smE
+	}
+	return false, opError(w, w2, operator)
+}
+
+// Gr (see Sm)
+func (w *WFunc) Gr(w2 WType, orEq bool) (WBool, error) {
+	operator := gr
+	if orEq {
+		operator = grE
+	}
+	return false, opError(w, w2, operator)
+}
+
+func (w *WFunc) String() string { return fmt.Sprintf("<func %s>", w.name) }
+
+// Name returns the name the function was declared with, "func" for a function
+// literal
+func (w *WFunc) Name() string { return w.name }
+
+// Lit returns the function literal that the function was created from
+func (w *WFunc) Lit() *FuncLit { return w.lit }
 
 // Helper functions
 
+// container identifies the elements of a list or a map that are shared by the
+// values referring to them
+type container struct {
+	ptr uintptr
+	len int
+}
+
+// containerOf returns the container of a list or a map, false for other
+// values and empty lists which hold no elements to share
+func containerOf(w WType) (container, bool) {
+	switch v := w.(type) {
+	case WList:
+		if len(v) > 0 {
+			return container{reflect.ValueOf(v).Pointer(), len(v)}, true
+		}
+	case Wmap:
+		return container{reflect.ValueOf(v).Pointer(), -1}, true
+	}
+	return container{}, false
+}
+
+// pairOf returns the containers of two values, false unless both are lists
+// or maps
+func pairOf(a, b WType) ([2]container, bool) {
+	ca, okA := containerOf(a)
+	cb, okB := containerOf(b)
+	return [2]container{ca, cb}, okA && okB
+}
+
+// debugString returns the String form of a value, seen holds the lists and
+// maps being written so that one nested in itself is written as [...] or
+// {...} rather than forever. tabLevel is the indentation of a map.
+func debugString(w WType, tabLevel int, seen map[container]bool) string {
+	if c, ok := containerOf(w); ok {
+		if seen[c] {
+			if _, isMap := w.(Wmap); isMap {
+				return "{...}"
+			}
+			return "[...]"
+		}
+		seen[c] = true
+		defer delete(seen, c)
+	}
+	var elements []WType
+	switch v := w.(type) {
+	case Wmap:
+		return v.toString(tabLevel, seen)
+	case WList:
+		elements = v
+	case WTuple:
+		elements = v
+	default:
+		return w.String()
+	}
+	var buffer bytes.Buffer
+	for i, el := range elements {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(debugString(el, 0, seen))
+	}
+	if _, isList := w.(WList); isList {
+		return "[" + buffer.String() + "]"
+	}
+	if len(elements) == 1 {
+		// a tuple of a single element has a trailing comma as in its literal
+		return "(" + buffer.String() + ",)"
+	}
+	return "(" + buffer.String() + ")"
+}
+
+// equals reports whether two values are equal, seen holds the pairs of lists
+// and maps being compared so that a pair compared again where it is nested in
+// itself is taken as equal rather than compared forever
+func equals(a, b WType, seen map[[2]container]bool) WBool {
+	if pair, ok := pairOf(a, b); ok {
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+	}
+	switch x := a.(type) {
+	case WList:
+		y, ok := b.(WList)
+		return WBool(ok) && seqEquals(x, y, seen)
+	case WTuple:
+		y, ok := b.(WTuple)
+		return WBool(ok) && seqEquals(x, y, seen)
+	case Wmap:
+		y, ok := b.(Wmap)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, e1 := range x {
+			e2, ok := y[k]
+			if !ok || !bool(equals(e1.value, e2.value, seen)) {
+				return false
+			}
+		}
+		return true
+	}
+	return a.Equals(b)
+}
+
+// seqEquals reports whether two sequences have equal elements, see equals
+func seqEquals(a, b []WType, seen map[[2]container]bool) WBool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equals(a[i], b[i], seen) {
+			return false
+		}
+	}
+	return true
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
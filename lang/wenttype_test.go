@@ -0,0 +1,181 @@
+package lang
+
+import (
+	"math"
+	"testing"
+)
+
+// cyclic returns a list and a map that hold themselves, and a list that holds
+// the map
+func cyclic() (WList, Wmap, WList) {
+	l := WList{WNum(1), WNull{}}
+	l[1] = l
+	m := Wmap{}
+	m.Set(WString("a"), WNum(1))
+	m.Set(WString("self"), m)
+	return l, m, WList{m}
+}
+
+func TestCyclicString(t *testing.T) {
+	l, m, lm := cyclic()
+	for _, tc := range []struct {
+		name     string
+		value    WType
+		expected string
+	}{
+		{"list", l, "[1, [...]]"},
+		{"map", m, "{\n  'a': 1,\n  'self': {...},\n}"},
+		{"list of a map", lm, "[{\n  'a': 1,\n  'self': {...},\n}]"},
+		{"tuple", WTuple{l, l}, "([1, [...]], [1, [...]])"},
+	} {
+		if got := tc.value.String(); got != tc.expected {
+			t.Errorf("%s: got %q, expected %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestCyclicEquals(t *testing.T) {
+	l, m, lm := cyclic()
+	l2, m2, lm2 := cyclic()
+	l3 := WList{WNum(2), WNull{}}
+	l3[1] = l3
+	for _, tc := range []struct {
+		name     string
+		a, b     WType
+		expected WBool
+	}{
+		{"same list", l, l, true},
+		{"equal lists", l, l2, true},
+		{"lists with different elements", l, l3, false},
+		{"equal maps", m, m2, true},
+		{"lists of equal maps", lm, lm2, true},
+		{"list and map", l, m, false},
+	} {
+		if got := tc.a.Equals(tc.b); got != tc.expected {
+			t.Errorf("%s: got %v, expected %v", tc.name, got, tc.expected)
+		}
+	}
+	if less, err := l.Sm(l3, false); err != nil || !bool(less) {
+		t.Errorf("got %v, %v for %s < %s, expected true", less, err, l, l3)
+	}
+}
+
+func TestWStringLen(t *testing.T) {
+	for _, tc := range []struct {
+		s        WString
+		expected int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"héllo", 5}, // characters, not bytes
+		{"日本", 2},
+	} {
+		if got := tc.s.Len(); got != tc.expected {
+			t.Errorf("%q: got length %d, expected %d", tc.s, got, tc.expected)
+		}
+	}
+}
+
+func TestWStringIndex(t *testing.T) {
+	for _, tc := range []struct {
+		s        WString
+		i        int
+		expected WString
+		err      string
+	}{
+		{"abc", 0, "a", ""},
+		{"héllo", 1, "é", ""},
+		{"héllo", 4, "o", ""},
+		{"abc", -1, "", "string index -1 out of range with length 3"},
+		{"abc", 3, "", "string index 3 out of range with length 3"},
+		{"", 0, "", "string index 0 out of range with length 0"},
+	} {
+		got, err := tc.s.Index(tc.i)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%q[%d]: got error %v, expected %q", tc.s, tc.i, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != tc.expected {
+			t.Errorf("%q[%d]: got %q, %v, expected %q", tc.s, tc.i, got, err, tc.expected)
+		}
+	}
+}
+
+func TestWStringSlice(t *testing.T) {
+	for _, tc := range []struct {
+		s        WString
+		lo, hi   int
+		expected WString
+		err      string
+	}{
+		{"abc", 0, 3, "abc", ""},
+		{"héllo", 1, 3, "él", ""},
+		{"abc", 1, 1, "", ""},
+		{"abc", 3, 3, "", ""},
+		{"abc", -1, 2, "", "string slice [-1:2] out of range with length 3"},
+		{"abc", 2, 1, "", "string slice [2:1] out of range with length 3"},
+		{"héllo", 0, 6, "", "string slice [0:6] out of range with length 5"},
+	} {
+		got, err := tc.s.Slice(tc.lo, tc.hi)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%q[%d:%d]: got error %v, expected %q", tc.s, tc.lo, tc.hi, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != tc.expected {
+			t.Errorf("%q[%d:%d]: got %q, %v, expected %q", tc.s, tc.lo, tc.hi, got, err, tc.expected)
+		}
+	}
+}
+
+func TestTupleKeys(t *testing.T) {
+	m := Wmap{}
+	m.Set(WTuple{WNum(0), WTuple{WString("a")}}, WString("set"))
+	for _, tc := range []struct {
+		name  string
+		key   WTuple
+		found bool
+	}{
+		{"same tuple", WTuple{WNum(0), WTuple{WString("a")}}, true},
+		{"negative zero", WTuple{WNum(math.Copysign(0, -1)), WTuple{WString("a")}}, true},
+		{"float element", WTuple{WNum(0.0), WTuple{WString("a")}}, true},
+		{"string element", WTuple{WString("0"), WTuple{WString("a")}}, false},
+		{"flattened", WTuple{WNum(0), WString("a")}, false},
+		{"prefix", WTuple{WNum(0)}, false},
+		{"empty", WTuple{}, false},
+	} {
+		if _, found := m.Get(tc.key); found != tc.found {
+			t.Errorf("%s: got found %v for %s, expected %v", tc.name, found, tc.key, tc.found)
+		}
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	for _, tc := range []struct {
+		value    WType
+		expected string
+	}{
+		{WNull{}, "null"},
+		{WNum(1.5), "number"},
+		{WString("a"), "string"},
+		{WBool(true), "bool"},
+		{WList{}, "list"},
+		{WTuple{WNum(1)}, "tuple"},
+		{Wmap{}, "map"},
+		{&WFunc{name: "f"}, "function"},
+		{builtins["len"], "builtin"},
+		{&WIterator{}, "iterator"},
+		{&WGenerator{}, "generator"},
+	} {
+		if got := TypeName(tc.value); got != tc.expected {
+			t.Errorf("%#v: got %q, expected %q", tc.value, got, tc.expected)
+		}
+	}
+	if _, err := (WList{}).Sm(WString("a"), false); err == nil ||
+		err.Error() != "'<' not supported between types 'list' and 'string'" {
+		t.Errorf("got error %v comparing a list and a string", err)
+	}
+}